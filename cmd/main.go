@@ -9,6 +9,8 @@ import (
 	"syscall"
 
 	alert "httplogmonitor/pkg/alertmanager"
+	"httplogmonitor/pkg/analyzer"
+	"httplogmonitor/pkg/clock"
 	"httplogmonitor/pkg/collector"
 	"httplogmonitor/pkg/config"
 	"httplogmonitor/pkg/printer"
@@ -16,33 +18,52 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "analyze":
+			analyze(os.Args[2:])
+			return
+		case "replay":
+			replay(os.Args[2:])
+			return
+		}
+	}
+
 	// read the program args
 	cfg := config.NewFromArgs()
+	// the log files are tailed from their end
+	detected := detectFormat(cfg, true)
 
 	// workers
 	r := reader.New(cfg)
-	c := collector.New(cfg)
+	c, err := collector.New(cfg)
+	if err != nil {
+		panic(err)
+	}
 	a := alert.New(cfg)
 	p := printer.New(cfg)
 
 	// using context and waitgroup to do a graceful stop of Reader.
 	// strictly speaking, it's not really necessary as we only read
-	// and all the resources (file descriptor) will be freed once the process is terminated.
-	// however, as the log file is the only "precious" artifact here - let's close it correctly
+	// and all the resources (file descriptors) will be freed once the process is terminated.
+	// however, as the log files are the only "precious" artifacts here - let's close them correctly
 	wg := &sync.WaitGroup{}
 	wg.Add(1)
 	ctx, cancelCtx := context.WithCancel(context.Background())
 	// making log and metric channels buffered
 	// to not break the reader's tick in case of the slow consumers
 	// however, it's unlikely that the consumers will be slower than Reader
-	logCh := make(chan string, cfg.LogBufferSize)
+	logCh := make(chan collector.LogEntry, cfg.LogBufferSize)
 	metCh := make(chan alert.Metric, cfg.MetricBufferSize)
 	printCh := make(chan printer.Formatter)
 
 	go r.Start(ctx, logCh, metCh, printCh, wg)
-	go c.Start(logCh, printCh)
+	go c.Start(logCh, metCh, printCh)
 	go a.Start(metCh, printCh)
 	go p.Start(printCh)
+	if detected != nil {
+		printCh <- detected
+	}
 
 	// signal handling
 	sigCh := make(chan os.Signal, 1)
@@ -53,3 +74,129 @@ func main() {
 	wg.Wait()
 	fmt.Println("\nStopped")
 }
+
+// analyze runs the offline analysis of complete log files
+// printing the final report once all the files are read
+func analyze(args []string) {
+	cfg := config.NewAnalyzeFromArgs(args)
+	detected := detectFormat(cfg, false)
+
+	a := analyzer.New(cfg)
+	p := printer.New(cfg)
+
+	printCh := make(chan printer.Formatter)
+	go func() {
+		if detected != nil {
+			printCh <- detected
+		}
+		a.Start(printCh)
+		close(printCh)
+	}()
+	// printer stops once all the messages are printed
+	p.Start(printCh)
+}
+
+// replay feeds the historical log files through the whole pipeline
+// the summaries and alerts are stamped with the log time
+func replay(args []string) {
+	cfg := config.NewReplayFromArgs(args)
+	detected := detectFormat(cfg, false)
+
+	// the log time is taken from the log entries in the configured format
+	parser, err := collector.NewParser(cfg)
+	if err != nil {
+		panic(err)
+	}
+
+	// workers sharing the log time
+	clk := clock.NewVirtual()
+	r := reader.NewReplayer(cfg, clk, collector.LogTime(parser))
+	c, err := collector.NewWithClock(cfg, clk)
+	if err != nil {
+		panic(err)
+	}
+	a := alert.New(cfg)
+	p := printer.New(cfg)
+
+	// replayer closes the log channel once all the files are replayed,
+	// the collector stops after sending its last messages then,
+	// the metric channel is closed once both of them are done to stop the alertmanager
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	logCh := make(chan collector.LogEntry, cfg.LogBufferSize)
+	metCh := make(chan alert.Metric, cfg.MetricBufferSize)
+	printCh := make(chan printer.Formatter)
+
+	collectorDone := make(chan struct{})
+	alertDone := make(chan struct{})
+	go r.Start(ctx, logCh, metCh, printCh, wg)
+	go func() {
+		c.Start(logCh, metCh, printCh)
+		close(collectorDone)
+	}()
+	go func() {
+		a.Start(metCh, printCh)
+		close(alertDone)
+	}()
+	printDone := make(chan struct{})
+	go func() {
+		p.Start(printCh)
+		close(printDone)
+	}()
+	if detected != nil {
+		printCh <- detected
+	}
+
+	// signal handling
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+	replayDone := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(replayDone)
+	}()
+	finished := false
+	select {
+	case <-sigCh:
+		cancelCtx()
+	case <-replayDone:
+		finished = true
+	}
+
+	wg.Wait()
+	<-collectorDone
+	close(metCh)
+	<-alertDone
+	if finished {
+		printCh <- printer.NewInfoMessage("Replay finished")
+	}
+	close(printCh)
+	<-printDone
+	cancelCtx()
+}
+
+// detectFormat sets the format of the log entries detected from the first (or last) lines of the log files
+// if it's not given explicitly, falls back to the common/combined log format if the detection fails
+// returns the message about the detection to be printed, nil if there was no detection
+func detectFormat(cfg *config.Config, last bool) printer.Formatter {
+	if cfg.LogFormat != config.LogFormatAuto {
+		return nil
+	}
+
+	d, err := collector.DetectFormat(cfg, reader.SampleEntries(cfg.LogFilePaths, cfg.DetectLines, last))
+	if err == collector.ErrNoSample {
+		cfg.LogFormat = config.LogFormatCLF
+		return printer.NewInfoMessage(fmt.Sprintf("No log entry to detect the log format from yet. Falling back to %s, set the format with -format", config.LogFormatCLF))
+	}
+	if err != nil {
+		cfg.LogFormat = config.LogFormatCLF
+		return printer.NewErrorMessage(fmt.Sprintf("Failed to detect the log format: %s. Falling back to %s, set the format with -format", err, config.LogFormatCLF))
+	}
+
+	cfg.LogFormat = d.Format
+	if len(d.JSONFields) != 0 {
+		cfg.JSONFields = d.JSONFields
+	}
+	return printer.NewInfoMessage(fmt.Sprintf("Detected log format: %s (confidence %.0f%%, %d of %d sampled lines matched)", d.Name(), d.Confidence()*100, d.Matched, d.Sampled))
+}
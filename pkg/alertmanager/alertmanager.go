@@ -10,79 +10,150 @@ import (
 )
 
 // AlertManager collects the traffic metrics and prints them every summary interval
+// the alert rules (the high traffic one, the bandwidth one if its threshold is set and the ones of the rules file)
+// are evaluated on the metrics and the unique clients of the summary intervals are monitored if their alerting factor is set
 type AlertManager struct {
-	buf            []int
-	threshold      int
-	ptr            int
-	sum            int
-	alertTriggered bool
+	hits *window
+	// bandwidth is nil if no rule is evaluated on the bytes
+	bandwidth *window
+	rules     []*ruleState
+	// unique are the unique clients of the previous summary intervals, nil if the unique clients alerting is off
+	unique               *window
+	uniqueJump           float64
+	uniqueAlertTriggered bool
+	// lastUnique is the unique clients of the last summary interval, uniqueAvg their average before it
+	lastUnique int
+	uniqueAvg  int
 }
 
 // New returns a new instance of AlertManager
 func New(cfg *config.Config) *AlertManager {
-	return &AlertManager{
-		buf:       make([]int, 0, cfg.MonitorWindowSec/cfg.PollIntervalSec),
-		threshold: cfg.AlertThreshold,
-		ptr:       0,
-		sum:       0,
+	a := &AlertManager{
+		hits: newWindow(cfg.MonitorWindowSec / cfg.PollIntervalSec),
 	}
+	if cfg.HasRuleMetric(config.RuleMetricBytes) {
+		a.bandwidth = newWindow(cfg.MonitorWindowSec / cfg.PollIntervalSec)
+	}
+	for _, r := range cfg.AlertRules() {
+		a.rules = append(a.rules, newRuleState(r, cfg.PollIntervalSec))
+	}
+	if cfg.UniqueJump > 0 {
+		// the monitoring window in summary intervals, at least the previous one
+		n := cfg.MonitorWindowSec / cfg.SummaryIntervalSec
+		if n < 1 {
+			n = 1
+		}
+		a.unique = newWindow(n)
+		a.uniqueJump = cfg.UniqueJump
+	}
+	return a
 }
 
 // Start listens on the metric channel and sends the alert/clear alert/alerton and regular avg traffic messages to the printer
+// the unique metrics are only monitored if the unique clients alerting is on
 func (a *AlertManager) Start(metCh <-chan Metric, printCh chan<- printer.Formatter) {
 	alertOnPrinted := false
 	for m := range metCh {
-		a.add(m)
-		// regular avg traffic message, displayed only in verbose mode
-		printCh <- printer.NewMessage(fmt.Sprintf("\tAverage traffic: %d/s", a.AvgTraffic()))
-
-		if a.AlertOn() && !alertOnPrinted {
-			// we have all the needed data, let's inform the user about that
-			printCh <- printer.NewInfoMessage("All needed metrics are collected. Alerting is on")
-			alertOnPrinted = true
+		switch v := m.(type) {
+		case UniqueMetric:
+			if a.unique != nil {
+				a.addUnique(v, printCh)
+			}
+			continue
+		case BytesMetric:
+			if a.bandwidth != nil {
+				a.addBytes(v, printCh)
+			}
+		case CounterMetric:
+			a.add(v)
+			// regular avg traffic message, displayed only in verbose mode
+			printCh <- printer.NewMessage(fmt.Sprintf("\tAverage traffic: %d/s", a.AvgTraffic()))
+
+			if a.AlertOn() && !alertOnPrinted {
+				// we have all the needed data, let's inform the user about that
+				printCh <- printer.NewInfoMessage("All needed metrics are collected. Alerting is on")
+				alertOnPrinted = true
+			}
+		}
+		a.evaluate(m, printCh)
+	}
+}
+
+// evaluate adds the given metric to the rules evaluated on it and sends their alert/clear alert messages
+func (a *AlertManager) evaluate(m Metric, printCh chan<- printer.Formatter) {
+	for _, r := range a.rules {
+		if !r.add(m) {
+			continue
 		}
-		switch a.Alert() {
+		switch r.alert() {
 		case 1:
 			// fire the alert
-			printCh <- printer.NewAlertMessage(a.AvgTraffic(), m.Time())
+			printCh <- r.message(false, m.Time())
 		case -1:
 			// clear the alert message
-			printCh <- printer.NewClearAlertMessage(a.AvgTraffic(), m.Time())
+			printCh <- r.message(true, m.Time())
 		}
 	}
 }
 
+// addBytes adds the given bytes metric to the bandwidth stats
+func (a *AlertManager) addBytes(m BytesMetric, printCh chan<- printer.Formatter) {
+	a.bandwidth.add(m.bytes)
+	// regular avg bandwidth message, displayed only in verbose mode
+	printCh <- printer.NewMessage(fmt.Sprintf("\tAverage bandwidth: %s/s", printer.FormatBytes(a.AvgBandwidth())))
+}
+
+// addUnique adds the given unique clients metric to the unique clients stats and sends the unique clients alert/clear alert messages
+func (a *AlertManager) addUnique(m UniqueMetric, printCh chan<- printer.Formatter) {
+	// the interval is compared to the average of the previous ones
+	a.uniqueAvg = a.unique.avg()
+	a.lastUnique = m.unique
+	printCh <- printer.NewMessage(fmt.Sprintf("\tUnique clients: %d (average %d)", a.lastUnique, a.uniqueAvg))
+
+	switch a.UniqueAlert() {
+	case 1:
+		printCh <- printer.NewUniqueAlertMessage(a.lastUnique, a.uniqueAvg, m.Time())
+	case -1:
+		printCh <- printer.NewClearUniqueAlertMessage(a.lastUnique, a.uniqueAvg, m.Time())
+	}
+	a.unique.add(m.unique)
+}
+
 // AvgTraffic average traffic (hits per second) for the monitoring window
 func (a *AlertManager) AvgTraffic() int {
-	if a.sum == 0 && len(a.buf) == 0 {
+	return a.hits.avg()
+}
+
+// AvgBandwidth average bandwidth (bytes per second) for the monitoring window, 0 if no rule is evaluated on the bytes
+func (a *AlertManager) AvgBandwidth() int {
+	if a.bandwidth == nil {
 		return 0
 	}
-	// (x).5 will be rounded up to (x+1).0
-	return int(math.Round(float64(a.sum) / float64(len(a.buf))))
+	return a.bandwidth.avg()
 }
 
-// Alert returns 1 if the average traffic for the past monitoring window is higher than the threshold
-// returns -1 if the average traffic has decreased below the threshold
-// return 0 if no alerts/clear alerts need to be sent
-// Note: alerting is ON only if we have enough data (monitoring window)
-func (a *AlertManager) Alert() int {
-	if !a.AlertOn() {
-		// no alert until we get enough data
+// UniqueAlert returns 1 if the unique clients of the last summary interval reached the alerting factor times
+// their average over the monitoring window, returns -1 if they have decreased below it
+// return 0 if no alerts/clear alerts need to be sent or the unique clients alerting is off
+// Note: the alerting starts from the second summary interval, no alert is triggered while the average is 0
+func (a *AlertManager) UniqueAlert() int {
+	if a.unique == nil || len(a.unique.buf) == 0 {
 		return 0
 	}
+	high := a.uniqueAvg > 0 && float64(a.lastUnique) >= a.uniqueJump*float64(a.uniqueAvg)
+	return transition(&a.uniqueAlertTriggered, high)
+}
 
-	if !a.alertTriggered {
-		if a.AvgTraffic() >= a.threshold {
-			a.alertTriggered = true
-			// alert is to be triggered
-			return 1
-		}
-	} else {
-		if a.AvgTraffic() < a.threshold {
-			a.alertTriggered = false
-			// alert is to be cleared
-			return -1
-		}
+// transition updates the given alert state by the given threshold crossing
+// returns 1 if the alert is to be triggered, -1 if it's to be cleared, 0 if nothing changed
+func transition(triggered *bool, high bool) int {
+	if !*triggered && high {
+		*triggered = true
+		return 1
+	}
+	if *triggered && !high {
+		*triggered = false
+		return -1
 	}
 	// alert is not triggered but the traffic is still ok
 	// or
@@ -94,53 +165,63 @@ func (a *AlertManager) Alert() int {
 
 // AlertOn returns true if the alerting is ready (enough data is collected)
 func (a *AlertManager) AlertOn() bool {
-	return a.bufFull()
+	return a.hits.full()
 }
 
-// add adds the given metric to the stats collected by the alertmanager
-func (a *AlertManager) add(m Metric) {
-	// we know that we deal with the counter here
-	// as it's the only metric used in the whole program
-	cnt, _ := m.Value().(int)
+// add adds the given counter metric to the traffic stats collected by the alertmanager
+func (a *AlertManager) add(m CounterMetric) {
+	a.hits.add(m.count)
+}
 
-	if !a.fill(cnt) {
-		return
-	}
-	a.addCircular(cnt)
+// window stores the metric values of the monitoring window
+type window struct {
+	buf []int
+	ptr int
+	sum int
 }
 
-// bufFull returns true if the internal buffer is full
-func (a *AlertManager) bufFull() bool {
-	return len(a.buf) == cap(a.buf)
+// newWindow returns a new instance of window holding the given number of values
+func newWindow(size int) *window {
+	return &window{buf: make([]int, 0, size)}
 }
 
-func (a *AlertManager) getBuf() []int {
-	return a.buf
+// full returns true if the internal buffer is full
+func (w *window) full() bool {
+	return len(w.buf) == cap(w.buf)
 }
 
-// fill adds to the sum of all the hits
-// returns true if the window is filled
-func (a *AlertManager) fill(cnt int) bool {
-	if !a.bufFull() {
-		a.buf = append(a.buf, cnt)
-		a.sum += cnt
-		return false
+// avg returns the average of the values of the window
+func (w *window) avg() int {
+	if w.sum == 0 && len(w.buf) == 0 {
+		return 0
+	}
+	// (x).5 will be rounded up to (x+1).0
+	return int(math.Round(float64(w.sum) / float64(len(w.buf))))
+}
+
+// add adds the given value to the window
+// overriding the oldest one once the window is filled
+func (w *window) add(v int) {
+	if !w.full() {
+		w.buf = append(w.buf, v)
+		w.sum += v
+		return
 	}
-	return true
+	w.addCircular(v)
 }
 
 // addCircular adds to the sum in the circular manner
 // that is, it overrides the oldest items with the new ones
-func (a *AlertManager) addCircular(cnt int) {
-	prevCnt := a.buf[a.ptr]
-	a.sum -= prevCnt
-
-	a.buf[a.ptr] = cnt
-	a.sum += cnt
-	if a.ptr >= len(a.buf)-1 {
-		a.ptr = 0
+func (w *window) addCircular(v int) {
+	prev := w.buf[w.ptr]
+	w.sum -= prev
+
+	w.buf[w.ptr] = v
+	w.sum += v
+	if w.ptr >= len(w.buf)-1 {
+		w.ptr = 0
 	} else {
-		a.ptr++
+		w.ptr++
 	}
 }
 
@@ -173,3 +254,75 @@ func (c CounterMetric) Time() time.Time {
 func (c CounterMetric) Value() interface{} {
 	return c.count
 }
+
+// BytesMetric represents the number of bytes sent during one polling interval
+type BytesMetric struct {
+	bytes int
+	time  time.Time
+}
+
+// NewBytesMetric returns a new instance of BytesMetric
+func NewBytesMetric(b int, time time.Time) BytesMetric {
+	return BytesMetric{
+		bytes: b,
+		time:  time,
+	}
+}
+
+// Time returns exact time at which the metric was started to be collected
+func (b BytesMetric) Time() time.Time {
+	return b.time
+}
+
+// Value returns the number of bytes
+func (b BytesMetric) Value() interface{} {
+	return b.bytes
+}
+
+// UniqueMetric represents the number of the unique clients of one summary interval
+type UniqueMetric struct {
+	unique int
+	time   time.Time
+}
+
+// NewUniqueMetric returns a new instance of UniqueMetric
+func NewUniqueMetric(u int, time time.Time) UniqueMetric {
+	return UniqueMetric{
+		unique: u,
+		time:   time,
+	}
+}
+
+// Time returns exact time at which the metric was started to be collected
+func (u UniqueMetric) Time() time.Time {
+	return u.time
+}
+
+// Value returns the number of the unique clients
+func (u UniqueMetric) Value() interface{} {
+	return u.unique
+}
+
+// StatsMetric represents the stats of the log entries read during one polling interval
+type StatsMetric struct {
+	stats *Stats
+	time  time.Time
+}
+
+// NewStatsMetric returns a new instance of StatsMetric
+func NewStatsMetric(s *Stats, time time.Time) StatsMetric {
+	return StatsMetric{
+		stats: s,
+		time:  time,
+	}
+}
+
+// Time returns exact time at which the metric was started to be collected
+func (s StatsMetric) Time() time.Time {
+	return s.time
+}
+
+// Value returns the stats
+func (s StatsMetric) Value() interface{} {
+	return s.stats
+}
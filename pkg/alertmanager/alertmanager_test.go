@@ -13,8 +13,8 @@ import (
 const (
 	timeFormat        = "2006-01-02 15:04:05.000"
 	timeout           = time.Duration(1000) * time.Millisecond
-	alertPattern      = "High traffic generated an alert - hits"
-	clearAlertPattern = "High traffic alert cleared"
+	alertPattern      = "high_traffic: High traffic generated an alert - hits"
+	clearAlertPattern = "high_traffic: High traffic alert cleared"
 )
 
 func TestAlertManagerNominal(t *testing.T) {
@@ -135,3 +135,238 @@ loop2:
 		t.Fatalf("Got wrong clear alert message: %s", gotClearAlert.Format())
 	}
 }
+
+func TestAlertManagerBandwidth(t *testing.T) {
+	cfg := config.NewDefault()
+	cfg.PollIntervalSec = 1
+	cfg.MonitorWindowSec = 2
+	cfg.BandwidthThreshold = 1100
+	a := New(cfg)
+
+	metCh := make(chan Metric)
+	printCh := make(chan printer.Formatter)
+	go a.Start(metCh, printCh)
+
+	t1, _ := time.Parse(timeFormat, "2019-11-30 15:00:01.000")
+	t2, _ := time.Parse(timeFormat, "2019-11-30 15:00:02.000")
+	t3, _ := time.Parse(timeFormat, "2019-11-30 15:00:03.000")
+
+	t.Log("Filling the monitoring window")
+	metCh <- NewBytesMetric(1536, t1)
+	// skip avg bandwidth, no alert until the monitoring window is full
+	<-printCh
+
+	t.Log("Triggering bandwidth alert")
+	metCh <- NewBytesMetric(2048, t2)
+	<-printCh
+	gotAlert := <-printCh
+	alertRegExp := regexp.MustCompile(fmt.Sprintf(`\[ALERT\].*High bandwidth generated an alert - bandwidth = 1.8 KB/s, triggered at %s`, t2.Format(timeFormat)))
+	if !alertRegExp.MatchString(gotAlert.Format()) {
+		t.Fatalf("Got wrong bandwidth alert message: %s", gotAlert.Format())
+	}
+
+	t.Log("Lowering the bandwidth")
+	metCh <- NewBytesMetric(0, t3)
+	<-printCh
+	gotClearAlert := <-printCh
+	clearAlertRegExp := regexp.MustCompile(fmt.Sprintf(`\[CLEAR\].*High bandwidth alert cleared at %s. Current bandwidth = 1.0 KB/s`, t3.Format(timeFormat)))
+	if !clearAlertRegExp.MatchString(gotClearAlert.Format()) {
+		t.Fatalf("Got wrong bandwidth clear alert message: %s", gotClearAlert.Format())
+	}
+	close(metCh)
+}
+
+func TestAlertManagerUnique(t *testing.T) {
+	cfg := config.NewDefault()
+	cfg.SummaryIntervalSec = 10
+	cfg.MonitorWindowSec = 20
+	cfg.UniqueJump = 5
+	a := New(cfg)
+
+	metCh := make(chan Metric)
+	printCh := make(chan printer.Formatter)
+	go a.Start(metCh, printCh)
+
+	t1, _ := time.Parse(timeFormat, "2019-11-30 15:00:10.000")
+	t2, _ := time.Parse(timeFormat, "2019-11-30 15:00:20.000")
+	t3, _ := time.Parse(timeFormat, "2019-11-30 15:00:30.000")
+	t4, _ := time.Parse(timeFormat, "2019-11-30 15:00:40.000")
+
+	t.Log("Collecting the average unique clients")
+	metCh <- NewUniqueMetric(10, t1)
+	// skip unique clients stats, no alert for the first interval
+	<-printCh
+	metCh <- NewUniqueMetric(12, t2)
+	<-printCh
+
+	t.Log("Triggering unique clients alert")
+	metCh <- NewUniqueMetric(60, t3)
+	<-printCh
+	gotAlert := <-printCh
+	alertRegExp := regexp.MustCompile(fmt.Sprintf(`\[ALERT\].*Unique clients jumped 5.5x generated an alert - unique clients = 60 \(average 11\), triggered at %s`, t3.Format(timeFormat)))
+	if !alertRegExp.MatchString(gotAlert.Format()) {
+		t.Fatalf("Got wrong unique clients alert message: %s", gotAlert.Format())
+	}
+
+	t.Log("Lowering the unique clients")
+	metCh <- NewUniqueMetric(15, t4)
+	<-printCh
+	gotClearAlert := <-printCh
+	clearAlertRegExp := regexp.MustCompile(fmt.Sprintf(`\[CLEAR\].*Unique clients alert cleared at %s. Current unique clients = 15 \(average 36\)`, t4.Format(timeFormat)))
+	if !clearAlertRegExp.MatchString(gotClearAlert.Format()) {
+		t.Fatalf("Got wrong unique clients clear alert message: %s", gotClearAlert.Format())
+	}
+	close(metCh)
+}
+
+func TestAlertManagerRules(t *testing.T) {
+	cfg := config.NewDefault()
+	cfg.PollIntervalSec = 1
+	cfg.MonitorWindowSec = 2
+	cfg.Rules = []config.Rule{
+		{Name: "api_5xx", Metric: config.RuleMetricServerErrorRatio, Op: ">", Threshold: 0.2},
+		{Name: "slow", Metric: config.RuleMetricLatencyP99, WindowSec: 1, Op: ">=", Threshold: 500},
+		{Name: "api_down", Metric: config.RuleMetricSectionHits, Section: "/api", Op: "<", Threshold: 1},
+	}
+	a := New(cfg)
+
+	metCh := make(chan Metric)
+	printCh := make(chan printer.Formatter, 10)
+	go a.Start(metCh, printCh)
+
+	newStats := func(codes []int, latency time.Duration, section string) *Stats {
+		s := NewStats(cfg)
+		for _, c := range codes {
+			s.Add(c, latency, true, section)
+		}
+		return s
+	}
+	t1, _ := time.Parse(timeFormat, "2019-11-30 15:00:01.000")
+	t2, _ := time.Parse(timeFormat, "2019-11-30 15:00:02.000")
+	t3, _ := time.Parse(timeFormat, "2019-11-30 15:00:03.000")
+
+	testCases := []struct {
+		name     string
+		metric   Metric
+		expected []string
+	}{
+		{
+			name:     "Window not full",
+			metric:   NewStatsMetric(newStats([]int{200, 500, 500}, 10*time.Millisecond, "/api"), t1),
+			expected: []string{},
+		},
+		{
+			name:   "Alerts triggered",
+			metric: NewStatsMetric(newStats([]int{200, 200, 200}, time.Second, "/"), t2),
+			expected: []string{
+				fmt.Sprintf(`\[ALERT\] api_5xx: High 5xx ratio generated an alert - 5xx ratio = 33.3%%, triggered at %s`, t2.Format(timeFormat)),
+				fmt.Sprintf(`\[ALERT\] slow: High p99 latency generated an alert - p99 latency = 994.9 ms, triggered at %s`, t2.Format(timeFormat)),
+			},
+		},
+		{
+			name:   "Alerts cleared and triggered",
+			metric: NewStatsMetric(newStats([]int{200}, 0, "/"), t3),
+			expected: []string{
+				fmt.Sprintf(`\[CLEAR\] api_5xx: High 5xx ratio alert cleared at %s. Current 5xx ratio = 0.0%%`, t3.Format(timeFormat)),
+				fmt.Sprintf(`\[CLEAR\] slow: High p99 latency alert cleared at %s. Current p99 latency = 0.0 ms`, t3.Format(timeFormat)),
+				fmt.Sprintf(`\[ALERT\] api_down: Low traffic of /api generated an alert - /api hits = 0, triggered at %s`, t3.Format(timeFormat)),
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			metCh <- tc.metric
+			// the metric is handled once the next one is received
+			metCh <- NewUniqueMetric(0, tc.metric.Time())
+			if len(printCh) != len(tc.expected) {
+				t.Fatalf("Test case %q: expected %d messages, got %d", tc.name, len(tc.expected), len(printCh))
+			}
+			for _, e := range tc.expected {
+				got := (<-printCh).Format()
+				if !regexp.MustCompile(e).MatchString(got) {
+					t.Errorf("Test case %q: expected message matching %s, got %s", tc.name, e, got)
+				}
+			}
+		})
+	}
+	close(metCh)
+}
+
+func TestAlertManagerErrorRatio(t *testing.T) {
+	cfg := config.NewDefault()
+	cfg.PollIntervalSec = 1
+	cfg.MonitorWindowSec = 1
+	cfg.ErrorRatioThreshold = 0.5
+	cfg.ServerErrorRatioThreshold = 0.2
+	cfg.MinHits = 3
+	a := New(cfg)
+
+	metCh := make(chan Metric)
+	printCh := make(chan printer.Formatter, 10)
+	go a.Start(metCh, printCh)
+
+	newStats := func(codes ...int) *Stats {
+		s := NewStats(cfg)
+		for _, c := range codes {
+			s.Add(c, 0, false, "/")
+		}
+		return s
+	}
+	t1, _ := time.Parse(timeFormat, "2019-11-30 15:00:01.000")
+	t2, _ := time.Parse(timeFormat, "2019-11-30 15:00:02.000")
+	t3, _ := time.Parse(timeFormat, "2019-11-30 15:00:03.000")
+	t4, _ := time.Parse(timeFormat, "2019-11-30 15:00:04.000")
+
+	testCases := []struct {
+		name     string
+		metric   Metric
+		expected []string
+	}{
+		{
+			name:     "Below the minimum volume",
+			metric:   NewStatsMetric(newStats(500), t1),
+			expected: []string{},
+		},
+		{
+			name:   "Alerts triggered",
+			metric: NewStatsMetric(newStats(404, 404, 503), t2),
+			expected: []string{
+				fmt.Sprintf(`\[ALERT\] high_error_ratio: High error ratio generated an alert - error ratio = 100.0%%, triggered at %s`, t2.Format(timeFormat)),
+				fmt.Sprintf(`\[ALERT\] high_5xx_ratio: High 5xx ratio generated an alert - 5xx ratio = 33.3%%, triggered at %s`, t2.Format(timeFormat)),
+			},
+		},
+		{
+			name:   "Error ratio alert cleared",
+			metric: NewStatsMetric(newStats(200, 200, 200, 503), t3),
+			expected: []string{
+				fmt.Sprintf(`\[CLEAR\] high_error_ratio: High error ratio alert cleared at %s. Current error ratio = 25.0%%`, t3.Format(timeFormat)),
+			},
+		},
+		{
+			name:   "5xx ratio alert cleared below the minimum volume",
+			metric: NewStatsMetric(newStats(503), t4),
+			expected: []string{
+				fmt.Sprintf(`\[CLEAR\] high_5xx_ratio: High 5xx ratio alert cleared at %s. Current 5xx ratio = 100.0%%`, t4.Format(timeFormat)),
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			metCh <- tc.metric
+			// the metric is handled once the next one is received
+			metCh <- NewUniqueMetric(0, tc.metric.Time())
+			if len(printCh) != len(tc.expected) {
+				t.Fatalf("Test case %q: expected %d messages, got %d", tc.name, len(tc.expected), len(printCh))
+			}
+			for _, e := range tc.expected {
+				got := (<-printCh).Format()
+				if !regexp.MustCompile(e).MatchString(got) {
+					t.Errorf("Test case %q: expected message matching %s, got %s", tc.name, e, got)
+				}
+			}
+		})
+	}
+	close(metCh)
+}
@@ -0,0 +1,277 @@
+package alertmanager
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"httplogmonitor/pkg/config"
+	"httplogmonitor/pkg/printer"
+	"httplogmonitor/pkg/sketch"
+)
+
+// statsMetrics are the rule metrics evaluated on the stats of the log entries sent by the collector
+var statsMetrics = []string{
+	config.RuleMetricErrors,
+	config.RuleMetricErrorRatio,
+	config.RuleMetricServerErrorRatio,
+	config.RuleMetricLatencyP99,
+	config.RuleMetricSectionHits,
+}
+
+// ruleState is the state of an alert rule: the values of its metric over the window and whether its alert is triggered
+// the hits are taken from the counter metrics, the bytes from the bytes metrics and the other metrics from the stats metrics
+// the alerts of the rules on the stats metrics are not triggered while the hits of the window are below their minimum volume
+type ruleState struct {
+	rule config.Rule
+	// values are the counts of the polling intervals: hits, bytes, errors (of the ratios) or hits of the section
+	values *window
+	// hits are the hits of the polling intervals, only for the stats metrics
+	hits *window
+	// latencies are the latency histograms of the polling intervals, only for the latency
+	latencies *histogramWindow
+	triggered bool
+}
+
+// newRuleState returns the state of the given rule which window is counted in the given polling intervals
+func newRuleState(rule config.Rule, pollIntervalSec int) *ruleState {
+	size := rule.WindowSec / pollIntervalSec
+	r := &ruleState{rule: rule}
+	switch rule.Metric {
+	case config.RuleMetricHits, config.RuleMetricBytes:
+		r.values = newWindow(size)
+	case config.RuleMetricLatencyP99:
+		r.latencies = newHistogramWindow(size)
+		r.hits = newWindow(size)
+	default:
+		r.values = newWindow(size)
+		r.hits = newWindow(size)
+	}
+	return r
+}
+
+// add adds the value of the rule metric taken from the given metric
+// returns false if the rule is not evaluated on the given metric
+func (r *ruleState) add(m Metric) bool {
+	switch v := m.(type) {
+	case CounterMetric:
+		if r.rule.Metric != config.RuleMetricHits {
+			return false
+		}
+		r.values.add(v.count)
+	case BytesMetric:
+		if r.rule.Metric != config.RuleMetricBytes {
+			return false
+		}
+		r.values.add(v.bytes)
+	case StatsMetric:
+		switch r.rule.Metric {
+		case config.RuleMetricErrors, config.RuleMetricErrorRatio:
+			r.values.add(v.stats.Errors())
+		case config.RuleMetricServerErrorRatio:
+			r.values.add(v.stats.Classes[5])
+		case config.RuleMetricLatencyP99:
+			r.latencies.add(v.stats.Latency)
+		case config.RuleMetricSectionHits:
+			r.values.add(v.stats.Sections[r.rule.Section])
+		default:
+			return false
+		}
+		r.hits.add(v.stats.Hits)
+	default:
+		return false
+	}
+	return true
+}
+
+// full returns true if the values of the whole window are collected
+func (r *ruleState) full() bool {
+	if r.latencies != nil {
+		return r.latencies.full()
+	}
+	return r.values.full()
+}
+
+// value returns the rule metric over the window:
+// the average of the counts (per polling interval), the ratio or the latency percentile in milliseconds
+func (r *ruleState) value() float64 {
+	switch r.rule.Metric {
+	case config.RuleMetricLatencyP99:
+		return float64(r.latencies.merge().Quantile(0.99)) / float64(time.Millisecond)
+	case config.RuleMetricErrorRatio, config.RuleMetricServerErrorRatio:
+		if r.hits.sum == 0 {
+			return 0
+		}
+		return float64(r.values.sum) / float64(r.hits.sum)
+	}
+	if len(r.values.buf) == 0 {
+		return 0
+	}
+	return float64(r.values.sum) / float64(len(r.values.buf))
+}
+
+// alert returns 1 if the alert of the rule is to be triggered, -1 if it's to be cleared,
+// 0 if nothing changed or the window is not full yet
+// the alert is not triggered while the hits of the window are below the minimum volume but it's cleared
+func (r *ruleState) alert() int {
+	if !r.full() {
+		return 0
+	}
+	v := r.value()
+	var high bool
+	switch r.rule.Op {
+	case ">":
+		high = v > r.rule.Threshold
+	case ">=":
+		high = v >= r.rule.Threshold
+	case "<":
+		high = v < r.rule.Threshold
+	case "<=":
+		high = v <= r.rule.Threshold
+	}
+	if r.hits != nil && r.hits.sum < r.rule.MinHits {
+		// too few hits for the metric to be meaningful (1 error out of 2 hits)
+		high = false
+	}
+	if r.latencies != nil && r.latencies.count() == 0 {
+		// no latency given by the log format
+		high = false
+	}
+	return transition(&r.triggered, high)
+}
+
+// message returns the alert message (or the clearance one if clear is set) of the rule at the given time
+func (r *ruleState) message(clear bool, t time.Time) printer.Formatter {
+	title := "High "
+	if r.rule.Op == "<" || r.rule.Op == "<=" {
+		title = "Low "
+	}
+	var name, value string
+	v := r.value()
+	switch r.rule.Metric {
+	case config.RuleMetricHits:
+		title += "traffic"
+		name, value = "hits", formatCount(v)
+	case config.RuleMetricErrors:
+		title += "errors"
+		name, value = "errors", formatCount(v)
+	case config.RuleMetricErrorRatio:
+		title += "error ratio"
+		name, value = "error ratio", fmt.Sprintf("%.1f%%", v*100)
+	case config.RuleMetricServerErrorRatio:
+		title += "5xx ratio"
+		name, value = "5xx ratio", fmt.Sprintf("%.1f%%", v*100)
+	case config.RuleMetricBytes:
+		title += "bandwidth"
+		name, value = "bandwidth", printer.FormatBytes(int(math.Round(v)))+"/s"
+	case config.RuleMetricLatencyP99:
+		title += "p99 latency"
+		name, value = "p99 latency", fmt.Sprintf("%.1f ms", v)
+	case config.RuleMetricSectionHits:
+		title += "traffic of " + r.rule.Section
+		name, value = r.rule.Section+" hits", formatCount(v)
+	}
+	if clear {
+		return printer.NewClearAlertMessage(r.rule.Name, title, name, value, t)
+	}
+	return printer.NewAlertMessage(r.rule.Name, title, name, value, t)
+}
+
+// formatCount formats the given average count rounded to the integer
+// (x).5 is rounded up to (x+1)
+func formatCount(v float64) string {
+	return strconv.Itoa(int(math.Round(v)))
+}
+
+// histogramWindow stores the latency histograms of the window
+type histogramWindow struct {
+	buf  []*sketch.Histogram
+	ptr  int
+	size int
+}
+
+// newHistogramWindow returns a new instance of histogramWindow holding the given number of histograms
+func newHistogramWindow(size int) *histogramWindow {
+	return &histogramWindow{buf: make([]*sketch.Histogram, 0, size), size: size}
+}
+
+// full returns true if the internal buffer is full
+func (w *histogramWindow) full() bool {
+	return len(w.buf) == w.size
+}
+
+// add adds the given histogram to the window overriding the oldest one once the window is filled
+func (w *histogramWindow) add(h *sketch.Histogram) {
+	if !w.full() {
+		w.buf = append(w.buf, h)
+		return
+	}
+	w.buf[w.ptr] = h
+	w.ptr = (w.ptr + 1) % w.size
+}
+
+// count returns the number of the latencies of the whole window
+func (w *histogramWindow) count() int {
+	cnt := 0
+	for _, b := range w.buf {
+		cnt += b.Count()
+	}
+	return cnt
+}
+
+// merge returns the histogram of the whole window
+func (w *histogramWindow) merge() *sketch.Histogram {
+	h := sketch.NewHistogram()
+	for _, b := range w.buf {
+		h.Merge(b)
+	}
+	return h
+}
+
+// Stats are the stats of the log entries of one polling interval the alert rules are evaluated on
+type Stats struct {
+	Hits int
+	// Classes are the hits by status class: 2xx at 2, 5xx at 5, ... (0 for no response sent)
+	Classes [6]int
+	Latency *sketch.Histogram
+	// Sections are the hits of the sections of the rules only
+	Sections map[string]int
+}
+
+// NewStats returns the empty stats of a polling interval for the alert rules of the given configuration
+// returns nil if no rule is evaluated on the stats of the log entries
+func NewStats(cfg *config.Config) *Stats {
+	if !cfg.HasRuleMetric(statsMetrics...) {
+		return nil
+	}
+	s := &Stats{
+		Latency:  sketch.NewHistogram(),
+		Sections: map[string]int{},
+	}
+	for _, r := range cfg.AlertRules() {
+		if r.Metric == config.RuleMetricSectionHits {
+			s.Sections[r.Section] = 0
+		}
+	}
+	return s
+}
+
+// Add adds the log entry of the given status code, latency (if its log format gives it) and section to the stats
+func (s *Stats) Add(code int, latency time.Duration, hasLatency bool, section string) {
+	s.Hits++
+	if c := code / 100; c >= 0 && c < len(s.Classes) {
+		s.Classes[c]++
+	}
+	if hasLatency {
+		s.Latency.Add(latency)
+	}
+	if _, ok := s.Sections[section]; ok {
+		s.Sections[section]++
+	}
+}
+
+// Errors returns the client and server errors (4xx and 5xx)
+func (s *Stats) Errors() int {
+	return s.Classes[4] + s.Classes[5]
+}
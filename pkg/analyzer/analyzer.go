@@ -0,0 +1,89 @@
+package analyzer
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+
+	"httplogmonitor/pkg/collector"
+	"httplogmonitor/pkg/config"
+	"httplogmonitor/pkg/printer"
+	"httplogmonitor/pkg/reader"
+)
+
+// Analyzer reads complete log files (offline mode)
+// and reports the summary of the whole time range covered by the log entries
+// together with the breakdown summaries bucketed by the log entries' timestamps
+type Analyzer struct {
+	config *config.Config
+}
+
+// New returns a new instance of Analyzer
+func New(cfg *config.Config) *Analyzer {
+	return &Analyzer{
+		config: cfg,
+	}
+}
+
+// Start analyzes all the files matching the configured paths
+// in the order of their modification time (the rotated files first) so that the log entries are mostly ordered
+// and sends the final report and errors to printCh
+func (a *Analyzer) Start(printCh chan<- printer.Formatter) {
+	paths := reader.Glob(a.config.LogFilePaths)
+	if len(paths) == 0 {
+		printCh <- printer.NewErrorMessage(fmt.Sprintf("No log file matches %v", a.config.LogFilePaths))
+		return
+	}
+	sortByModTime(paths)
+
+	parser, err := collector.NewParser(a.config)
+	if err != nil {
+		printCh <- printer.NewErrorMessage(fmt.Sprintf("Wrong log format: %s", err))
+		return
+	}
+
+	rep := NewReport(parser, a.config.SummaryIntervalSec, a.config.TopSectionNum, a.config.TopCapacity, a.config.TrendIntervals)
+	for _, p := range paths {
+		if err := a.analyzeFile(p, rep); err != nil {
+			printCh <- printer.NewErrorMessage(fmt.Sprintf("Error reading log file %s: %s", p, err.Error()))
+		}
+	}
+
+	if rep.ParseErrors != 0 {
+		printCh <- printer.NewErrorMessage(fmt.Sprintf("Failed to parse %d log entries. First error: %s", rep.ParseErrors, rep.FirstParseError))
+	}
+	if rep.LateEntries != 0 {
+		printCh <- printer.NewErrorMessage(fmt.Sprintf("%d log entries were dated in intervals already reported, only counted in the total summary", rep.LateEntries))
+	}
+	rep.Finish()
+	printCh <- rep
+}
+
+// analyzeFile adds all the log entries of the given file to the report
+// gzipped files are decompressed on the fly
+func (a *Analyzer) analyzeFile(path string, rep *Report) error {
+	f, err := reader.OpenFile(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), reader.MaxLineSize)
+	for sc.Scan() {
+		rep.AddEntry(collector.LogEntry{Source: path, Line: sc.Text()})
+	}
+	return sc.Err()
+}
+
+// sortByModTime sorts the given paths by the modification time of their files, the ones failing to stat first
+func sortByModTime(paths []string) {
+	mod := make(map[string]int64, len(paths))
+	for _, p := range paths {
+		if fi, err := os.Stat(p); err == nil {
+			mod[p] = fi.ModTime().UnixNano()
+		}
+	}
+	sort.SliceStable(paths, func(i, j int) bool { return mod[paths[i]] < mod[paths[j]] })
+}
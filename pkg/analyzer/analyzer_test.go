@@ -0,0 +1,131 @@
+package analyzer
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"httplogmonitor/pkg/collector"
+	"httplogmonitor/pkg/config"
+	"httplogmonitor/pkg/printer"
+)
+
+const logTimeFormat = "2006-01-02 15:04:05 -0700"
+
+func TestAnalyzerNominal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "test")
+	if err != nil {
+		t.Skip("Failed to create the test directory: ", err)
+	}
+	defer os.RemoveAll(dir)
+
+	t.Log("Creating the plain and gzipped log files")
+	plain := `127.0.0.1 - james [09/May/2018:16:00:39 +0000] "GET /report HTTP/1.0" 200 123
+127.0.0.1 - james [09/May/2018:16:00:41 +0000] "POST /api/user HTTP/1.0" 500 123
+not a log entry
+`
+	if err := ioutil.WriteFile(dir+"/access.log", []byte(plain), 0644); err != nil {
+		t.Fatal("Failed to create the plain file: ", err)
+	}
+	f, err := os.Create(dir + "/access.log.1.gz")
+	if err != nil {
+		t.Fatal("Failed to create the gzipped file: ", err)
+	}
+	gz := gzip.NewWriter(f)
+	gz.Write([]byte(`127.0.0.1 - james [09/May/2018:15:59:58 +0000] "GET /report HTTP/1.0" 302 123
+127.0.0.1 - james [09/May/2018:16:00:42 +0000] "GET /report HTTP/1.0" 404 123
+`))
+	gz.Close()
+	f.Close()
+	// the rotated file is analyzed first
+	rotated := time.Now().Add(-time.Hour)
+	os.Chtimes(dir+"/access.log.1.gz", rotated, rotated)
+
+	cfg := config.NewDefault()
+	cfg.LogFilePaths = []string{dir + "/access.log*"}
+	a := New(cfg)
+
+	printCh := make(chan printer.Formatter, 5)
+	a.Start(printCh)
+	close(printCh)
+
+	t.Log("Checking the parse error")
+	if _, ok := (<-printCh).(printer.ErrorMessage); !ok {
+		t.Fatal("Error message expected")
+	}
+
+	t.Log("Checking the report")
+	rep, ok := (<-printCh).(*Report)
+	if !ok {
+		t.Fatal("Report expected")
+	}
+	if rep.Entries != 4 || rep.ParseErrors != 1 {
+		t.Fatalf("Expected 4 entries and 1 parse error, got %d and %d", rep.Entries, rep.ParseErrors)
+	}
+	expectedStart, _ := time.Parse(logTimeFormat, "2018-05-09 15:59:58 +0000")
+	expectedEnd, _ := time.Parse(logTimeFormat, "2018-05-09 16:00:42 +0000")
+	if !rep.Start.Equal(expectedStart) || !rep.End.Equal(expectedEnd) {
+		t.Fatalf("Expected time range %s - %s, got %s - %s", expectedStart, expectedEnd, rep.Start, rep.End)
+	}
+	if rep.Total.Sections.Count("/report") != 3 || rep.Total.Sections.Count("/api") != 1 {
+		t.Fatalf("Unexpected total sections %v", rep.Total.Sections)
+	}
+
+	expectedIntervals := []struct {
+		start string
+		hits  int
+	}{
+		{"2018-05-09 15:59:50 +0000", 1},
+		{"2018-05-09 16:00:30 +0000", 1},
+		{"2018-05-09 16:00:40 +0000", 2},
+	}
+	if len(rep.Intervals) != len(expectedIntervals) {
+		t.Fatalf("Expected %d intervals, got %d", len(expectedIntervals), len(rep.Intervals))
+	}
+	for i, ei := range expectedIntervals {
+		start, _ := time.Parse(logTimeFormat, ei.start)
+		if !rep.Intervals[i].Start.Equal(start) {
+			t.Fatalf("Expected interval %d start %s, got %s", i, start, rep.Intervals[i].Start)
+		}
+		if rep.Intervals[i].Hits != ei.hits {
+			t.Fatalf("Expected interval %d hits %d, got %d", i, ei.hits, rep.Intervals[i].Hits)
+		}
+	}
+}
+
+func TestReportIntervals(t *testing.T) {
+	rep := NewReport(collector.CLFParser{}, 10, 10, 100, 1)
+	start, _ := time.Parse(logTimeFormat, "2018-05-09 16:00:00 +0000")
+	line := func(tm time.Time) collector.LogEntry {
+		return collector.LogEntry{Line: `127.0.0.1 - james [` + tm.Format("02/Jan/2006:15:04:05 -0700") + `] "GET /report HTTP/1.0" 200 123`}
+	}
+
+	t.Log("Checking the summaries kept while the log entries are added")
+	for i := 0; i < 1000; i++ {
+		// out of order within an interval
+		rep.AddEntry(line(start.Add(time.Duration(i+1-2*(i%2)) * time.Second)))
+		if len(rep.buckets) > 2 || len(rep.prev) > 1 {
+			t.Fatalf("Expected at most 2 open and 1 previous summaries, got %d and %d", len(rep.buckets), len(rep.prev))
+		}
+	}
+	rep.AddEntry(line(start))
+	rep.Finish()
+
+	if rep.Entries != 1001 || rep.LateEntries != 1 {
+		t.Fatalf("Expected 1001 entries and 1 late one, got %d and %d", rep.Entries, rep.LateEntries)
+	}
+	if len(rep.Intervals) != 100 {
+		t.Fatalf("Expected 100 intervals, got %d", len(rep.Intervals))
+	}
+	for i, in := range rep.Intervals {
+		if !in.Start.Equal(start.Add(time.Duration(i*10)*time.Second)) || in.Hits != 10 {
+			t.Fatalf("Expected interval %d with 10 hits from %s, got %d from %s", i, start.Add(time.Duration(i*10)*time.Second), in.Hits, in.Start)
+		}
+	}
+	if strings.Contains(rep.Intervals[0].Output, "Trends compared to") || !strings.Contains(rep.Intervals[1].Output, "Trends compared to") {
+		t.Fatal("Expected the intervals compared to the previous one but the first one")
+	}
+}
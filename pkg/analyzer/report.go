@@ -0,0 +1,194 @@
+package analyzer
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"httplogmonitor/pkg/collector"
+)
+
+const timeFormat = "2006-01-02 15:04:05"
+
+// Interval represents the summary of one breakdown interval
+// formatted once the log entries are past the interval so that its summary is not kept
+type Interval struct {
+	Start time.Time
+	// Hits is the number of log entries of the interval
+	Hits int
+	// Output is the formatted summary of the interval
+	Output string
+}
+
+// Report represents the result of the analysis:
+// the summary of the whole time range and the summaries of each interval having log entries
+// the log entries are expected to be mostly ordered: an interval is closed (formatted)
+// once a log entry is dated one more interval after its end
+type Report struct {
+	Start     time.Time
+	End       time.Time
+	Entries   int
+	Total     *collector.Summary
+	Intervals []Interval
+	// ParseErrors is the number of log entries which failed to be parsed
+	ParseErrors     int
+	FirstParseError string
+	// LateEntries is the number of log entries dated in an interval already closed, they're only in the total summary
+	LateEntries int
+	intervalSec int
+	topNum      int
+	topCapacity int
+	trendNum    int
+	parser      collector.Parser
+	// summaries of the open intervals by the unix time of their start
+	buckets map[int64]*collector.Summary
+	// start of the first interval and of the next interval to be closed, not set until the first log entry
+	first int64
+	next  int64
+	// summaries of the last closed intervals the next ones are compared to by the unix time of their start
+	prev map[int64]*collector.Summary
+}
+
+// NewReport returns a new instance of Report parsing the log entries with the given parser
+// with given breakdown interval, limit for most hitted sections, number of the keys tracked by the top tables
+// and number of the previous intervals the intervals are compared to (0 for no trends)
+func NewReport(parser collector.Parser, intervalSec, top, capacity, trend int) *Report {
+	return &Report{
+		parser:      parser,
+		Total:       collector.NewSummary(top, capacity),
+		intervalSec: intervalSec,
+		topNum:      top,
+		topCapacity: capacity,
+		trendNum:    trend,
+		buckets:     map[int64]*collector.Summary{},
+		prev:        map[int64]*collector.Summary{},
+	}
+}
+
+// AddEntry parses the given log entry and adds it to the total summary and the summary of its interval
+// closing the intervals the log entries are past
+func (r *Report) AddEntry(e collector.LogEntry) {
+	msg, err := collector.ParseEntry(r.parser, e)
+	if err == collector.ErrDirective {
+		return
+	}
+	if err == nil && msg.Time.IsZero() {
+		// the log entries are bucketed by their time
+		err = errors.New("no time in log entry")
+	}
+	if err != nil {
+		if r.ParseErrors == 0 {
+			r.FirstParseError = fmt.Sprintf("%q: %s", e.Line, err)
+		}
+		r.ParseErrors++
+		return
+	}
+
+	r.Entries++
+	if r.Start.IsZero() || msg.Time.Before(r.Start) {
+		r.Start = msg.Time
+	}
+	if msg.Time.After(r.End) {
+		r.End = msg.Time
+	}
+	r.Total.Add(msg)
+
+	start := msg.Time.Truncate(time.Duration(r.intervalSec) * time.Second).Unix()
+	if r.Entries == 1 || start < r.next && len(r.Intervals) == 0 {
+		// no interval closed yet
+		r.first = start
+		r.next = start
+	}
+	if start < r.next {
+		r.LateEntries++
+		return
+	}
+	sum, ok := r.buckets[start]
+	if !ok {
+		sum = collector.NewSummary(r.topNum, r.topCapacity)
+		r.buckets[start] = sum
+	}
+	sum.Add(msg)
+
+	r.closeUntil(start - int64(r.intervalSec))
+}
+
+// Finish calculates the traffic and closes the remaining intervals once all the log entries are added
+func (r *Report) Finish() {
+	// the time range is inclusive: entries from the same second give 1 second range
+	r.Total.CalcTraffic(int(math.Floor(r.End.Sub(r.Start).Seconds())) + 1)
+
+	last := r.next
+	for start := range r.buckets {
+		if start > last {
+			last = start
+		}
+	}
+	r.closeUntil(last + int64(r.intervalSec))
+}
+
+// closeUntil closes the intervals in chronological order up to the one starting before the given time:
+// the interval summaries are compared to the ones of the previous intervals, formatted and dropped
+// only the summaries of the last intervals needed by the trends are kept
+// the intervals with no log entries are taken as empty, the ones before the first interval are not known
+func (r *Report) closeUntil(end int64) {
+	for ; r.next < end; r.next += int64(r.intervalSec) {
+		sum, ok := r.buckets[r.next]
+		if !ok {
+			continue
+		}
+		delete(r.buckets, r.next)
+
+		sum.CalcTraffic(r.intervalSec)
+		if r.trendNum != 0 {
+			prev := make([]*collector.Summary, 0, r.trendNum)
+			for k := r.trendNum; k >= 1; k-- {
+				start := r.next - int64(k*r.intervalSec)
+				if start < r.first {
+					continue
+				}
+				prev = append(prev, r.prev[start])
+			}
+			sum.SetTrend(prev)
+			r.prev[r.next] = sum
+			for start := range r.prev {
+				if start <= r.next-int64(r.trendNum*r.intervalSec) {
+					delete(r.prev, start)
+				}
+			}
+		}
+		r.Intervals = append(r.Intervals, Interval{
+			Start:  time.Unix(r.next, 0).In(r.Start.Location()),
+			Hits:   sum.Sum["hits"],
+			Output: sum.Format(),
+		})
+	}
+}
+
+// Format formats the report as the total summary followed by the summaries of all the intervals
+func (r Report) Format() string {
+	b := strings.Builder{}
+
+	if r.Entries == 0 {
+		b.WriteString("\n========== REPORT: no log entries ==========\n")
+		return b.String()
+	}
+
+	b.WriteString(fmt.Sprintf("\n========== REPORT: %d log entries from %s to %s ==========\n", r.Entries, r.Start.Format(timeFormat), r.End.Format(timeFormat)))
+	b.WriteString(r.Total.Format())
+
+	for _, i := range r.Intervals {
+		end := i.Start.Add(time.Duration(r.intervalSec) * time.Second)
+		b.WriteString(fmt.Sprintf("\n========== INTERVAL: %s - %s ==========\n", i.Start.Format(timeFormat), end.Format(timeFormat)))
+		b.WriteString(i.Output)
+	}
+
+	return b.String()
+}
+
+// Verbose returns false as the report is to be always displayed
+func (r Report) Verbose() bool {
+	return false
+}
@@ -0,0 +1,157 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock gives the current time and the tickers based on it
+type Clock interface {
+	Now() time.Time
+	NewTicker(d time.Duration) Ticker
+}
+
+// Ticker delivers the ticks of a clock at the given interval
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// New returns the wall clock
+func New() Clock {
+	return realClock{}
+}
+
+// realClock implements Clock using the wall clock
+type realClock struct{}
+
+// Now returns the current wall clock time
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+// NewTicker returns the standard ticker
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+// realTicker implements Ticker using the standard ticker
+type realTicker struct {
+	*time.Ticker
+}
+
+// C returns the channel of the standard ticker
+func (t realTicker) C() <-chan time.Time {
+	return t.Ticker.C
+}
+
+// Virtual is a clock which is moved forward explicitly,
+// for instance by the timestamps of the replayed log entries
+// the time is not set until the first move, the tickers start from it
+type Virtual struct {
+	mu      sync.Mutex
+	now     time.Time
+	tickers []*virtualTicker
+}
+
+// NewVirtual returns a new instance of the virtual clock with no time set
+func NewVirtual() *Virtual {
+	return &Virtual{}
+}
+
+// Now returns the current time of the virtual clock
+func (v *Virtual) Now() time.Time {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.now
+}
+
+// NewTicker returns a ticker delivering the ticks while the virtual clock moves forward
+func (v *Virtual) NewTicker(d time.Duration) Ticker {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	t := &virtualTicker{
+		c:    make(chan time.Time),
+		done: make(chan struct{}),
+		d:    d,
+	}
+	if !v.now.IsZero() {
+		t.next = v.now.Add(d)
+	}
+	v.tickers = append(v.tickers, t)
+	return t
+}
+
+// Advance moves the virtual clock forward to the given time
+// delivering all the ticks due in between in chronological order
+// blocks until every tick is received (or its ticker is stopped)
+// moving backward is ignored
+func (v *Virtual) Advance(to time.Time) {
+	for {
+		v.mu.Lock()
+		if v.now.IsZero() {
+			// first move: the tickers start from here
+			v.now = to
+			for _, t := range v.tickers {
+				t.next = to.Add(t.d)
+			}
+		}
+		// the earliest tick due
+		var due *virtualTicker
+		for _, t := range v.tickers {
+			if t.stopped() || t.next.After(to) {
+				continue
+			}
+			if due == nil || t.next.Before(due.next) {
+				due = t
+			}
+		}
+		if due == nil {
+			if to.After(v.now) {
+				v.now = to
+			}
+			v.mu.Unlock()
+			return
+		}
+		tm := due.next
+		v.now = tm
+		due.next = tm.Add(due.d)
+		v.mu.Unlock()
+
+		select {
+		case due.c <- tm:
+		case <-due.done:
+		}
+	}
+}
+
+// virtualTicker implements Ticker for the virtual clock
+type virtualTicker struct {
+	c    chan time.Time
+	done chan struct{}
+	once sync.Once
+	d    time.Duration
+	// next is the time of the next tick
+	next time.Time
+}
+
+// C returns the channel of the ticks
+func (t *virtualTicker) C() <-chan time.Time {
+	return t.c
+}
+
+// Stop stops delivering the ticks
+func (t *virtualTicker) Stop() {
+	t.once.Do(func() { close(t.done) })
+}
+
+// stopped returns true if the ticker is stopped
+func (t *virtualTicker) stopped() bool {
+	select {
+	case <-t.done:
+		return true
+	default:
+		return false
+	}
+}
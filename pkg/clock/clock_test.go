@@ -0,0 +1,71 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+const timeFormat = "2006-01-02 15:04:05"
+
+func TestVirtualNominal(t *testing.T) {
+	v := NewVirtual()
+	t1 := v.NewTicker(2 * time.Second)
+	t2 := v.NewTicker(5 * time.Second)
+	defer t1.Stop()
+
+	start, _ := time.Parse(timeFormat, "2019-11-30 15:00:00")
+	v.Advance(start)
+	if !v.Now().Equal(start) {
+		t.Fatalf("Expected time %s, got %s", start, v.Now())
+	}
+
+	type tick struct {
+		ticker int
+		time   string
+	}
+	ticks := make(chan tick)
+	go func() {
+		for {
+			select {
+			case tm := <-t1.C():
+				ticks <- tick{1, tm.Format(timeFormat)}
+			case tm := <-t2.C():
+				ticks <- tick{2, tm.Format(timeFormat)}
+				if tm.Sub(start) >= 5*time.Second {
+					// no more ticks from the second ticker
+					t2.Stop()
+				}
+			}
+		}
+	}()
+
+	end := start.Add(11 * time.Second)
+	go v.Advance(end)
+
+	expected := []tick{
+		{1, "2019-11-30 15:00:02"},
+		{1, "2019-11-30 15:00:04"},
+		{2, "2019-11-30 15:00:05"},
+		{1, "2019-11-30 15:00:06"},
+		{1, "2019-11-30 15:00:08"},
+		{1, "2019-11-30 15:00:10"},
+	}
+	for _, e := range expected {
+		select {
+		case got := <-ticks:
+			if got != e {
+				t.Fatalf("Expected tick %v, got %v", e, got)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out waiting for tick %v", e)
+		}
+	}
+
+	// the last tick is received before the clock is moved to the end
+	for i := 0; i < 100 && !v.Now().Equal(end); i++ {
+		time.Sleep(time.Millisecond)
+	}
+	if !v.Now().Equal(end) {
+		t.Fatalf("Expected time %s, got %s", end, v.Now())
+	}
+}
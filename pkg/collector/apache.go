@@ -0,0 +1,257 @@
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// apacheDirective describes how a directive of the log format is matched and stored into the log message
+type apacheDirective struct {
+	pattern string
+	// set is nil for the directives which are matched but not stored
+	set func(m *LogMessage, v string) error
+}
+
+// apacheDirectives are the directives of the log format without argument
+var apacheDirectives = map[byte]apacheDirective{
+	'h': {`\S+`, setRemoteAddr},
+	'a': {`\S+`, setRemoteAddr},
+	'l': {`\S+`, nil},
+	'u': {`\S+`, setApacheUser},
+	't': {`\[\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}\]`, setApacheTime},
+	'r': {`.*?`, setRequest},
+	'm': {`[A-Z]+`, setMethod},
+	'U': {`\S+`, setPath},
+	'H': {`\S+`, setProtocol},
+	's': {`\d{3}`, setCode},
+	'b': {`\d+|-`, setApacheBytes},
+	'B': {`\d+`, setBytes},
+	'O': {`\d+`, setBytes},
+	'D': {`\d+`, setDurationUnit(time.Microsecond)},
+	'T': {`\d+`, setDurationUnit(time.Second)},
+	'A': {`\S+`, nil},
+	'f': {`.*?`, nil},
+	'I': {`\d+`, nil},
+	'k': {`\d+`, nil},
+	'L': {`\S*`, nil},
+	'p': {`\d+`, nil},
+	'P': {`\d+`, nil},
+	'q': {`\S*`, nil},
+	'R': {`\S*`, nil},
+	'S': {`\d+`, nil},
+	'v': {`\S+`, nil},
+	'V': {`\S+`, nil},
+	'X': {`[X+-]`, nil},
+}
+
+// apacheTimeFormats are the supported formats of the %{format}t directive
+var apacheTimeFormats = map[string]apacheDirective{
+	"sec":  {`\d+`, setUnixTime(time.Second)},
+	"msec": {`\d+`, setUnixTime(time.Millisecond)},
+	"usec": {`\d+`, setUnixTime(time.Microsecond)},
+}
+
+// apacheDurationUnits are the supported units of the %{unit}T directive
+var apacheDurationUnits = map[string]time.Duration{
+	"s":  time.Second,
+	"ms": time.Millisecond,
+	"us": time.Microsecond,
+}
+
+// ApacheFormat is a parser of the log entries compiled from an Apache httpd LogFormat string
+type ApacheFormat struct {
+	regexp *regexp.Regexp
+	// setters of the stored directives in the order of the capturing groups
+	setters []func(m *LogMessage, v string) error
+}
+
+// CompileApacheFormat compiles the given Apache LogFormat string into a parser
+// the format must contain the status (%s, %>s) and either %r or %m with %U
+func CompileApacheFormat(format string) (*ApacheFormat, error) {
+	f := &ApacheFormat{}
+	seen := map[byte]bool{}
+	b := strings.Builder{}
+	b.WriteString("^")
+
+	for i := 0; i < len(format); i++ {
+		c := format[i]
+		if c == '\\' && i+1 < len(format) {
+			// escapes of the config file: \" \\ \t \n
+			i++
+			b.WriteString(regexp.QuoteMeta(unescapeApacheFormat(format[i])))
+			continue
+		}
+		if c != '%' {
+			b.WriteString(regexp.QuoteMeta(string(c)))
+			continue
+		}
+
+		// %[condition][<>]{argument}directive
+		i++
+		for i < len(format) && strings.IndexByte("!0123456789,<>", format[i]) != -1 {
+			i++
+		}
+		var arg string
+		if i < len(format) && format[i] == '{' {
+			end := strings.IndexByte(format[i:], '}')
+			if end == -1 {
+				return nil, errors.New("unterminated argument in apache log format")
+			}
+			arg = format[i+1 : i+end]
+			i += end + 1
+		}
+		if i >= len(format) {
+			return nil, errors.New("apache log format ends with a bare %")
+		}
+		if format[i] == '%' {
+			b.WriteString("%")
+			continue
+		}
+
+		d, err := apacheDirectiveOf(format[i], arg)
+		if err != nil {
+			return nil, err
+		}
+		if d.set == nil {
+			b.WriteString("(?:" + d.pattern + ")")
+			continue
+		}
+		b.WriteString("(" + d.pattern + ")")
+		f.setters = append(f.setters, d.set)
+		seen[format[i]] = true
+	}
+	b.WriteString("$")
+
+	if !seen['s'] {
+		return nil, errors.New("apache log format must contain %s or %>s")
+	}
+	if !seen['r'] && !(seen['m'] && seen['U']) {
+		return nil, errors.New("apache log format must contain %r or %m and %U")
+	}
+
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile apache log format: %s", err)
+	}
+	f.regexp = re
+	return f, nil
+}
+
+// apacheDirectiveOf returns the description of the given directive with its argument (if any)
+func apacheDirectiveOf(c byte, arg string) (apacheDirective, error) {
+	if len(arg) == 0 {
+		if d, ok := apacheDirectives[c]; ok {
+			return d, nil
+		}
+		return apacheDirective{}, fmt.Errorf("unsupported apache log format directive %%%c", c)
+	}
+
+	switch c {
+	case 'i':
+		// request headers
+		switch strings.ToLower(arg) {
+		case "referer":
+			return apacheDirective{`.*?`, setReferer}, nil
+		case "user-agent":
+			return apacheDirective{`.*?`, setUserAgent}, nil
+		}
+		return apacheDirective{`.*?`, nil}, nil
+	case 'o', 'e', 'n', 'C', 'x', '^':
+		// response headers, environment variables, notes, cookies and others
+		return apacheDirective{`.*?`, nil}, nil
+	case 'a', 'h':
+		return apacheDirectives[c], nil
+	case 'p', 'P':
+		return apacheDirective{`\S+`, nil}, nil
+	case 't':
+		// begin: and end: prefixes are only about the moment the time is taken at
+		arg = strings.TrimPrefix(strings.TrimPrefix(arg, "begin:"), "end:")
+		if len(arg) == 0 {
+			return apacheDirectives['t'], nil
+		}
+		if d, ok := apacheTimeFormats[arg]; ok {
+			return d, nil
+		}
+		return apacheDirective{}, fmt.Errorf("unsupported apache time format %%{%s}t", arg)
+	case 'T':
+		if u, ok := apacheDurationUnits[arg]; ok {
+			return apacheDirective{`\d+`, setDurationUnit(u)}, nil
+		}
+		return apacheDirective{}, fmt.Errorf("unsupported apache duration unit %%{%s}T", arg)
+	}
+	return apacheDirective{}, fmt.Errorf("unsupported apache log format directive %%{%s}%c", arg, c)
+}
+
+// unescapeApacheFormat returns the character escaped by a backslash in the log format
+func unescapeApacheFormat(c byte) string {
+	switch c {
+	case 't':
+		return "\t"
+	case 'n':
+		return "\n"
+	}
+	return string(c)
+}
+
+// Parse parses the given raw log entry according to the compiled format
+func (f *ApacheFormat) Parse(str string) (*LogMessage, error) {
+	m := f.regexp.FindStringSubmatch(str)
+	if m == nil {
+		return nil, errors.New("apache log format not matched")
+	}
+	msg := &LogMessage{}
+	for i, set := range f.setters {
+		if err := set(msg, m[i+1]); err != nil {
+			return nil, err
+		}
+	}
+	return msg, nil
+}
+
+func setApacheUser(m *LogMessage, v string) error {
+	m.User = dashToEmpty(unescapeQuoted(v))
+	return nil
+}
+
+// setApacheTime parses the time in the brackets: [10/Oct/2000:13:55:36 -0700]
+func setApacheTime(m *LogMessage, v string) error {
+	return setTimeLocal(m, v[1:len(v)-1])
+}
+
+// setApacheBytes parses the size of the response in the CLF format ("-" for no bytes)
+func setApacheBytes(m *LogMessage, v string) error {
+	if v == "-" {
+		m.Bytes = 0
+		return nil
+	}
+	return setBytes(m, v)
+}
+
+// setDurationUnit returns a setter of the latency given as an integer number of the given unit
+func setDurationUnit(unit time.Duration) func(m *LogMessage, v string) error {
+	return func(m *LogMessage, v string) error {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return errors.New("wrong request time format")
+		}
+		m.Latency = time.Duration(n) * unit
+		m.HasLatency = true
+		return nil
+	}
+}
+
+// setUnixTime returns a setter of the time given as an integer number of the given unit since the epoch
+func setUnixTime(unit time.Duration) func(m *LogMessage, v string) error {
+	return func(m *LogMessage, v string) error {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return errors.New("wrong time format")
+		}
+		m.Time = time.Unix(0, n*int64(unit))
+		return nil
+	}
+}
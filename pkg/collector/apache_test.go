@@ -0,0 +1,178 @@
+package collector
+
+import (
+	"testing"
+	"time"
+)
+
+const apacheTimedFormat = `%h %l %u %t \"%r\" %>s %b %D \"%{User-Agent}i\"`
+
+func TestCompileApacheFormat(t *testing.T) {
+	testCases := []struct {
+		name        string
+		format      string
+		expectedErr bool
+	}{
+		{
+			name:   "Common",
+			format: `%h %l %u %t "%r" %>s %b`,
+		},
+		{
+			name:   "Duration and user agent",
+			format: apacheTimedFormat,
+		},
+		{
+			name:   "Split request and ignored directives",
+			format: `%v:%p %a %{X-Forwarded-For}i %{sec}t "%m %U%q %H" %s %O %{ms}T %{UNIQUE_ID}e`,
+		},
+		{
+			name:   "Status condition",
+			format: `%h %l %u %t "%r" %>s %b "%!200,304{Referer}i"`,
+		},
+		{
+			name:        "Unsupported directive",
+			format:      `%h %l %u %t "%r" %>s %b %Z`,
+			expectedErr: true,
+		},
+		{
+			name:        "Unsupported time format",
+			format:      `%h %{%Y-%m-%d}t "%r" %>s`,
+			expectedErr: true,
+		},
+		{
+			name:        "No status",
+			format:      `%h %l %u %t "%r" %b`,
+			expectedErr: true,
+		},
+		{
+			name:        "No request",
+			format:      `%h %l %u %t "%m" %>s %b`,
+			expectedErr: true,
+		},
+		{
+			name:        "Unterminated argument",
+			format:      `%h %t "%r" %>s %{User-Agent`,
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := CompileApacheFormat(tc.format)
+			if err != nil {
+				if !tc.expectedErr {
+					t.Errorf("Test case %q got not expected error: %s", tc.name, err)
+				}
+				return
+			}
+			if tc.expectedErr {
+				t.Errorf("Test case %q got no error while one is expected", tc.name)
+			}
+		})
+	}
+}
+
+func TestApacheFormatParse(t *testing.T) {
+	testCases := []struct {
+		name        string
+		format      string
+		input       string
+		expected    LogMessage
+		expectedErr bool
+	}{
+		{
+			name:   "Common",
+			format: `%h %l %u %t "%r" %>s %b`,
+			input:  `127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326`,
+			expected: LogMessage{
+				Section:    "/apache_pb.gif",
+				Method:     "GET",
+				Code:       200,
+				Time:       mustParseTime("10/Oct/2000:13:55:36 -0700"),
+				Protocol:   "HTTP/1.0",
+				RemoteAddr: "127.0.0.1",
+				User:       "frank",
+				Bytes:      2326,
+			},
+		},
+		{
+			name:   "Duration and user agent",
+			format: apacheTimedFormat,
+			input:  `10.0.0.1 - - [09/May/2018:16:00:39 +0000] "POST /api/user HTTP/1.1" 500 - 1250 "Mozilla/5.0 (X11; Linux x86_64)"`,
+			expected: LogMessage{
+				Section:    "/api",
+				Method:     "POST",
+				Code:       500,
+				Time:       mustParseTime("09/May/2018:16:00:39 +0000"),
+				Protocol:   "HTTP/1.1",
+				RemoteAddr: "10.0.0.1",
+				UserAgent:  "Mozilla/5.0 (X11; Linux x86_64)",
+				Latency:    1250 * time.Microsecond,
+				HasLatency: true,
+			},
+		},
+		{
+			name:   "Escaped quotes",
+			format: apacheTimedFormat,
+			input:  `10.0.0.1 - - [09/May/2018:16:00:39 +0000] "GET /search?q=\"a\" HTTP/1.1" 200 12 30 "agent \"quoted\""`,
+			expected: LogMessage{
+				Section:    "/search",
+				Method:     "GET",
+				Code:       200,
+				Time:       mustParseTime("09/May/2018:16:00:39 +0000"),
+				Protocol:   "HTTP/1.1",
+				RemoteAddr: "10.0.0.1",
+				Bytes:      12,
+				UserAgent:  `agent "quoted"`,
+				Latency:    30 * time.Microsecond,
+				HasLatency: true,
+			},
+		},
+		{
+			name:   "Split request and ignored directives",
+			format: `%v:%p %a %{X-Forwarded-For}i %{sec}t "%m %U%q %H" %s %O %{ms}T %{UNIQUE_ID}e`,
+			input:  `example.com:443 10.0.0.1 1.2.3.4, 5.6.7.8 1525881639 "GET /users/1?full=1 HTTP/2.0" 200 512 42 XfPz1`,
+			expected: LogMessage{
+				Section:    "/users",
+				Method:     "GET",
+				Code:       200,
+				Time:       mustParseTime("09/May/2018:16:00:39 +0000"),
+				Protocol:   "HTTP/2.0",
+				RemoteAddr: "10.0.0.1",
+				Bytes:      512,
+				Latency:    42 * time.Millisecond,
+				HasLatency: true,
+			},
+		},
+		{
+			name:        "Format not matched",
+			format:      apacheTimedFormat,
+			input:       `127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326`,
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			f, err := CompileApacheFormat(tc.format)
+			if err != nil {
+				t.Fatalf("Test case %q failed to compile format: %s", tc.name, err)
+			}
+			output, err := f.Parse(tc.input)
+			if err != nil {
+				if !tc.expectedErr {
+					t.Errorf("Test case %q got not expected error: %s", tc.name, err)
+				}
+				return
+			}
+			if tc.expectedErr {
+				t.Errorf("Test case %q got no error while one is expected", tc.name)
+				return
+			}
+
+			if !output.Equal(&tc.expected) {
+				t.Errorf("Test case %q: output didn't match, got %+v", tc.name, *output)
+			}
+		})
+	}
+}
@@ -0,0 +1,209 @@
+package collector
+
+import (
+	"errors"
+	"math"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AWS Application Load Balancer log entry example (the trailing fields are omitted):
+// http 2018-07-02T22:23:00.186641Z app/my-lb/50dc6c495c0c9188 192.168.131.39:2817 10.0.0.1:80 0.000 0.001 0.000 200 200 34 366 "GET http://www.example.com:80/ HTTP/1.1" "curl/7.46.0" - - ...
+// Classic Load Balancer log entry example (no type):
+// 2015-05-13T23:39:43.945958Z my-loadbalancer 192.168.131.39:2817 10.0.0.1:80 0.000073 0.001048 0.000057 200 200 0 29 "GET http://www.example.com:80/ HTTP/1.1" "curl/7.38.0" - -
+
+// indexes of the fields of the load balancer log entries without the type
+const (
+	albTime = iota
+	albELB
+	albClient
+	albTarget
+	albRequestTime
+	albTargetTime
+	albResponseTime
+	albELBStatus
+	albTargetStatus
+	albReceivedBytes
+	albSentBytes
+	albRequest
+	albUserAgent
+	// albMinFields is the number of the fields needed, the following ones are ignored
+	albMinFields
+)
+
+// ALBParser is a parser of the AWS Application (and Classic) Load Balancer access log entries
+// the status is the one returned by the load balancer, the target's one is the upstream status
+// the latency is the sum of the request, target and response processing times
+type ALBParser struct{}
+
+// Parse parses the given load balancer log entry
+func (ALBParser) Parse(str string) (*LogMessage, error) {
+	f, err := splitQuoted(str)
+	if err != nil {
+		return nil, err
+	}
+	// the application load balancer's entries start with the type of the request (http, https, h2, ws, ...)
+	if len(f) != 0 && len(f[0]) != 0 && (f[0][0] < '0' || f[0][0] > '9') {
+		f = f[1:]
+	}
+	if len(f) < albMinFields {
+		return nil, errors.New("alb log entry format not matched")
+	}
+
+	msg := &LogMessage{}
+	msg.Time, err = time.Parse(time.RFC3339Nano, f[albTime])
+	if err != nil {
+		return nil, errors.New("wrong time format")
+	}
+
+	msg.RemoteAddr = f[albClient]
+	if host, _, err := net.SplitHostPort(f[albClient]); err == nil {
+		msg.RemoteAddr = host
+	}
+
+	if err := msg.parseAbsoluteRequest(f[albRequest]); err != nil {
+		return nil, err
+	}
+
+	if err := msg.parseCode(f[albELBStatus]); err != nil {
+		return nil, err
+	}
+	// no target status if the request wasn't forwarded
+	if f[albTargetStatus] != "-" {
+		msg.UpstreamCode, err = strconv.Atoi(f[albTargetStatus])
+		if err != nil {
+			return nil, errors.New("wrong target status format")
+		}
+	}
+
+	msg.Bytes, err = strconv.Atoi(f[albSentBytes])
+	if err != nil {
+		return nil, err
+	}
+
+	for _, i := range []int{albRequestTime, albTargetTime, albResponseTime} {
+		d, err := parseALBSeconds(f[i])
+		if err != nil {
+			return nil, err
+		}
+		msg.Latency += d
+		if i == albTargetTime {
+			msg.UpstreamLatency = d
+		}
+		// the times are -1 if the request couldn't be dispatched
+		if !strings.HasPrefix(f[i], "-") {
+			msg.HasLatency = true
+		}
+	}
+
+	msg.UserAgent = dashToEmpty(f[albUserAgent])
+	return msg, nil
+}
+
+// parseAbsoluteRequest extracts the method, section and protocol from the given request line with an absolute url
+func (m *LogMessage) parseAbsoluteRequest(req string) error {
+	r := strings.Split(req, " ")
+	if len(r) != 3 {
+		return errors.New("method and url format not matched")
+	}
+	u, err := url.Parse(r[1])
+	if err != nil || len(r[0]) == 0 || r[0] == "-" {
+		return errors.New("method and url format not matched")
+	}
+	m.Method = r[0]
+	m.Protocol = r[2]
+	// the path is decoded by parsePath
+	path := u.EscapedPath()
+	if len(path) == 0 {
+		path = "/"
+	}
+	return m.parsePath(path)
+}
+
+// parseALBSeconds parses the processing time in seconds with the microsecond resolution
+// -1 (the request wasn't forwarded or the connection was closed) is taken as no time
+func parseALBSeconds(str string) (time.Duration, error) {
+	f, err := strconv.ParseFloat(str, 64)
+	if err != nil {
+		return 0, errors.New("wrong processing time format")
+	}
+	if f < 0 {
+		return 0, nil
+	}
+	return time.Duration(math.Round(f*1e6)) * time.Microsecond, nil
+}
+
+// splitQuoted splits the given string by the spaces keeping the double quoted fields together (unquoted)
+func splitQuoted(str string) ([]string, error) {
+	var fields []string
+	for i := 0; i < len(str); i++ {
+		if str[i] == ' ' {
+			continue
+		}
+		if str[i] != '"' {
+			j := strings.IndexByte(str[i:], ' ')
+			if j == -1 {
+				j = len(str) - i
+			}
+			fields = append(fields, str[i:i+j])
+			i += j
+			continue
+		}
+		b := strings.Builder{}
+		closed := false
+		for i++; i < len(str); i++ {
+			if str[i] == '\\' && i+1 < len(str) {
+				i++
+			} else if str[i] == '"' {
+				closed = true
+				break
+			}
+			b.WriteByte(str[i])
+		}
+		if !closed {
+			return nil, errors.New("unterminated quoted field")
+		}
+		fields = append(fields, b.String())
+	}
+	return fields, nil
+}
+
+// cloudFrontFields are the fields of the CloudFront standard logs, they are given by the #Fields directive too
+const cloudFrontFields = "date time x-edge-location sc-bytes c-ip cs-method cs(Host) cs-uri-stem sc-status cs(Referer) cs(User-Agent) cs-uri-query cs(Cookie) x-edge-result-type x-edge-request-id x-host-header cs-protocol cs-bytes time-taken x-forwarded-for ssl-protocol ssl-cipher x-edge-response-result-type cs-protocol-version fle-status fle-encrypted-fields c-port time-to-first-byte x-edge-detailed-result-type sc-content-type sc-content-len sc-range-start sc-range-end"
+
+// NewCloudFrontParser returns a parser of the CloudFront standard logs (tab separated W3C extended log file format)
+// the url encoded referrers and user agents are decoded
+func NewCloudFrontParser() *W3CExtendedParser {
+	setters := map[string]func(m *LogMessage, v string) error{}
+	for k, v := range w3cSetters {
+		setters[k] = v
+	}
+	setters["cs(referer)"] = setCloudFrontReferer
+	setters["cs(user-agent)"] = setCloudFrontUserAgent
+	setters["cs-protocol-version"] = setProtocol
+
+	// the default fields contain the mandatory ones
+	p, _ := newW3CExtendedParser(cloudFrontFields, setters)
+	return p
+}
+
+func setCloudFrontReferer(m *LogMessage, v string) error {
+	m.Referer = urlUnescape(v)
+	return nil
+}
+
+func setCloudFrontUserAgent(m *LogMessage, v string) error {
+	m.UserAgent = urlUnescape(v)
+	return nil
+}
+
+// urlUnescape decodes the given url encoded string, it's returned as is if it's not well encoded
+func urlUnescape(str string) string {
+	if u, err := url.PathUnescape(str); err == nil {
+		return u
+	}
+	return str
+}
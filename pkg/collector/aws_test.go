@@ -0,0 +1,218 @@
+package collector
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAWSParsers(t *testing.T) {
+	testCases := []struct {
+		name     string
+		parser   Parser
+		file     string
+		expected []LogMessage
+	}{
+		{
+			name:   "Application load balancer",
+			parser: ALBParser{},
+			file:   "alb.log",
+			expected: []LogMessage{
+				{
+					Section:         "/",
+					Method:          "GET",
+					Protocol:        "HTTP/1.1",
+					Code:            200,
+					UpstreamCode:    200,
+					Time:            mustParseRFC3339("2018-07-02T22:23:00.186641Z"),
+					RemoteAddr:      "192.168.131.39",
+					Bytes:           366,
+					UserAgent:       "curl/7.46.0",
+					Latency:         time.Millisecond,
+					HasLatency:      true,
+					UpstreamLatency: time.Millisecond,
+				},
+				{
+					Section:         "/api",
+					Method:          "GET",
+					Protocol:        "HTTP/1.1",
+					Code:            200,
+					UpstreamCode:    200,
+					Time:            mustParseRFC3339("2018-07-02T22:23:00.186641Z"),
+					RemoteAddr:      "192.168.131.39",
+					Bytes:           57,
+					UserAgent:       "Mozilla/5.0 (X11; Linux x86_64)",
+					Latency:         171 * time.Millisecond,
+					HasLatency:      true,
+					UpstreamLatency: 48 * time.Millisecond,
+				},
+				{
+					Section:         "/api",
+					Method:          "POST",
+					Protocol:        "HTTP/2.0",
+					Code:            502,
+					UpstreamCode:    502,
+					Time:            mustParseRFC3339("2018-07-02T22:23:01.001Z"),
+					RemoteAddr:      "10.0.1.252",
+					Bytes:           257,
+					UserAgent:       "curl/7.46.0",
+					Latency:         2 * time.Millisecond,
+					HasLatency:      true,
+					UpstreamLatency: 2 * time.Millisecond,
+				},
+				{
+					Section:    "/static",
+					Method:     "GET",
+					Protocol:   "HTTP/1.1",
+					Code:       503,
+					Time:       mustParseRFC3339("2018-07-02T22:23:02.5Z"),
+					RemoteAddr: "192.168.131.40",
+					Bytes:      366,
+				},
+			},
+		},
+		{
+			name:   "Classic load balancer",
+			parser: ALBParser{},
+			file:   "elb.log",
+			expected: []LogMessage{
+				{
+					Section:         "/",
+					Method:          "GET",
+					Protocol:        "HTTP/1.1",
+					Code:            200,
+					UpstreamCode:    200,
+					Time:            mustParseRFC3339("2015-05-13T23:39:43.945958Z"),
+					RemoteAddr:      "192.168.131.39",
+					Bytes:           29,
+					UserAgent:       "curl/7.38.0",
+					Latency:         1178 * time.Microsecond,
+					HasLatency:      true,
+					UpstreamLatency: 1048 * time.Microsecond,
+				},
+				{
+					Section:         "/images",
+					Method:          "GET",
+					Protocol:        "HTTP/1.1",
+					Code:            404,
+					UpstreamCode:    404,
+					Time:            mustParseRFC3339("2015-05-13T23:39:44.945958Z"),
+					RemoteAddr:      "192.168.131.39",
+					Bytes:           57,
+					UserAgent:       "curl/7.38.0",
+					Latency:         2471 * time.Microsecond,
+					HasLatency:      true,
+					UpstreamLatency: 1048 * time.Microsecond,
+				},
+			},
+		},
+		{
+			name:   "CloudFront",
+			parser: NewCloudFrontParser(),
+			file:   "cloudfront.log",
+			expected: []LogMessage{
+				{
+					Section:    "/index.html",
+					Method:     "GET",
+					Protocol:   "HTTP/2.0",
+					Code:       200,
+					Time:       mustParseRFC3339("2019-12-04T21:02:31Z"),
+					RemoteAddr: "192.0.2.100",
+					Bytes:      392,
+					UserAgent:  "Mozilla/5.0 (Windows NT 10.0; Win64; x64)",
+					Latency:    time.Millisecond,
+					HasLatency: true,
+				},
+				{
+					Section:    "/api",
+					Method:     "GET",
+					Protocol:   "HTTP/1.1",
+					Code:       404,
+					Time:       mustParseRFC3339("2019-12-04T21:02:31Z"),
+					RemoteAddr: "192.0.2.100",
+					Bytes:      392,
+					Referer:    "https://www.example.com/",
+					UserAgent:  "curl/7.58.0",
+					Latency:    250 * time.Millisecond,
+					HasLatency: true,
+				},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			output := parseTestFile(t, tc.parser, tc.file)
+			if len(output) != len(tc.expected) {
+				t.Fatalf("Test case %q: expected %d log messages, got %d", tc.name, len(tc.expected), len(output))
+			}
+			for i := range output {
+				if !output[i].Equal(&tc.expected[i]) {
+					t.Errorf("Test case %q: log message %d didn't match, got %+v", tc.name, i, *output[i])
+				}
+			}
+		})
+	}
+}
+
+func TestALBParserErrors(t *testing.T) {
+	testCases := []struct {
+		name  string
+		input string
+	}{
+		{
+			name:  "Common log format",
+			input: `127.0.0.1 - james [09/May/2018:16:00:39 +0000] "GET /report HTTP/1.0" 200 123`,
+		},
+		{
+			name:  "Unterminated quote",
+			input: `http 2018-07-02T22:23:00.186641Z app/my-lb 1.2.3.4:1 10.0.0.1:80 0.000 0.001 0.000 200 200 34 366 "GET http://www.example.com:80/ HTTP/1.1`,
+		},
+		{
+			name:  "Malformed request",
+			input: `http 2018-07-02T22:23:00.186641Z app/my-lb 1.2.3.4:1 - -1 -1 -1 400 - 0 0 "- - - " "-" - -`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := (ALBParser{}).Parse(tc.input); err == nil {
+				t.Errorf("Test case %q got no error while one is expected", tc.name)
+			}
+		})
+	}
+}
+
+// parseTestFile parses all the log entries of the given file of testdata skipping the directives
+func parseTestFile(t *testing.T, p Parser, file string) []*LogMessage {
+	path := filepath.Join("testdata", file)
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open test file: %s", err)
+	}
+	defer f.Close()
+
+	var output []*LogMessage
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		msg, err := ParseEntry(p, LogEntry{Source: path, Line: sc.Text()})
+		if err == ErrDirective {
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Failed to parse %q: %s", sc.Text(), err)
+		}
+		output = append(output, msg)
+	}
+	return output
+}
+
+func mustParseRFC3339(s string) time.Time {
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
@@ -4,46 +4,202 @@ import (
 	"fmt"
 	"time"
 
+	alert "httplogmonitor/pkg/alertmanager"
+	"httplogmonitor/pkg/clock"
 	"httplogmonitor/pkg/config"
+	"httplogmonitor/pkg/entry"
 	"httplogmonitor/pkg/printer"
 )
 
+// LogEntry represents a raw log entry together with its source
+// it's defined in the entry package so that the readers don't depend on the collector
+type LogEntry = entry.LogEntry
+
 // Collector stores the summary stats for the given summary interval
 type Collector struct {
 	config *config.Config
+	clock  clock.Clock
 	sum    *Summary
+	parser Parser
+	// counter of the hits by the log entries' timestamps, only in the event time mode
+	events *eventCounter
+	// bytes sent during the current polling interval, only if the bandwidth is monitored in the read time mode
+	bytes int
+	// bandwidth is set if an alert rule is evaluated on the bytes sent
+	bandwidth bool
+	// stats of the log entries of the current polling interval, nil if no alert rule is evaluated on them
+	stats *alert.Stats
+	// unique clients and visitors of the rolling hour and day
+	uniques *uniqueWindows
+	// previous summaries the trends are calculated from, the latest last
+	history []*Summary
+	// aggregation windows summarized after every interval summary
+	windows []*summaryWindow
 }
 
 // New returns a new instance of Collector
-func New(cfg *config.Config) *Collector {
-	return &Collector{
-		config: cfg,
-		sum:    NewSummary(cfg.TopSectionNum),
+// fails if the configured log format cannot be parsed
+func New(cfg *config.Config) (*Collector, error) {
+	return NewWithClock(cfg, clock.New())
+}
+
+// NewWithClock returns a new instance of Collector
+// which summary interval is measured by the given clock
+func NewWithClock(cfg *config.Config, clk clock.Clock) (*Collector, error) {
+	parser, err := NewParser(cfg)
+	if err != nil {
+		return nil, err
+	}
+	windows, err := newSummaryWindows(cfg)
+	if err != nil {
+		return nil, err
+	}
+	c := &Collector{
+		config:    cfg,
+		clock:     clk,
+		sum:       NewSummary(cfg.TopSectionNum, cfg.TopCapacity),
+		parser:    parser,
+		uniques:   newUniqueWindows(),
+		windows:   windows,
+		bandwidth: cfg.HasRuleMetric(config.RuleMetricBytes),
+		stats:     alert.NewStats(cfg),
 	}
+	if cfg.EventTime {
+		c.events = newEventCounter(time.Duration(cfg.PollIntervalSec)*time.Second, time.Duration(cfg.LatenessSec)*time.Second, time.Duration(cfg.MonitorWindowSec)*time.Second, c.bandwidth)
+	}
+	return c, nil
 }
 
 // Start collects the log message statistics (most hitted sections and some interesting info)
 // and sends it to the printer every summary interval
-func (c *Collector) Start(logCh <-chan string, printCh chan<- printer.Formatter) {
-	tick := time.NewTicker(time.Duration(c.config.SummaryIntervalSec) * time.Second)
+// in the event time mode the hits are also counted by the log entries' timestamps
+// and the counter metrics are sent to metCh once their polling intervals are complete
+// if the bandwidth is monitored the bytes metrics are sent to metCh every polling interval
+// (or once their polling intervals are complete in the event time mode)
+// if alert rules are evaluated on the stats of the log entries (errors, latency, ...)
+// the stats metrics are sent to metCh every polling interval (by the read time)
+// if the unique clients are monitored their number is sent to metCh every summary interval
+// returns once logCh is closed sending the summary of the last (incomplete) interval
+func (c *Collector) Start(logCh <-chan LogEntry, metCh chan<- alert.Metric, printCh chan<- printer.Formatter) {
+	tick := c.clock.NewTicker(time.Duration(c.config.SummaryIntervalSec) * time.Second)
 	defer tick.Stop()
 
+	// nil channel blocks forever: no event time counting nor bandwidth and stats monitoring
+	var pollCh <-chan time.Time
+	if c.events != nil || c.bandwidth || c.stats != nil {
+		pollTick := c.clock.NewTicker(time.Duration(c.config.PollIntervalSec) * time.Second)
+		defer pollTick.Stop()
+		pollCh = pollTick.C()
+	}
+
 	for {
 		select {
-		case <-tick.C:
+		case t := <-tick.C():
 			// time to print the summary
-			c.sum.CalcTraffic(c.config.SummaryIntervalSec)
-			printCh <- *c.sum
-			c.sum = NewSummary(c.config.TopSectionNum)
-		case l := <-logCh:
+			c.flush(t, metCh, printCh)
+		case t := <-pollCh:
+			if c.events != nil {
+				c.sendMetrics(c.events.flush(t), metCh, printCh)
+			} else if c.bandwidth {
+				c.sendBytes(t, metCh)
+			}
+			if c.stats != nil {
+				c.sendStats(t, metCh)
+			}
+		case e, ok := <-logCh:
+			if !ok {
+				if c.events != nil {
+					c.sendMetrics(c.events.flushAll(), metCh, printCh)
+				} else if c.bandwidth {
+					c.sendBytes(c.clock.Now(), metCh)
+				}
+				if c.stats != nil {
+					c.sendStats(c.clock.Now(), metCh)
+				}
+				c.flush(c.clock.Now(), metCh, printCh)
+				return
+			}
+			if e.Sync != nil {
+				close(e.Sync)
+				break
+			}
 			// transform raw log entries into log messages
-			msg, err := NewLogMessageFromLogEntry(l)
+			msg, err := ParseEntry(c.parser, e)
+			if err == ErrDirective {
+				break
+			}
 			if err != nil {
-				printCh <- printer.NewErrorMessage(fmt.Sprintf("Failed to parse log entry: %q. Error: %s", l, err))
+				printCh <- printer.NewErrorMessage(fmt.Sprintf("Failed to parse log entry: %q. Error: %s", e.Line, err))
 				break
 			}
 			// add messages to the summary
 			c.sum.Add(msg)
+			c.uniques.add(msg, c.clock.Now())
+			// the log entries with no time are not counted by their timestamps
+			if c.events != nil && !msg.Time.IsZero() {
+				c.events.add(msg.Time, c.clock.Now(), msg.Bytes)
+			}
+			// the bytes are counted by the log entries' timestamps in the event time mode
+			if c.events == nil && c.bandwidth {
+				c.bytes += msg.Bytes
+			}
+			if c.stats != nil {
+				c.stats.Add(msg.Code, msg.Latency, msg.HasLatency, msg.Section)
+			}
+		}
+	}
+}
+
+// flush sends the summary stamped with the given time and compared to the previous ones to the printer
+// followed by the summaries of the aggregation windows and starts a new one
+// the unique clients of the summary are sent to metCh if they are monitored
+func (c *Collector) flush(t time.Time, metCh chan<- alert.Metric, printCh chan<- printer.Formatter) {
+	c.sum.CalcTraffic(c.config.SummaryIntervalSec)
+	c.uniques.fill(c.sum, t)
+	c.sum.Time = t
+	if c.config.TrendIntervals > 0 {
+		c.sum.SetTrend(c.history)
+		c.history = append(c.history, c.sum)
+		if len(c.history) > c.config.TrendIntervals {
+			c.history = c.history[1:]
 		}
 	}
+	if c.config.UniqueJump > 0 {
+		metCh <- alert.NewUniqueMetric(c.sum.Sum[uniqueClientsKey], t)
+	}
+	printCh <- *c.sum
+	for _, w := range c.windows {
+		s := w.summarize(c.sum, c.config)
+		s.Time = t
+		printCh <- *s
+	}
+	c.sum = NewSummary(c.config.TopSectionNum, c.config.TopCapacity)
+}
+
+// sendBytes sends the bytes metric of the polling interval ending at the given time
+func (c *Collector) sendBytes(t time.Time, metCh chan<- alert.Metric) {
+	metCh <- alert.NewBytesMetric(c.bytes, t)
+	c.bytes = 0
+}
+
+// sendStats sends the stats metric of the polling interval ending at the given time
+func (c *Collector) sendStats(t time.Time, metCh chan<- alert.Metric) {
+	metCh <- alert.NewStatsMetric(c.stats, t)
+	c.stats = alert.NewStats(c.config)
+}
+
+// sendMetrics sends the given counter metrics of the event time mode
+// informing the printer about the log entries dropped as they came too late or were dated in the future
+func (c *Collector) sendMetrics(mets []alert.Metric, metCh chan<- alert.Metric, printCh chan<- printer.Formatter) {
+	for _, m := range mets {
+		metCh <- m
+	}
+	if c.events.late != 0 {
+		printCh <- printer.NewErrorMessage(fmt.Sprintf("%d log entries came later than the allowed lateness (%ds), not counted for alerting", c.events.late, c.config.LatenessSec))
+		c.events.late = 0
+	}
+	if c.events.future != 0 {
+		printCh <- printer.NewErrorMessage(fmt.Sprintf("%d log entries were dated after the clock time (beyond the allowed lateness of %ds), not counted for alerting", c.events.future, c.config.LatenessSec))
+		c.events.future = 0
+	}
 }
@@ -3,45 +3,340 @@ package collector
 import (
 	"reflect"
 	"testing"
+	"time"
 
+	alert "httplogmonitor/pkg/alertmanager"
+	"httplogmonitor/pkg/clock"
 	"httplogmonitor/pkg/config"
 	"httplogmonitor/pkg/printer"
+	"httplogmonitor/pkg/sketch"
 )
 
 func TestCollectorNominal(t *testing.T) {
 	cfg := config.NewDefault()
 	cfg.TopSectionNum = 2
 	cfg.SummaryIntervalSec = 2
-	c := New(cfg)
+	c, err := New(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create collector: %s", err)
+	}
 
-	logCh := make(chan string, 5)
+	logCh := make(chan LogEntry, 5)
 	printCh := make(chan printer.Formatter)
 
-	logCh <- `127.0.0.1 - james [09/May/2018:16:00:39 +0000] "GET /report HTTP/1.0" 200 123`
-	logCh <- `127.0.0.1 - james [09/May/2018:16:01:39 +0000] "POST /report HTTP/1.0" 202 123`
-	logCh <- `127.0.0.1 - james [09/May/2018:16:02:39 +0000] "PUT /unknown HTTP/1.0" 404 123`
-	logCh <- `127.0.0.1 - james [09/May/2018:16:03:39 +0000] "GET /report HTTP/1.0" 200 123`
-	logCh <- `127.0.0.1 - james [09/May/2018:16:04:39 +0000] "PUT /unknown HTTP/1.0" 500 123`
+	logCh <- LogEntry{Source: "a.log", Line: `127.0.0.1 - james [09/May/2018:16:00:39 +0000] "GET /report HTTP/1.0" 200 123`}
+	logCh <- LogEntry{Source: "a.log", Line: `127.0.0.1 - james [09/May/2018:16:01:39 +0000] "POST /report HTTP/1.0" 202 123`}
+	logCh <- LogEntry{Source: "b.log", Line: `127.0.0.1 - james [09/May/2018:16:02:39 +0000] "PUT /unknown HTTP/1.0" 404 123`}
+	logCh <- LogEntry{Source: "b.log", Line: `127.0.0.1 - james [09/May/2018:16:03:39 +0000] "GET /report HTTP/1.0" 200 123`}
+	logCh <- LogEntry{Source: "b.log", Line: `127.0.0.1 - james [09/May/2018:16:04:39 +0000] "PUT /unknown HTTP/1.0" 500 123 "-" "curl/7.58.0"`}
 
-	go c.Start(logCh, printCh)
+	go c.Start(logCh, nil, printCh)
 
-	gotSummary := <-printCh
+	gotSummary, ok := (<-printCh).(Summary)
+	if !ok {
+		t.Fatal("Summary expected")
+	}
+	if gotSummary.Time.IsZero() {
+		t.Fatal("Summary time expected")
+	}
+	// the end of the interval is not known in advance
+	gotSummary.Time = time.Time{}
 
+	// the top tables are filled in the order of the log entries
+	sections := []string{"/report", "/report", "/unknown", "/report", "/unknown"}
+	clients := []string{"127.0.0.1", "127.0.0.1", "127.0.0.1", "127.0.0.1", "127.0.0.1"}
+	capacity := cfg.TopCapacity
 	expectedSummary := Summary{
-		Sections: map[string]int{
-			"/report":  3,
-			"/unknown": 2,
+		Sections: newTopKOf(capacity, 1, sections...),
+		Paths:    newTopKOf(capacity, 1, sections...),
+		Clients:  newTopKOf(capacity, 1, clients...),
+		Codes: map[string]int{
+			"200": 2,
+			"202": 1,
+			"404": 1,
+			"500": 1,
+		},
+		Methods: newTopKOf(capacity, 1, "GET", "POST", "PUT", "GET", "PUT"),
+		SectionClasses: map[string]map[string]int{
+			"/report": {
+				"2xx": 3,
+			},
+			"/unknown": {
+				"4xx": 1,
+				"5xx": 1,
+			},
+		},
+		Sources: map[string]*sketch.TopK{
+			"a.log": newTopKOf(capacity, 1, "/report", "/report"),
+			"b.log": newTopKOf(capacity, 1, "/unknown", "/report", "/unknown"),
 		},
+		Referers:     newTopKOf(capacity, 1),
+		UserAgents:   newTopKOf(capacity, 1, "curl/7.58.0"),
+		Flags:        map[string]int{},
+		SectionBytes: newTopKOf(capacity, 123, sections...),
+		ClientBytes:  newTopKOf(capacity, 123, clients...),
 		Sum: map[string]int{
-			hitsKey:    5,
-			successKey: 3,
-			errorsKey:  2,
-			trafficKey: 3,
+			hitsKey:         5,
+			successKey:      3,
+			errorsKey:       2,
+			clientErrorsKey: 1,
+			serverErrorsKey: 1,
+			trafficKey:      3,
+			bytesKey:        615,
+			bandwidthKey:    308,
+			// the last log entry comes with a user agent
+			uniqueClientsKey:  1,
+			uniqueVisitorsKey: 2,
+			hourClientsKey:    1,
+			hourVisitorsKey:   2,
+			dayClientsKey:     1,
+			dayVisitorsKey:    2,
 		},
-		topNum: 2,
+		// no latency in the common log format
+		Latency:          sketch.NewHistogram(),
+		SectionLatencies: map[string]*sketch.Histogram{},
+		UniqueClients:    newHyperLogLogOf("127.0.0.1"),
+		UniqueVisitors:   newHyperLogLogOf("127.0.0.1 ", "127.0.0.1 curl/7.58.0"),
+		topNum:           2,
+		capacity:         capacity,
 	}
 
 	if !reflect.DeepEqual(expectedSummary, gotSummary) {
 		t.Fatalf("Excepted summary %#v, got summary %#v", expectedSummary, gotSummary)
 	}
 }
+
+// newTopKOf returns a new top-K summary with the given keys added in order with the given weight
+func newTopKOf(capacity, weight int, keys ...string) *sketch.TopK {
+	t := sketch.NewTopK(capacity)
+	for _, k := range keys {
+		t.Add(k, weight)
+	}
+	return t
+}
+
+// newHyperLogLogOf returns a new HyperLogLog with the given keys added
+func newHyperLogLogOf(keys ...string) *sketch.HyperLogLog {
+	h := sketch.NewHyperLogLog()
+	for _, k := range keys {
+		h.Add(k)
+	}
+	return h
+}
+
+func TestCollectorVirtualClock(t *testing.T) {
+	cfg := config.NewDefault()
+	cfg.SummaryIntervalSec = 10
+	cfg.TrendIntervals = 1
+	clk := clock.NewVirtual()
+	start, _ := time.Parse(timeLocalLayout, "09/May/2018:16:00:00 +0000")
+	clk.Advance(start)
+	c, err := NewWithClock(cfg, clk)
+	if err != nil {
+		t.Fatalf("Failed to create collector: %s", err)
+	}
+
+	logCh := make(chan LogEntry)
+	printCh := make(chan printer.Formatter)
+	go c.Start(logCh, nil, printCh)
+
+	logCh <- LogEntry{Source: "a.log", Line: `127.0.0.1 - james [09/May/2018:16:00:01 +0000] "GET /report HTTP/1.0" 200 123`}
+	// returns once the tick is received by the collector
+	clk.Advance(start.Add(15 * time.Second))
+
+	t.Log("Checking the summary stamped with the clock time")
+	gotSummary, ok := (<-printCh).(Summary)
+	if !ok {
+		t.Fatal("Summary expected")
+	}
+	if !gotSummary.Time.Equal(start.Add(10*time.Second)) || gotSummary.Sum[hitsKey] != 1 {
+		t.Fatalf("Expected 1 hit at %s, got %d at %s", start.Add(10*time.Second), gotSummary.Sum[hitsKey], gotSummary.Time)
+	}
+	if gotSummary.Trend != nil {
+		t.Fatalf("Expected no trend for the first summary, got %+v", gotSummary.Trend)
+	}
+
+	t.Log("Checking the last summary once the log channel is closed")
+	logCh <- LogEntry{Source: "a.log", Line: `127.0.0.1 - james [09/May/2018:16:00:12 +0000] "GET /report HTTP/1.0" 200 123`}
+	close(logCh)
+	gotSummary, ok = (<-printCh).(Summary)
+	if !ok {
+		t.Fatal("Summary expected")
+	}
+	if !gotSummary.Time.Equal(start.Add(15*time.Second)) || gotSummary.Sum[hitsKey] != 1 {
+		t.Fatalf("Expected 1 hit at %s, got %d at %s", start.Add(15*time.Second), gotSummary.Sum[hitsKey], gotSummary.Time)
+	}
+	// compared to the previous summary
+	if gotSummary.Trend == nil || gotSummary.Trend.Hits != 1 || !gotSummary.Trend.Top["/report"] {
+		t.Fatalf("Expected the trend of the previous summary, got %+v", gotSummary.Trend)
+	}
+}
+
+func TestCollectorWindows(t *testing.T) {
+	cfg := config.NewDefault()
+	cfg.SummaryIntervalSec = 10
+	cfg.SummaryWindows = []string{"total", "20s"}
+	clk := clock.NewVirtual()
+	start, _ := time.Parse(timeLocalLayout, "09/May/2018:16:00:00 +0000")
+	clk.Advance(start)
+	c, err := NewWithClock(cfg, clk)
+	if err != nil {
+		t.Fatalf("Failed to create collector: %s", err)
+	}
+
+	logCh := make(chan LogEntry)
+	printCh := make(chan printer.Formatter, 3)
+	go c.Start(logCh, nil, printCh)
+
+	testCases := []struct {
+		name     string
+		hits     int
+		rolling  string
+		expected map[string]int
+	}{
+		{name: "First interval", hits: 1, rolling: "LAST 20s (partial, covering 10s)", expected: map[string]int{"": 1, "TOTAL SINCE START": 1, "LAST 20s (partial, covering 10s)": 1}},
+		{name: "Second interval", hits: 2, rolling: "LAST 20s", expected: map[string]int{"": 2, "TOTAL SINCE START": 3, "LAST 20s": 3}},
+		{name: "First interval out of the rolling window", hits: 3, rolling: "LAST 20s", expected: map[string]int{"": 3, "TOTAL SINCE START": 6, "LAST 20s": 5}},
+	}
+
+	for i, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for j := 0; j < tc.hits; j++ {
+				logCh <- LogEntry{Source: "a.log", Line: `127.0.0.1 - james [09/May/2018:16:00:01 +0000] "GET /report HTTP/1.0" 200 123`}
+			}
+			// returns once the tick is received by the collector
+			clk.Advance(start.Add(time.Duration(i+1) * 10 * time.Second))
+
+			// the interval summary first, then the windows in their order
+			for _, w := range []string{"", "TOTAL SINCE START", tc.rolling} {
+				sum, ok := (<-printCh).(Summary)
+				if !ok {
+					t.Fatal("Summary expected")
+				}
+				if sum.Window != w || sum.Sum[hitsKey] != tc.expected[w] || sum.Sections.Count("/report") != tc.expected[w] {
+					t.Fatalf("Test case %q: expected %d hits in the window %q, got %d in %q", tc.name, tc.expected[w], w, sum.Sum[hitsKey], sum.Window)
+				}
+			}
+		})
+	}
+	close(logCh)
+}
+
+func TestCollectorBandwidth(t *testing.T) {
+	cfg := config.NewDefault()
+	cfg.PollIntervalSec = 1
+	cfg.BandwidthThreshold = 1024
+	clk := clock.NewVirtual()
+	start, _ := time.Parse(timeLocalLayout, "09/May/2018:16:00:00 +0000")
+	clk.Advance(start)
+	c, err := NewWithClock(cfg, clk)
+	if err != nil {
+		t.Fatalf("Failed to create collector: %s", err)
+	}
+
+	logCh := make(chan LogEntry)
+	metCh := make(chan alert.Metric, 1)
+	printCh := make(chan printer.Formatter, 1)
+	go c.Start(logCh, metCh, printCh)
+
+	logCh <- LogEntry{Source: "a.log", Line: `127.0.0.1 - james [09/May/2018:16:00:00 +0000] "GET /report HTTP/1.0" 200 123`}
+	logCh <- LogEntry{Source: "a.log", Line: `127.0.0.1 - james [09/May/2018:16:00:00 +0000] "GET /report HTTP/1.0" 200 877`}
+	// returns once the tick is received by the collector
+	clk.Advance(start.Add(time.Second))
+
+	t.Log("Checking the bytes metric of the polling interval")
+	m := <-metCh
+	if _, ok := m.(alert.BytesMetric); !ok {
+		t.Fatalf("Bytes metric expected, got %#v", m)
+	}
+	if b, _ := m.Value().(int); b != 1000 || !m.Time().Equal(start.Add(time.Second)) {
+		t.Fatalf("Expected 1000 bytes at %s, got %d at %s", start.Add(time.Second), b, m.Time())
+	}
+	close(logCh)
+}
+
+func TestCollectorStats(t *testing.T) {
+	cfg := config.NewDefault()
+	cfg.PollIntervalSec = 1
+	cfg.Rules = []config.Rule{
+		{Name: "errors", Metric: config.RuleMetricErrors, Op: ">", Threshold: 10},
+		{Name: "api_traffic", Metric: config.RuleMetricSectionHits, Section: "/api", Op: ">", Threshold: 10},
+	}
+	clk := clock.NewVirtual()
+	start, _ := time.Parse(timeLocalLayout, "09/May/2018:16:00:00 +0000")
+	clk.Advance(start)
+	c, err := NewWithClock(cfg, clk)
+	if err != nil {
+		t.Fatalf("Failed to create collector: %s", err)
+	}
+
+	logCh := make(chan LogEntry)
+	metCh := make(chan alert.Metric, 1)
+	printCh := make(chan printer.Formatter, 1)
+	go c.Start(logCh, metCh, printCh)
+
+	logCh <- LogEntry{Source: "a.log", Line: `127.0.0.1 - james [09/May/2018:16:00:00 +0000] "GET /api/users HTTP/1.0" 200 123`}
+	logCh <- LogEntry{Source: "a.log", Line: `127.0.0.1 - james [09/May/2018:16:00:00 +0000] "GET /api/orders HTTP/1.0" 503 123`}
+	logCh <- LogEntry{Source: "a.log", Line: `127.0.0.1 - james [09/May/2018:16:00:00 +0000] "GET /report HTTP/1.0" 404 123`}
+	// returns once the tick is received by the collector
+	clk.Advance(start.Add(time.Second))
+
+	t.Log("Checking the stats metric of the polling interval")
+	m := <-metCh
+	if _, ok := m.(alert.StatsMetric); !ok {
+		t.Fatalf("Stats metric expected, got %#v", m)
+	}
+	s, _ := m.Value().(*alert.Stats)
+	if s.Hits != 3 || s.Errors() != 2 || s.Classes[2] != 1 || s.Classes[5] != 1 || !reflect.DeepEqual(s.Sections, map[string]int{"/api": 2}) || !m.Time().Equal(start.Add(time.Second)) {
+		t.Fatalf("Unexpected stats %+v at %s", s, m.Time())
+	}
+	close(logCh)
+}
+
+func TestCollectorUniqueClients(t *testing.T) {
+	cfg := config.NewDefault()
+	cfg.SummaryIntervalSec = 10
+	cfg.UniqueJump = 5
+	clk := clock.NewVirtual()
+	start, _ := time.Parse(timeLocalLayout, "09/May/2018:16:00:00 +0000")
+	clk.Advance(start)
+	c, err := NewWithClock(cfg, clk)
+	if err != nil {
+		t.Fatalf("Failed to create collector: %s", err)
+	}
+
+	logCh := make(chan LogEntry)
+	metCh := make(chan alert.Metric, 1)
+	printCh := make(chan printer.Formatter, 1)
+	go c.Start(logCh, metCh, printCh)
+
+	logCh <- LogEntry{Source: "a.log", Line: `10.0.0.1 - - [09/May/2018:16:00:00 +0000] "GET /report HTTP/1.0" 200 123 "-" "curl/7.58.0"`}
+	logCh <- LogEntry{Source: "a.log", Line: `10.0.0.1 - - [09/May/2018:16:00:00 +0000] "GET /report HTTP/1.0" 200 123 "-" "Mozilla/5.0"`}
+	logCh <- LogEntry{Source: "a.log", Line: `10.0.0.2 - - [09/May/2018:16:00:00 +0000] "GET /report HTTP/1.0" 200 123 "-" "curl/7.58.0"`}
+	clk.Advance(start.Add(10 * time.Second))
+
+	t.Log("Checking the unique clients metric of the summary interval")
+	m := <-metCh
+	if _, ok := m.(alert.UniqueMetric); !ok {
+		t.Fatalf("Unique metric expected, got %#v", m)
+	}
+	if u, _ := m.Value().(int); u != 2 {
+		t.Fatalf("Expected 2 unique clients, got %d", u)
+	}
+	sum := (<-printCh).(Summary)
+	if sum.Sum[uniqueVisitorsKey] != 3 || sum.Sum[hourClientsKey] != 2 || sum.Sum[dayVisitorsKey] != 3 {
+		t.Fatalf("Unexpected unique clients and visitors %v", sum.Sum)
+	}
+
+	t.Log("Checking the rolling hour in the next interval")
+	logCh <- LogEntry{Source: "a.log", Line: `10.0.0.3 - - [09/May/2018:16:00:10 +0000] "GET /report HTTP/1.0" 200 123 "-" "curl/7.58.0"`}
+	clk.Advance(start.Add(20 * time.Second))
+	if u, _ := (<-metCh).Value().(int); u != 1 {
+		t.Fatalf("Expected 1 unique client, got %d", u)
+	}
+	sum = (<-printCh).(Summary)
+	if sum.Sum[hourClientsKey] != 3 || sum.Sum[hourVisitorsKey] != 4 {
+		t.Fatalf("Unexpected unique clients and visitors of the last hour %v", sum.Sum)
+	}
+	close(logCh)
+}
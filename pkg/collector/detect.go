@@ -0,0 +1,135 @@
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"httplogmonitor/pkg/config"
+)
+
+// detectMinConfidence is the minimum ratio of the sampled lines to be matched by the detected format
+const detectMinConfidence = 0.8
+
+// ErrNoSample is returned by the detection if there is no log entry to sample (empty log files)
+var ErrNoSample = errors.New("no log entry to sample")
+
+// Detection is the result of the log format detection
+type Detection struct {
+	// Format and JSONFields are to be set to the configuration
+	Format     string
+	JSONFields string
+	// Matched is the number of the sampled lines matched by the format
+	Matched int
+	Sampled int
+	// configured is set for the formats given by the flags, preferred to the other ones for the equal scores
+	configured bool
+}
+
+// Name returns the name of the detected format with the json fields preset if any
+func (d Detection) Name() string {
+	if len(d.JSONFields) != 0 {
+		return fmt.Sprintf("%s (%s)", d.Format, d.JSONFields)
+	}
+	return d.Format
+}
+
+// Confidence returns the ratio of the sampled lines matched by the detected format
+func (d Detection) Confidence() float64 {
+	if d.Sampled == 0 {
+		return 0
+	}
+	return float64(d.Matched) / float64(d.Sampled)
+}
+
+// detectCandidates returns the formats to be scored in the order of preference for the equal scores:
+// the configured formats (nginx, apache and json fields) first, then the more specific formats first
+func detectCandidates(cfg *config.Config) []Detection {
+	var cands []Detection
+	if len(cfg.NginxLogFormat) != 0 || len(cfg.NginxConfPath) != 0 {
+		cands = append(cands, Detection{Format: config.LogFormatNginx, configured: true})
+	}
+	if len(cfg.ApacheLogFormat) != 0 {
+		cands = append(cands, Detection{Format: config.LogFormatApache, configured: true})
+	}
+	if len(cfg.JSONFields) != 0 {
+		// the configured json fields are kept
+		cands = append(cands, Detection{Format: config.LogFormatJSON, configured: true})
+	}
+	return append(cands,
+		Detection{Format: config.LogFormatCLF},
+		Detection{Format: config.LogFormatHAProxy},
+		Detection{Format: config.LogFormatEnvoy},
+		Detection{Format: config.LogFormatALB},
+		Detection{Format: config.LogFormatCloudFront},
+		Detection{Format: config.LogFormatW3C},
+		Detection{Format: config.LogFormatJSON, JSONFields: "nginx"},
+		Detection{Format: config.LogFormatJSON, JSONFields: "caddy"},
+	)
+}
+
+// DetectFormat scores the parsers of all the formats by the ratio of the given sampled log entries they match
+// and returns the best one, the directives (W3C extended headers) are matched only by their parsers
+// fails if no format matches enough log entries or several ones match as many of them as the best one
+// but the configured formats and the more specific cloudfront format preferred to the w3c extended one
+func DetectFormat(cfg *config.Config, entries []LogEntry) (Detection, error) {
+	if len(entries) == 0 {
+		return Detection{}, ErrNoSample
+	}
+
+	best := Detection{}
+	// the formats matching as many entries as the best one
+	var ties []string
+	for _, cand := range detectCandidates(cfg) {
+		c := *cfg
+		c.LogFormat = cand.Format
+		if len(cand.JSONFields) != 0 {
+			c.JSONFields = cand.JSONFields
+		}
+		// the cloudfront parser matches all the w3c extended entries following a #Fields directive
+		if cand.Format == config.LogFormatCloudFront && !cloudFrontFieldsOnly(entries) {
+			continue
+		}
+		p, err := NewParser(&c)
+		if err != nil {
+			continue
+		}
+
+		cand.Sampled = len(entries)
+		for _, e := range entries {
+			if _, err := ParseEntry(p, e); err == nil || err == ErrDirective {
+				cand.Matched++
+			}
+		}
+		switch {
+		case cand.Matched > best.Matched:
+			best, ties = cand, nil
+		case cand.Matched == 0 || cand.Matched < best.Matched:
+		case best.configured && !cand.configured:
+		case best.Format == config.LogFormatCloudFront && cand.Format == config.LogFormatW3C:
+		default:
+			ties = append(ties, cand.Name())
+		}
+	}
+
+	if best.Confidence() < detectMinConfidence {
+		if best.Matched == 0 {
+			return best, fmt.Errorf("no known format matches the %d sampled log entries", len(entries))
+		}
+		return best, fmt.Errorf("ambiguous format, the best one (%s) matches only %d of the %d sampled log entries", best.Name(), best.Matched, len(entries))
+	}
+	if len(ties) != 0 {
+		return best, fmt.Errorf("ambiguous format, %s and %s match the same %d of the %d sampled log entries", best.Name(), strings.Join(ties, ", "), best.Matched, len(entries))
+	}
+	return best, nil
+}
+
+// cloudFrontFieldsOnly tells if all the #Fields directives of the given log entries are cloudfront's ones
+func cloudFrontFieldsOnly(entries []LogEntry) bool {
+	for _, e := range entries {
+		if strings.HasPrefix(e.Line, "#Fields:") && !strings.Contains(e.Line, "x-edge-location") {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,149 @@
+package collector
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"httplogmonitor/pkg/config"
+)
+
+func TestDetectFormat(t *testing.T) {
+	clf := []string{
+		`127.0.0.1 - james [09/May/2018:16:00:39 +0000] "GET /report HTTP/1.0" 200 123`,
+		`127.0.0.1 - jill [09/May/2018:16:00:41 +0000] "GET /api/user HTTP/1.0" 200 234`,
+		`127.0.0.1 - frank [09/May/2018:16:00:42 +0000] "POST /api/user HTTP/1.0" 200 34`,
+	}
+	iis := []string{
+		"#Software: Microsoft Internet Information Services 10.0",
+		"#Fields: date time s-ip cs-method cs-uri-stem cs-uri-query s-port cs-username c-ip cs(User-Agent) cs(Referer) sc-status sc-substatus sc-win32-status time-taken",
+		"2018-05-09 16:00:39 10.0.0.2 GET /api/user id=1 443 - 10.0.0.1 curl/7.58.0 - 200 0 0 15",
+	}
+	testCases := []struct {
+		name               string
+		file               string
+		lines              []string
+		apacheFormat       string
+		nginxFormat        string
+		expectedFormat     string
+		expectedJSONFields string
+		expectedErr        bool
+	}{
+		{
+			name:           "Common log format",
+			lines:          clf,
+			expectedFormat: config.LogFormatCLF,
+		},
+		{
+			name:           "Configured apache format first",
+			lines:          clf,
+			apacheFormat:   `%h %l %u %t "%r" %>s %b`,
+			expectedFormat: config.LogFormatApache,
+		},
+		{
+			name:         "Configured formats tied",
+			lines:        clf,
+			apacheFormat: `%h %l %u %t "%r" %>s %b`,
+			nginxFormat:  `$remote_addr - $remote_user [$time_local] "$request" $status $body_bytes_sent`,
+			expectedErr:  true,
+		},
+		{
+			name:           "HAProxy",
+			file:           "haproxy.log",
+			expectedFormat: config.LogFormatHAProxy,
+		},
+		{
+			name:           "Envoy",
+			file:           "envoy.log",
+			expectedFormat: config.LogFormatEnvoy,
+		},
+		{
+			name:           "Application load balancer",
+			file:           "alb.log",
+			expectedFormat: config.LogFormatALB,
+		},
+		{
+			name:           "CloudFront",
+			file:           "cloudfront.log",
+			expectedFormat: config.LogFormatCloudFront,
+		},
+		{
+			name:           "W3C extended",
+			lines:          iis,
+			expectedFormat: config.LogFormatW3C,
+		},
+		{
+			name: "Caddy JSON",
+			lines: []string{
+				`{"ts":1525881639.5,"request":{"remote_ip":"127.0.0.1","method":"GET","uri":"/api/user","proto":"HTTP/2.0"},"status":200,"size":123,"duration":0.015}`,
+			},
+			expectedFormat:     config.LogFormatJSON,
+			expectedJSONFields: "caddy",
+		},
+		{
+			name:        "Ambiguous",
+			lines:       append([]string{"garbage", "more garbage"}, clf[0]),
+			expectedErr: true,
+		},
+		{
+			name:        "Unknown",
+			lines:       []string{"garbage"},
+			expectedErr: true,
+		},
+		{
+			name:        "No log entry",
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := config.NewDefault()
+			cfg.ApacheLogFormat = tc.apacheFormat
+			cfg.NginxLogFormat = tc.nginxFormat
+
+			entries := make([]LogEntry, 0, len(tc.lines))
+			for _, l := range tc.lines {
+				entries = append(entries, LogEntry{Source: "test", Line: l})
+			}
+			if len(tc.file) != 0 {
+				entries = readTestEntries(t, tc.file)
+			}
+
+			d, err := DetectFormat(cfg, entries)
+			if tc.expectedErr {
+				if err == nil {
+					t.Errorf("Test case %q got no error while one is expected, detected %s", tc.name, d.Name())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Test case %q got an unexpected error: %s", tc.name, err)
+			}
+			if d.Format != tc.expectedFormat || d.JSONFields != tc.expectedJSONFields {
+				t.Errorf("Test case %q: expected %q %q, got %q %q", tc.name, tc.expectedFormat, tc.expectedJSONFields, d.Format, d.JSONFields)
+			}
+			if d.Confidence() != 1 {
+				t.Errorf("Test case %q: expected all the entries matched, got %d of %d", tc.name, d.Matched, d.Sampled)
+			}
+		})
+	}
+}
+
+// readTestEntries reads all the lines of the given file of testdata as log entries
+func readTestEntries(t *testing.T, file string) []LogEntry {
+	path := filepath.Join("testdata", file)
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open test file: %s", err)
+	}
+	defer f.Close()
+
+	var entries []LogEntry
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		entries = append(entries, LogEntry{Source: path, Line: sc.Text()})
+	}
+	return entries
+}
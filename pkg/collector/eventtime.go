@@ -0,0 +1,125 @@
+package collector
+
+import (
+	"time"
+
+	alert "httplogmonitor/pkg/alertmanager"
+)
+
+// eventCounter counts the log entries by their event time (timestamp of the log entry)
+// in buckets of the polling interval
+// a bucket is complete once the watermark passes its end,
+// the watermark is the latest event time seen minus the allowed lateness,
+// it keeps moving with the clock while no newer event is seen
+// the bytes sent are summed in the same buckets if the bandwidth is monitored
+// the events dated after the clock time (plus the lateness) are dropped
+// and only the buckets of the last monitoring window are flushed after a gap
+type eventCounter struct {
+	width    time.Duration
+	lateness time.Duration
+	window   time.Duration
+	// counters by the bucket start
+	buckets map[int64]int
+	// bytes sent by the bucket start, nil if the bandwidth is not monitored
+	bytes map[int64]int
+	// start of the next bucket to be completed, not set until the first event
+	next time.Time
+	// the latest event time and the clock time at which it was seen
+	maxEvent     time.Time
+	maxEventSeen time.Time
+	// number of events dropped since the last flush as their buckets were already complete (or skipped)
+	late int
+	// number of events dropped since the last flush as they were dated in the future
+	future int
+}
+
+// newEventCounter returns a new instance of eventCounter with given bucket width, allowed lateness
+// and monitoring window, summing the bytes sent too if bandwidth is set
+func newEventCounter(width, lateness, window time.Duration, bandwidth bool) *eventCounter {
+	e := &eventCounter{
+		width:    width,
+		lateness: lateness,
+		window:   window,
+		buckets:  map[int64]int{},
+	}
+	if bandwidth {
+		e.bytes = map[int64]int{}
+	}
+	return e
+}
+
+// add counts the event which happened at the given time, was seen at the given clock time and sent the given bytes
+// returns false if the event is too late to be counted or dated after the clock time plus the lateness
+func (e *eventCounter) add(t, now time.Time, bytes int) bool {
+	if t.After(now.Add(e.lateness)) {
+		e.future++
+		return false
+	}
+	start := t.Truncate(e.width)
+	if e.next.IsZero() {
+		e.next = start
+	}
+	if start.Before(e.next) {
+		e.late++
+		return false
+	}
+
+	e.buckets[start.UnixNano()]++
+	if e.bytes != nil {
+		e.bytes[start.UnixNano()] += bytes
+	}
+	if t.After(e.maxEvent) {
+		e.maxEvent = t
+		e.maxEventSeen = now
+	}
+	return true
+}
+
+// watermark returns the event time up to which the buckets are complete at the given clock time
+func (e *eventCounter) watermark(now time.Time) time.Time {
+	return e.maxEvent.Add(now.Sub(e.maxEventSeen)).Add(-e.lateness)
+}
+
+// flush returns the counter (and bytes) metrics of all the buckets completed at the given clock time in chronological order
+// the metric time is the end of the bucket
+func (e *eventCounter) flush(now time.Time) []alert.Metric {
+	if e.next.IsZero() {
+		return nil
+	}
+	return e.flushUntil(e.watermark(now))
+}
+
+// flushAll returns the counter (and bytes) metrics of all the buckets up to the latest event
+func (e *eventCounter) flushAll() []alert.Metric {
+	if e.next.IsZero() {
+		return nil
+	}
+	return e.flushUntil(e.maxEvent.Truncate(e.width).Add(e.width))
+}
+
+// flushUntil returns the counter (and bytes) metrics of all the buckets ending not after the given time
+// the buckets before the monitoring window ending at the given time are skipped, their events counted as late
+func (e *eventCounter) flushUntil(wm time.Time) []alert.Metric {
+	if skip := wm.Add(-e.window).Truncate(e.width); skip.After(e.next) {
+		for k, cnt := range e.buckets {
+			if k < skip.UnixNano() {
+				e.late += cnt
+				delete(e.buckets, k)
+				delete(e.bytes, k)
+			}
+		}
+		e.next = skip
+	}
+	mets := []alert.Metric{}
+	for end := e.next.Add(e.width); !end.After(wm); end = e.next.Add(e.width) {
+		k := e.next.UnixNano()
+		mets = append(mets, alert.NewCounterMetric(e.buckets[k], end))
+		delete(e.buckets, k)
+		if e.bytes != nil {
+			mets = append(mets, alert.NewBytesMetric(e.bytes[k], end))
+			delete(e.bytes, k)
+		}
+		e.next = end
+	}
+	return mets
+}
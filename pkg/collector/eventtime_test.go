@@ -0,0 +1,150 @@
+package collector
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	alert "httplogmonitor/pkg/alertmanager"
+)
+
+func TestEventCounter(t *testing.T) {
+	e := newEventCounter(time.Second, 2*time.Second, time.Minute, false)
+	start := mustParseTime("09/May/2018:16:00:00 +0000")
+	at := func(sec float64) time.Time { return start.Add(time.Duration(sec * float64(time.Second))) }
+
+	type metric struct {
+		cnt int
+		end time.Time
+	}
+	check := func(name string, got []metric, expected []metric) {
+		if len(got) != len(expected) {
+			t.Fatalf("%s: expected metrics %v, got %v", name, expected, got)
+		}
+		for i := range expected {
+			if got[i].cnt != expected[i].cnt || !got[i].end.Equal(expected[i].end) {
+				t.Fatalf("%s: expected metrics %v, got %v", name, expected, got)
+			}
+		}
+	}
+	flush := func(now time.Time) []metric {
+		ms := []metric{}
+		for _, m := range e.flush(now) {
+			cnt, _ := m.Value().(int)
+			ms = append(ms, metric{cnt, m.Time()})
+		}
+		return ms
+	}
+
+	t.Log("Adding a burst of events read at once")
+	// the clock is 10 seconds ahead of the log entries
+	now := at(10)
+	for _, sec := range []float64{0.1, 0.5, 1.2, 2.7, 2.9, 3.1} {
+		if !e.add(at(sec), now, 0) {
+			t.Fatalf("Event at %v must not be late", sec)
+		}
+	}
+	// watermark is 3.1 - 2 = 1.1: only the first bucket is complete
+	check("Burst", flush(now), []metric{{2, at(1)}})
+
+	t.Log("Adding an out of order event within the lateness")
+	if !e.add(at(1.5), now, 0) {
+		t.Fatal("Event within the lateness must be counted")
+	}
+
+	t.Log("Adding a late event")
+	if e.add(at(0.9), now, 0) {
+		t.Fatal("Event of the complete bucket must be late")
+	}
+	if e.late != 1 {
+		t.Fatalf("Expected 1 late event, got %d", e.late)
+	}
+
+	t.Log("Letting the clock move without new events")
+	// watermark is 3.1 + 3 - 2 = 4.1
+	check("Clock", flush(now.Add(3*time.Second)), []metric{{2, at(2)}, {2, at(3)}, {1, at(4)}})
+
+	t.Log("Flushing all the buckets")
+	e.add(at(5.5), now.Add(3*time.Second), 0)
+	ms := e.flushAll()
+	if len(ms) != 2 {
+		t.Fatalf("Expected 2 metrics, got %d", len(ms))
+	}
+	if cnt, _ := ms[1].Value().(int); cnt != 1 || !ms[1].Time().Equal(at(6)) {
+		t.Fatalf("Expected 1 hit at %s, got %d at %s", at(6), cnt, ms[1].Time())
+	}
+}
+
+func TestEventCounterBytes(t *testing.T) {
+	e := newEventCounter(time.Second, 0, time.Minute, true)
+	start := mustParseTime("09/May/2018:16:00:00 +0000")
+	now := start.Add(2 * time.Second)
+
+	e.add(start.Add(100*time.Millisecond), now, 1000)
+	e.add(start.Add(500*time.Millisecond), now, 24)
+	e.add(start.Add(1500*time.Millisecond), now, 512)
+
+	ms := e.flushAll()
+	expected := []alert.Metric{
+		alert.NewCounterMetric(2, start.Add(time.Second)),
+		alert.NewBytesMetric(1024, start.Add(time.Second)),
+		alert.NewCounterMetric(1, start.Add(2*time.Second)),
+		alert.NewBytesMetric(512, start.Add(2*time.Second)),
+	}
+	if !reflect.DeepEqual(expected, ms) {
+		t.Fatalf("Expected metrics %v, got %v", expected, ms)
+	}
+}
+
+func TestEventCounterBounds(t *testing.T) {
+	start := mustParseTime("09/May/2018:16:00:00 +0000")
+
+	testCases := []struct {
+		name    string
+		events  []time.Time
+		now     time.Time
+		metrics int
+		late    int
+		future  int
+	}{
+		{
+			name:    "Event dated after the clock time",
+			events:  []time.Time{start, start.Add(time.Hour)},
+			now:     start.Add(2 * time.Second),
+			metrics: 1,
+			future:  1,
+		},
+		{
+			name:    "Event within the lateness after the clock time",
+			events:  []time.Time{start, start.Add(2 * time.Second)},
+			now:     start.Add(time.Second),
+			metrics: 3,
+		},
+		{
+			name:    "Old first event",
+			events:  []time.Time{start.Add(-24 * time.Hour), start},
+			now:     start.Add(time.Second),
+			metrics: 10,
+			late:    1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := newEventCounter(time.Second, 2*time.Second, 10*time.Second, false)
+			for _, ev := range tc.events {
+				e.add(ev, tc.now, 0)
+			}
+			ms := e.flush(tc.now.Add(3 * time.Second))
+			if len(ms) != tc.metrics {
+				t.Fatalf("Expected %d metrics, got %d", tc.metrics, len(ms))
+			}
+			if e.late != tc.late {
+				t.Fatalf("Expected %d late events, got %d", tc.late, e.late)
+			}
+			if e.future != tc.future {
+				t.Fatalf("Expected %d future events, got %d", tc.future, e.future)
+			}
+		})
+	}
+}
@@ -0,0 +1,246 @@
+package collector
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// jsonFieldKeys are the keys of the log message fields which can be mapped to the json fields
+// in the order they are set: the method and the path override the ones of the request line
+var jsonFieldKeys = []string{"remote_addr", "user", "time", "request", "method", "uri", "protocol", "status", "bytes", "referer", "user_agent", "latency", "upstream_latency"}
+
+// jsonSetters store the value of the json field into the log message
+var jsonSetters = map[string]func(m *LogMessage, v string) error{
+	"remote_addr":      setRemoteAddr,
+	"user":             setUser,
+	"time":             setJSONTime,
+	"request":          setJSONRequest,
+	"method":           setMethod,
+	"uri":              setPath,
+	"protocol":         setProtocol,
+	"status":           setCode,
+	"bytes":            setBytes,
+	"referer":          setJSONReferer,
+	"user_agent":       setJSONUserAgent,
+	"latency":          setLatency,
+	"upstream_latency": setUpstreamLatency,
+}
+
+// jsonFieldPresets are the mappings of the known json access logs
+// nginx one follows the names of the variables (log_format escape=json) and is the default
+var jsonFieldPresets = map[string]map[string]string{
+	"nginx": {
+		"remote_addr":      "remote_addr",
+		"user":             "remote_user",
+		"time":             "time_local",
+		"request":          "request",
+		"status":           "status",
+		"bytes":            "body_bytes_sent",
+		"referer":          "http_referer",
+		"user_agent":       "http_user_agent",
+		"latency":          "request_time",
+		"upstream_latency": "upstream_response_time",
+	},
+	"caddy": {
+		"remote_addr": "request.remote_ip",
+		"user":        "user_id",
+		"time":        "ts",
+		"method":      "request.method",
+		"uri":         "request.uri",
+		"protocol":    "request.proto",
+		"status":      "status",
+		"bytes":       "size",
+		"referer":     "request.headers.Referer",
+		"user_agent":  "request.headers.User-Agent",
+		"latency":     "duration",
+	},
+}
+
+// defaultJSONPreset is the mapping used if no preset is given
+const defaultJSONPreset = "nginx"
+
+// jsonField is a json field mapped to the log message
+type jsonField struct {
+	name string
+	set  func(m *LogMessage, v string) error
+}
+
+// JSONParser is a parser of the access log entries written as json objects, one per line
+type JSONParser struct {
+	fields []jsonField
+}
+
+// NewJSONParser returns a parser of the json log entries with the given field mapping:
+// comma separated presets (nginx, caddy) and key=field pairs, the nested fields are separated by dots
+// e.g. "caddy,user=request.user" or "status=code,uri=path,latency="
+// an empty field unmaps the key, the pairs are applied on top of the nginx preset if no preset is given
+func NewJSONParser(spec string) (*JSONParser, error) {
+	mapping, err := parseJSONFields(spec)
+	if err != nil {
+		return nil, err
+	}
+	if len(mapping["status"]) == 0 {
+		return nil, errors.New("json fields must map status")
+	}
+	if len(mapping["request"]) == 0 && len(mapping["uri"]) == 0 {
+		return nil, errors.New("json fields must map request or uri")
+	}
+
+	p := &JSONParser{}
+	for _, k := range jsonFieldKeys {
+		if name := mapping[k]; len(name) != 0 {
+			p.fields = append(p.fields, jsonField{name: name, set: jsonSetters[k]})
+		}
+	}
+	return p, nil
+}
+
+// parseJSONFields returns the mapping of the log message fields to the json fields given by the spec
+func parseJSONFields(spec string) (map[string]string, error) {
+	mapping := map[string]string{}
+	preset := false
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if len(item) == 0 {
+			continue
+		}
+		i := strings.IndexByte(item, '=')
+		if i == -1 {
+			p, ok := jsonFieldPresets[item]
+			if !ok {
+				return nil, fmt.Errorf("unknown json fields preset %q", item)
+			}
+			for k, v := range p {
+				mapping[k] = v
+			}
+			preset = true
+			continue
+		}
+		if _, ok := jsonSetters[item[:i]]; !ok {
+			return nil, fmt.Errorf("unknown json field key %q, supported keys: %s", item[:i], strings.Join(sortedJSONKeys(), ", "))
+		}
+		mapping[item[:i]] = item[i+1:]
+	}
+
+	if !preset {
+		for k, v := range jsonFieldPresets[defaultJSONPreset] {
+			if _, ok := mapping[k]; !ok {
+				mapping[k] = v
+			}
+		}
+	}
+	return mapping, nil
+}
+
+// sortedJSONKeys returns the keys of the log message fields in the alphabetical order
+func sortedJSONKeys() []string {
+	keys := append([]string{}, jsonFieldKeys...)
+	sort.Strings(keys)
+	return keys
+}
+
+// Parse parses the given json log entry
+// the absent, null and empty fields are left empty but the status and the request path
+func (p *JSONParser) Parse(str string) (*LogMessage, error) {
+	d := json.NewDecoder(strings.NewReader(str))
+	d.UseNumber()
+	var obj map[string]interface{}
+	if err := d.Decode(&obj); err != nil {
+		return nil, errors.New("json log entry format not matched")
+	}
+
+	msg := &LogMessage{}
+	for _, f := range p.fields {
+		v, ok := lookupJSON(obj, f.name)
+		if !ok || len(v) == 0 {
+			continue
+		}
+		if err := f.set(msg, v); err != nil {
+			return nil, fmt.Errorf("json field %q: %s", f.name, err)
+		}
+	}
+
+	if msg.Code == 0 {
+		return nil, errors.New("no status in json log entry")
+	}
+	if len(msg.Section) == 0 {
+		return nil, errors.New("no request path in json log entry")
+	}
+	return msg, nil
+}
+
+// lookupJSON returns the string value of the field of the given object
+// the nested fields are separated by dots, the first element is taken from the arrays (headers)
+func lookupJSON(obj map[string]interface{}, name string) (string, bool) {
+	if v, ok := obj[name]; ok {
+		return jsonString(v)
+	}
+	// the field names may contain dots themselves
+	for i := strings.IndexByte(name, '.'); i != -1; {
+		if sub, ok := obj[name[:i]].(map[string]interface{}); ok {
+			if v, ok := lookupJSON(sub, name[i+1:]); ok {
+				return v, true
+			}
+		}
+		next := strings.IndexByte(name[i+1:], '.')
+		if next == -1 {
+			break
+		}
+		i += next + 1
+	}
+	return "", false
+}
+
+// jsonString returns the string form of the given json value, false for null and objects
+func jsonString(v interface{}) (string, bool) {
+	switch t := v.(type) {
+	case string:
+		return t, true
+	case json.Number:
+		return t.String(), true
+	case bool:
+		return strconv.FormatBool(t), true
+	case []interface{}:
+		if len(t) != 0 {
+			return jsonString(t[0])
+		}
+	}
+	return "", false
+}
+
+// setJSONTime parses the time given as unix seconds (1525881639.123), RFC3339 or the local time of nginx
+func setJSONTime(m *LogMessage, v string) error {
+	if f, err := strconv.ParseFloat(v, 64); err == nil {
+		sec, frac := math.Modf(f)
+		m.Time = time.Unix(int64(sec), int64(math.Round(frac*1e6))*int64(time.Microsecond))
+		return nil
+	}
+	for _, layout := range []string{time.RFC3339Nano, timeLocalLayout} {
+		if t, err := time.Parse(layout, v); err == nil {
+			m.Time = t
+			return nil
+		}
+	}
+	return errors.New("wrong time format")
+}
+
+// setJSONRequest parses the request line, json strings are not escaped the nginx way
+func setJSONRequest(m *LogMessage, v string) error {
+	return m.parseRequest(v)
+}
+
+func setJSONReferer(m *LogMessage, v string) error {
+	m.Referer = dashToEmpty(v)
+	return nil
+}
+
+func setJSONUserAgent(m *LogMessage, v string) error {
+	m.UserAgent = dashToEmpty(v)
+	return nil
+}
@@ -0,0 +1,161 @@
+package collector
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewJSONParser(t *testing.T) {
+	testCases := []struct {
+		name        string
+		spec        string
+		expectedErr bool
+	}{
+		{
+			name: "Default",
+			spec: "",
+		},
+		{
+			name: "Preset with override",
+			spec: "caddy,user=request.user",
+		},
+		{
+			name: "Default with override",
+			spec: "status=code, latency=",
+		},
+		{
+			name:        "Unknown preset",
+			spec:        "traefik",
+			expectedErr: true,
+		},
+		{
+			name:        "Unknown key",
+			spec:        "host=server_name",
+			expectedErr: true,
+		},
+		{
+			name:        "Status unmapped",
+			spec:        "status=",
+			expectedErr: true,
+		},
+		{
+			name:        "Request unmapped",
+			spec:        "caddy,uri=",
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := NewJSONParser(tc.spec)
+			if err != nil {
+				if !tc.expectedErr {
+					t.Errorf("Test case %q got not expected error: %s", tc.name, err)
+				}
+				return
+			}
+			if tc.expectedErr {
+				t.Errorf("Test case %q got no error while one is expected", tc.name)
+			}
+		})
+	}
+}
+
+func TestJSONParserParse(t *testing.T) {
+	testCases := []struct {
+		name        string
+		spec        string
+		input       string
+		expected    LogMessage
+		expectedErr bool
+	}{
+		{
+			name:  "Nginx escape=json",
+			spec:  "",
+			input: `{"remote_addr":"10.0.0.1","remote_user":"","time_local":"09/May/2018:16:00:39 +0000","request":"GET /api/user HTTP/1.1","status":"200","body_bytes_sent":"512","http_referer":"","http_user_agent":"curl/7.58.0","request_time":"0.125","upstream_response_time":""}`,
+			expected: LogMessage{
+				Section:    "/api",
+				Method:     "GET",
+				Code:       200,
+				Time:       mustParseTime("09/May/2018:16:00:39 +0000"),
+				Protocol:   "HTTP/1.1",
+				RemoteAddr: "10.0.0.1",
+				Bytes:      512,
+				UserAgent:  "curl/7.58.0",
+				Latency:    125 * time.Millisecond,
+				HasLatency: true,
+			},
+		},
+		{
+			name:  "Caddy",
+			spec:  "caddy",
+			input: `{"level":"info","ts":1525881639.5,"logger":"http.log.access","msg":"handled request","request":{"remote_ip":"10.0.0.1","proto":"HTTP/2.0","method":"POST","host":"example.com","uri":"/users/1?full=1","headers":{"User-Agent":["Mozilla/5.0"],"Referer":["http://example.com/"]}},"user_id":"","duration":0.0421,"size":1024,"status":201}`,
+			expected: LogMessage{
+				Section:    "/users",
+				Method:     "POST",
+				Code:       201,
+				Time:       mustParseTime("09/May/2018:16:00:39 +0000").Add(500 * time.Millisecond),
+				Protocol:   "HTTP/2.0",
+				RemoteAddr: "10.0.0.1",
+				Bytes:      1024,
+				Referer:    "http://example.com/",
+				UserAgent:  "Mozilla/5.0",
+				Latency:    42 * time.Millisecond,
+				HasLatency: true,
+			},
+		},
+		{
+			name:  "Custom fields",
+			spec:  "time=@timestamp,method=http.method,uri=http.path,status=http.status,request=",
+			input: `{"@timestamp":"2018-05-09T16:00:39Z","http":{"method":"GET","path":"/","status":404}}`,
+			expected: LogMessage{
+				Section: "/",
+				Method:  "GET",
+				Code:    404,
+				Time:    mustParseTime("09/May/2018:16:00:39 +0000"),
+			},
+		},
+		{
+			name:        "Not json",
+			spec:        "",
+			input:       `127.0.0.1 - james [09/May/2018:16:00:39 +0000] "GET /report HTTP/1.0" 200 123`,
+			expectedErr: true,
+		},
+		{
+			name:        "No status",
+			spec:        "",
+			input:       `{"request":"GET / HTTP/1.1"}`,
+			expectedErr: true,
+		},
+		{
+			name:        "Wrong status",
+			spec:        "",
+			input:       `{"request":"GET / HTTP/1.1","status":"abc"}`,
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := NewJSONParser(tc.spec)
+			if err != nil {
+				t.Fatalf("Test case %q failed to create parser: %s", tc.name, err)
+			}
+			output, err := p.Parse(tc.input)
+			if err != nil {
+				if !tc.expectedErr {
+					t.Errorf("Test case %q got not expected error: %s", tc.name, err)
+				}
+				return
+			}
+			if tc.expectedErr {
+				t.Errorf("Test case %q got no error while one is expected", tc.name)
+				return
+			}
+
+			if !output.Equal(&tc.expected) {
+				t.Errorf("Test case %q: output didn't match, got %+v", tc.name, *output)
+			}
+		})
+	}
+}
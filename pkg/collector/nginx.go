@@ -0,0 +1,347 @@
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// nginxCombinedFormat is the predefined log format of nginx
+const nginxCombinedFormat = `$remote_addr - $remote_user [$time_local] "$request" $status $body_bytes_sent "$http_referer" "$http_user_agent"`
+
+// nginxVarRegExp matches the variables of the log format: $name or ${name}
+var nginxVarRegExp = regexp.MustCompile(`\$(?:([a-zA-Z0-9_]+)|\{([a-zA-Z0-9_]+)\})`)
+
+// nginxVariable describes how a variable of the log format is matched and stored into the log message
+type nginxVariable struct {
+	pattern string
+	set     func(m *LogMessage, v string) error
+}
+
+// nginxVariables are the variables stored into the log message
+var nginxVariables = map[string]nginxVariable{
+	"remote_addr":            {`\S+`, setRemoteAddr},
+	"remote_user":            {`\S+`, setUser},
+	"time_local":             {`\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}`, setTimeLocal},
+	"time_iso8601":           {`\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(?:Z|[+-]\d{2}:\d{2})`, setTimeISO8601},
+	"msec":                   {`\d+\.\d+`, setMsec},
+	"request":                {`.*?`, setRequest},
+	"request_method":         {`[A-Z]+`, setMethod},
+	"request_uri":            {`\S+`, setPath},
+	"uri":                    {`\S+`, setPath},
+	"server_protocol":        {`\S+`, setProtocol},
+	"status":                 {`\d{3}`, setCode},
+	"body_bytes_sent":        {`\d+`, setBytes},
+	"bytes_sent":             {`\d+`, setBytes},
+	"http_referer":           {`.*?`, setReferer},
+	"http_user_agent":        {`.*?`, setUserAgent},
+	"request_time":           {`\d+(?:\.\d+)?`, setLatency},
+	"upstream_response_time": {`.*?`, setUpstreamLatency},
+}
+
+// nginxIgnoredVariables are the known variables which are matched but not stored
+var nginxIgnoredVariables = map[string]bool{
+	"args":                      true,
+	"binary_remote_addr":        true,
+	"connection":                true,
+	"connection_requests":       true,
+	"content_length":            true,
+	"content_type":              true,
+	"document_uri":              true,
+	"gzip_ratio":                true,
+	"host":                      true,
+	"hostname":                  true,
+	"pipe":                      true,
+	"proxy_add_x_forwarded_for": true,
+	"proxy_host":                true,
+	"query_string":              true,
+	"realip_remote_addr":        true,
+	"remote_port":               true,
+	"request_id":                true,
+	"request_length":            true,
+	"scheme":                    true,
+	"server_addr":               true,
+	"server_name":               true,
+	"server_port":               true,
+	"ssl_cipher":                true,
+	"ssl_protocol":              true,
+	"upstream_addr":             true,
+	"upstream_bytes_received":   true,
+	"upstream_cache_status":     true,
+	"upstream_connect_time":     true,
+	"upstream_header_time":      true,
+	"upstream_response_length":  true,
+	"upstream_status":           true,
+}
+
+// nginxIgnoredPrefixes are the prefixes of the variable families which are matched but not stored
+var nginxIgnoredPrefixes = []string{"http_", "sent_http_", "cookie_", "arg_"}
+
+// NginxFormat is a parser of the log entries compiled from an nginx log_format definition
+type NginxFormat struct {
+	regexp *regexp.Regexp
+	// setters of the stored variables in the order of the capturing groups
+	setters []func(m *LogMessage, v string) error
+}
+
+// CompileNginxFormat compiles the given nginx log format into a parser
+// the format must contain $status and either $request or $request_method with $request_uri (or $uri)
+func CompileNginxFormat(format string) (*NginxFormat, error) {
+	f := &NginxFormat{}
+	seen := map[string]bool{}
+	b := strings.Builder{}
+	b.WriteString("^")
+
+	last := 0
+	for _, loc := range nginxVarRegExp.FindAllStringSubmatchIndex(format, -1) {
+		b.WriteString(regexp.QuoteMeta(format[last:loc[0]]))
+		last = loc[1]
+
+		var name string
+		if loc[2] != -1 {
+			name = format[loc[2]:loc[3]]
+		} else {
+			name = format[loc[4]:loc[5]]
+		}
+
+		if v, ok := nginxVariables[name]; ok {
+			b.WriteString("(" + v.pattern + ")")
+			f.setters = append(f.setters, v.set)
+			seen[name] = true
+			continue
+		}
+		if !isIgnoredNginxVariable(name) {
+			return nil, fmt.Errorf("unsupported nginx variable $%s", name)
+		}
+		b.WriteString("(?:.*?)")
+	}
+	b.WriteString(regexp.QuoteMeta(format[last:]))
+	b.WriteString("$")
+
+	if !seen["status"] {
+		return nil, errors.New("nginx log format must contain $status")
+	}
+	if !seen["request"] && !(seen["request_method"] && (seen["request_uri"] || seen["uri"])) {
+		return nil, errors.New("nginx log format must contain $request or $request_method and $request_uri")
+	}
+
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile nginx log format: %s", err)
+	}
+	f.regexp = re
+	return f, nil
+}
+
+// isIgnoredNginxVariable checks whether the given variable is known but not stored
+func isIgnoredNginxVariable(name string) bool {
+	if nginxIgnoredVariables[name] {
+		return true
+	}
+	for _, p := range nginxIgnoredPrefixes {
+		if strings.HasPrefix(name, p) && len(name) > len(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// Parse parses the given raw log entry according to the compiled format
+func (f *NginxFormat) Parse(str string) (*LogMessage, error) {
+	m := f.regexp.FindStringSubmatch(str)
+	if m == nil {
+		return nil, errors.New("nginx log format not matched")
+	}
+	msg := &LogMessage{}
+	for i, set := range f.setters {
+		if err := set(msg, m[i+1]); err != nil {
+			return nil, err
+		}
+	}
+	return msg, nil
+}
+
+// LoadNginxFormat returns the log format of the given name defined by a log_format directive of the nginx config file
+// the predefined combined format is returned if it's not redefined by the file
+func LoadNginxFormat(path, name string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	toks := tokenizeNginxConf(string(data))
+	for i := 0; i < len(toks); i++ {
+		if toks[i] != "log_format" || i+1 >= len(toks) || toks[i+1] != name {
+			continue
+		}
+		// the format strings follow the optional escape parameter until the end of the directive
+		b := strings.Builder{}
+		for j := i + 2; j < len(toks) && toks[j] != ";"; j++ {
+			if strings.HasPrefix(toks[j], "escape=") {
+				continue
+			}
+			b.WriteString(toks[j])
+		}
+		return b.String(), nil
+	}
+
+	if name == "combined" {
+		return nginxCombinedFormat, nil
+	}
+	return "", fmt.Errorf("log_format %q not found in %s", name, path)
+}
+
+// tokenizeNginxConf splits the given nginx config into the words, quoted strings (unquoted) and semicolons
+// comments are skipped
+func tokenizeNginxConf(conf string) []string {
+	var toks []string
+	for i := 0; i < len(conf); i++ {
+		c := conf[i]
+		switch {
+		case c == '#':
+			for i < len(conf) && conf[i] != '\n' {
+				i++
+			}
+		case c == ';' || c == '{' || c == '}':
+			toks = append(toks, string(c))
+		case c == '\'' || c == '"':
+			b := strings.Builder{}
+			for i++; i < len(conf) && conf[i] != c; i++ {
+				if conf[i] == '\\' && i+1 < len(conf) {
+					i++
+				}
+				b.WriteByte(conf[i])
+			}
+			toks = append(toks, b.String())
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+		default:
+			j := i
+			for j < len(conf) && !strings.ContainsRune(" \t\r\n;{}'\"", rune(conf[j])) {
+				j++
+			}
+			toks = append(toks, conf[i:j])
+			i = j - 1
+		}
+	}
+	return toks
+}
+
+func setRemoteAddr(m *LogMessage, v string) error {
+	m.RemoteAddr = v
+	return nil
+}
+
+func setUser(m *LogMessage, v string) error {
+	m.User = dashToEmpty(v)
+	return nil
+}
+
+func setTimeLocal(m *LogMessage, v string) error {
+	t, err := time.Parse(timeLocalLayout, v)
+	if err != nil {
+		return errors.New("wrong time format")
+	}
+	m.Time = t
+	return nil
+}
+
+func setTimeISO8601(m *LogMessage, v string) error {
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return errors.New("wrong time format")
+	}
+	m.Time = t
+	return nil
+}
+
+func setMsec(m *LogMessage, v string) error {
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return errors.New("wrong time format")
+	}
+	m.Time = time.Unix(0, int64(math.Round(f*1e3))*int64(time.Millisecond))
+	return nil
+}
+
+func setRequest(m *LogMessage, v string) error {
+	return m.parseRequest(unescapeQuoted(v))
+}
+
+func setMethod(m *LogMessage, v string) error {
+	m.Method = v
+	return nil
+}
+
+func setPath(m *LogMessage, v string) error {
+	return m.parsePath(v)
+}
+
+func setProtocol(m *LogMessage, v string) error {
+	m.Protocol = v
+	return nil
+}
+
+func setCode(m *LogMessage, v string) error {
+	return m.parseCode(v)
+}
+
+func setBytes(m *LogMessage, v string) error {
+	b, err := strconv.Atoi(v)
+	if err != nil {
+		return err
+	}
+	m.Bytes = b
+	return nil
+}
+
+func setReferer(m *LogMessage, v string) error {
+	m.Referer = dashToEmpty(unescapeQuoted(v))
+	return nil
+}
+
+func setUserAgent(m *LogMessage, v string) error {
+	m.UserAgent = dashToEmpty(unescapeQuoted(v))
+	return nil
+}
+
+func setLatency(m *LogMessage, v string) error {
+	d, err := parseSeconds(v)
+	if err != nil {
+		return errors.New("wrong request time format")
+	}
+	m.Latency = d
+	m.HasLatency = true
+	return nil
+}
+
+// setUpstreamLatency sums the response times of all the upstreams contacted for the request:
+// "0.010, 0.003" (several servers) or "0.001 : 0.002" (internal redirect), "-" if no upstream
+func setUpstreamLatency(m *LogMessage, v string) error {
+	var sum time.Duration
+	for _, f := range strings.FieldsFunc(v, func(r rune) bool { return r == ',' || r == ':' || r == ' ' }) {
+		if f == "-" {
+			continue
+		}
+		d, err := parseSeconds(f)
+		if err != nil {
+			return errors.New("wrong upstream response time format")
+		}
+		sum += d
+	}
+	m.UpstreamLatency = sum
+	return nil
+}
+
+// parseSeconds parses the given number of seconds with the millisecond resolution (0.123)
+func parseSeconds(str string) (time.Duration, error) {
+	f, err := strconv.ParseFloat(str, 64)
+	if err != nil || f < 0 {
+		return 0, errors.New("wrong number of seconds")
+	}
+	return time.Duration(math.Round(f*1e3)) * time.Millisecond, nil
+}
@@ -0,0 +1,255 @@
+package collector
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const nginxTimedFormat = `$remote_addr - $remote_user [$time_local] "$request" $status $body_bytes_sent "$http_referer" "$http_user_agent" $request_time $upstream_response_time`
+
+func TestCompileNginxFormat(t *testing.T) {
+	testCases := []struct {
+		name        string
+		format      string
+		expectedErr bool
+	}{
+		{
+			name:   "Combined",
+			format: nginxCombinedFormat,
+		},
+		{
+			name:   "Request time and upstream response time",
+			format: nginxTimedFormat,
+		},
+		{
+			name:   "Braced variables",
+			format: `${remote_addr} [${time_local}] "${request_method} ${request_uri} ${server_protocol}" ${status}`,
+		},
+		{
+			name:   "Ignored variables",
+			format: `$host $remote_addr [$time_local] "$request" $status "$http_x_forwarded_for" $upstream_addr $request_id`,
+		},
+		{
+			name:        "Unsupported variable",
+			format:      `$remote_addr [$time_local] "$request" $status $foo`,
+			expectedErr: true,
+		},
+		{
+			name:        "No status",
+			format:      `$remote_addr [$time_local] "$request"`,
+			expectedErr: true,
+		},
+		{
+			name:        "No request",
+			format:      `$remote_addr [$time_local] "$request_method" $status`,
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := CompileNginxFormat(tc.format)
+			if err != nil {
+				if !tc.expectedErr {
+					t.Errorf("Test case %q got not expected error: %s", tc.name, err)
+				}
+				return
+			}
+			if tc.expectedErr {
+				t.Errorf("Test case %q got no error while one is expected", tc.name)
+			}
+		})
+	}
+}
+
+func TestNginxFormatParse(t *testing.T) {
+	testCases := []struct {
+		name        string
+		format      string
+		input       string
+		expected    LogMessage
+		expectedErr bool
+	}{
+		{
+			name:   "Repo example",
+			format: `$remote_addr - $remote_user [$time_local] "$request" $status $bytes_sent`,
+			input:  `127.0.0.1 - james [09/May/2018:16:00:39 +0000] "GET /report HTTP/1.0" 200 123`,
+			expected: LogMessage{
+				Section:    "/report",
+				Method:     "GET",
+				Code:       200,
+				Time:       mustParseTime("09/May/2018:16:00:39 +0000"),
+				Protocol:   "HTTP/1.0",
+				RemoteAddr: "127.0.0.1",
+				User:       "james",
+				Bytes:      123,
+			},
+		},
+		{
+			name:   "Request time and upstream response time",
+			format: nginxTimedFormat,
+			input:  `10.0.0.1 - - [09/May/2018:16:00:39 +0000] "GET /api/user?id=1 HTTP/1.1" 502 0 "-" "curl/7.58.0" 0.125 0.010, 0.100`,
+			expected: LogMessage{
+				Section:         "/api",
+				Method:          "GET",
+				Code:            502,
+				Time:            mustParseTime("09/May/2018:16:00:39 +0000"),
+				Protocol:        "HTTP/1.1",
+				RemoteAddr:      "10.0.0.1",
+				UserAgent:       "curl/7.58.0",
+				Latency:         125 * time.Millisecond,
+				HasLatency:      true,
+				UpstreamLatency: 110 * time.Millisecond,
+			},
+		},
+		{
+			name:   "No upstream",
+			format: nginxTimedFormat,
+			input:  `10.0.0.1 - - [09/May/2018:16:00:39 +0000] "GET /static/app.js HTTP/1.1" 304 0 "http://example.com/" "curl/7.58.0" 0.000 -`,
+			expected: LogMessage{
+				Section:    "/static",
+				Method:     "GET",
+				Code:       304,
+				Time:       mustParseTime("09/May/2018:16:00:39 +0000"),
+				Protocol:   "HTTP/1.1",
+				RemoteAddr: "10.0.0.1",
+				Referer:    "http://example.com/",
+				UserAgent:  "curl/7.58.0",
+				HasLatency: true,
+			},
+		},
+		{
+			name:   "Split request and ignored variables",
+			format: `$host $remote_addr [$time_local] "$request_method $request_uri $server_protocol" $status "$http_x_forwarded_for"`,
+			input:  `example.com 10.0.0.1 [09/May/2018:16:00:39 +0000] "POST /users/1 HTTP/2.0" 201 "1.2.3.4, 5.6.7.8"`,
+			expected: LogMessage{
+				Section:    "/users",
+				Method:     "POST",
+				Code:       201,
+				Time:       mustParseTime("09/May/2018:16:00:39 +0000"),
+				Protocol:   "HTTP/2.0",
+				RemoteAddr: "10.0.0.1",
+			},
+		},
+		{
+			name:   "ISO 8601 time",
+			format: `$remote_addr $time_iso8601 "$request" $status`,
+			input:  `10.0.0.1 2018-05-09T16:00:39+00:00 "GET / HTTP/1.1" 200`,
+			expected: LogMessage{
+				Section:    "/",
+				Method:     "GET",
+				Code:       200,
+				Time:       mustParseTime("09/May/2018:16:00:39 +0000"),
+				Protocol:   "HTTP/1.1",
+				RemoteAddr: "10.0.0.1",
+			},
+		},
+		{
+			name:        "Format not matched",
+			format:      nginxTimedFormat,
+			input:       `127.0.0.1 - james [09/May/2018:16:00:39 +0000] "GET /report HTTP/1.0" 200 123`,
+			expectedErr: true,
+		},
+		{
+			name:        "Unknown http code",
+			format:      nginxCombinedFormat,
+			input:       `127.0.0.1 - james [09/May/2018:16:00:39 +0000] "GET /report HTTP/1.0" 999 123 "-" "-"`,
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			f, err := CompileNginxFormat(tc.format)
+			if err != nil {
+				t.Fatalf("Test case %q failed to compile format: %s", tc.name, err)
+			}
+			output, err := f.Parse(tc.input)
+			if err != nil {
+				if !tc.expectedErr {
+					t.Errorf("Test case %q got not expected error: %s", tc.name, err)
+				}
+				return
+			}
+			if tc.expectedErr {
+				t.Errorf("Test case %q got no error while one is expected", tc.name)
+				return
+			}
+
+			if !output.Equal(&tc.expected) {
+				t.Errorf("Test case %q: output didn't match, got %+v", tc.name, *output)
+			}
+		})
+	}
+}
+
+func TestLoadNginxFormat(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nginx")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	conf := filepath.Join(dir, "nginx.conf")
+	data := `http {
+    # log_format  main  '$remote_addr';
+    log_format  main  '$remote_addr - $remote_user [$time_local] "$request" '
+                      '$status $body_bytes_sent $request_time';
+    log_format  json escape=json '{"addr":"$remote_addr","status":$status}';
+    access_log  /var/log/nginx/access.log  main;
+}
+`
+	if err := ioutil.WriteFile(conf, []byte(data), 0644); err != nil {
+		t.Fatalf("Failed to write nginx config: %s", err)
+	}
+
+	testCases := []struct {
+		name        string
+		formatName  string
+		expected    string
+		expectedErr bool
+	}{
+		{
+			name:       "Multiline format",
+			formatName: "main",
+			expected:   `$remote_addr - $remote_user [$time_local] "$request" $status $body_bytes_sent $request_time`,
+		},
+		{
+			name:       "Escape parameter",
+			formatName: "json",
+			expected:   `{"addr":"$remote_addr","status":$status}`,
+		},
+		{
+			name:       "Predefined combined",
+			formatName: "combined",
+			expected:   nginxCombinedFormat,
+		},
+		{
+			name:        "Not found",
+			formatName:  "upstream",
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			output, err := LoadNginxFormat(conf, tc.formatName)
+			if err != nil {
+				if !tc.expectedErr {
+					t.Errorf("Test case %q got not expected error: %s", tc.name, err)
+				}
+				return
+			}
+			if tc.expectedErr {
+				t.Errorf("Test case %q got no error while one is expected", tc.name)
+				return
+			}
+			if output != tc.expected {
+				t.Errorf("Test case %q: expected %q, got %q", tc.name, tc.expected, output)
+			}
+		})
+	}
+}
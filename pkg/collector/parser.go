@@ -0,0 +1,126 @@
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"httplogmonitor/pkg/config"
+)
+
+// Parser parses the raw log entries into the log messages
+type Parser interface {
+	Parse(str string) (*LogMessage, error)
+}
+
+// SourceParser is a parser keeping its state per source of the log entries
+type SourceParser interface {
+	ParseSource(source, str string) (*LogMessage, error)
+}
+
+// ErrDirective is returned for the lines which are directives of the log format (W3C extended headers)
+// rather than log entries, they are not to be reported
+var ErrDirective = errors.New("log format directive")
+
+// ParseEntry parses the given log entry with the given parser and labels the log message with the entry's source
+// the source is passed to the parsers implementing SourceParser
+func ParseEntry(p Parser, e LogEntry) (*LogMessage, error) {
+	var msg *LogMessage
+	var err error
+	if sp, ok := p.(SourceParser); ok {
+		msg, err = sp.ParseSource(e.Source, e.Line)
+	} else {
+		msg, err = p.Parse(e.Line)
+	}
+	if err != nil {
+		return nil, err
+	}
+	msg.Source = e.Source
+	return msg, nil
+}
+
+// LogTime returns the function giving the time of the log entries parsed with the given parser
+// the replayer paces the log entries with it
+func LogTime(p Parser) func(LogEntry) (time.Time, error) {
+	return func(e LogEntry) (time.Time, error) {
+		msg, err := ParseEntry(p, e)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return msg.Time, nil
+	}
+}
+
+// CLFParser is a parser of the log entries in the common or combined log format
+type CLFParser struct{}
+
+// Parse parses the given raw log entry in the common or combined log format
+func (CLFParser) Parse(str string) (*LogMessage, error) {
+	return NewLogMessageFromLogEntry(str)
+}
+
+// NewParser returns the parser of the configured log format
+// extracting the sections of the log messages by the configured depth, routes and rules
+func NewParser(cfg *config.Config) (Parser, error) {
+	p, err := newFormatParser(cfg)
+	if err != nil {
+		return nil, err
+	}
+	s, err := NewSectioner(cfg.SectionDepth, cfg.SectionRoutes, cfg.SectionRules)
+	if err != nil {
+		return nil, err
+	}
+	return &sectionParser{parser: p, sectioner: s}, nil
+}
+
+// newFormatParser returns the parser of the configured log format:
+// common/combined, nginx log_format (given as is or by the nginx config file), apache LogFormat,
+// W3C extended, AWS load balancer, CloudFront, haproxy, envoy or json lines
+func newFormatParser(cfg *config.Config) (Parser, error) {
+	switch cfg.LogFormat {
+	case config.LogFormatCLF, config.LogFormatAuto:
+		// the format is expected to be detected beforehand, common/combined is the fallback
+		return CLFParser{}, nil
+	case config.LogFormatNginx:
+		format := cfg.NginxLogFormat
+		if len(cfg.NginxConfPath) != 0 {
+			var err error
+			format, err = LoadNginxFormat(cfg.NginxConfPath, cfg.NginxFormatName)
+			if err != nil {
+				return nil, err
+			}
+		}
+		f, err := CompileNginxFormat(format)
+		if err != nil {
+			return nil, err
+		}
+		return f, nil
+	case config.LogFormatApache:
+		f, err := CompileApacheFormat(cfg.ApacheLogFormat)
+		if err != nil {
+			return nil, err
+		}
+		return f, nil
+	case config.LogFormatW3C:
+		p, err := NewW3CExtendedParser(cfg.W3CFields)
+		if err != nil {
+			return nil, err
+		}
+		return p, nil
+	case config.LogFormatALB:
+		return ALBParser{}, nil
+	case config.LogFormatCloudFront:
+		return NewCloudFrontParser(), nil
+	case config.LogFormatHAProxy:
+		return HAProxyParser{}, nil
+	case config.LogFormatEnvoy:
+		return EnvoyParser{}, nil
+	case config.LogFormatJSON:
+		p, err := NewJSONParser(cfg.JSONFields)
+		if err != nil {
+			return nil, err
+		}
+		return p, nil
+	}
+	return nil, fmt.Errorf("unknown log format %q", cfg.LogFormat)
+}
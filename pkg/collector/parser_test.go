@@ -0,0 +1,91 @@
+package collector
+
+import (
+	"testing"
+
+	"httplogmonitor/pkg/config"
+)
+
+func TestNewParser(t *testing.T) {
+	testCases := []struct {
+		name        string
+		format      string
+		nginx       string
+		apache      string
+		input       string
+		expectedErr bool
+	}{
+		{
+			name:   "Common log format",
+			format: config.LogFormatCLF,
+			input:  `127.0.0.1 - james [09/May/2018:16:00:39 +0000] "GET /report HTTP/1.0" 200 123`,
+		},
+		{
+			name:   "Nginx log format",
+			format: config.LogFormatNginx,
+			nginx:  `$remote_addr [$time_local] "$request" $status`,
+			input:  `127.0.0.1 [09/May/2018:16:00:39 +0000] "GET /report HTTP/1.0" 200`,
+		},
+		{
+			name:   "Apache log format",
+			format: config.LogFormatApache,
+			apache: `%h %t "%r" %>s`,
+			input:  `127.0.0.1 [09/May/2018:16:00:39 +0000] "GET /report HTTP/1.0" 200`,
+		},
+		{
+			name:   "JSON log format",
+			format: config.LogFormatJSON,
+			input:  `{"time_local":"09/May/2018:16:00:39 +0000","request":"GET /report HTTP/1.0","status":200}`,
+		},
+		{
+			name:        "Wrong nginx log format",
+			format:      config.LogFormatNginx,
+			nginx:       `$remote_addr $status`,
+			expectedErr: true,
+		},
+		{
+			name:        "Unknown log format",
+			format:      "xml",
+			expectedErr: true,
+		},
+	}
+
+	expected := LogMessage{
+		Section:  "/report",
+		Method:   "GET",
+		Code:     200,
+		Protocol: "HTTP/1.0",
+		Time:     mustParseTime("09/May/2018:16:00:39 +0000"),
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := config.NewDefault()
+			cfg.LogFormat = tc.format
+			cfg.NginxLogFormat = tc.nginx
+			cfg.ApacheLogFormat = tc.apache
+
+			p, err := NewParser(cfg)
+			if err != nil {
+				if !tc.expectedErr {
+					t.Errorf("Test case %q got not expected error: %s", tc.name, err)
+				}
+				return
+			}
+			if tc.expectedErr {
+				t.Errorf("Test case %q got no error while one is expected", tc.name)
+				return
+			}
+
+			output, err := p.Parse(tc.input)
+			if err != nil {
+				t.Fatalf("Test case %q failed to parse: %s", tc.name, err)
+			}
+			// only the fields common to all the formats are compared
+			output.RemoteAddr, output.User, output.Bytes = "", "", 0
+			if !output.Equal(&expected) {
+				t.Errorf("Test case %q: output didn't match, got %+v", tc.name, *output)
+			}
+		})
+	}
+}
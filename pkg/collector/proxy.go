@@ -0,0 +1,125 @@
+package collector
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HAProxy HTTP log format example (option httplog, the syslog header is optional):
+// Feb  6 12:14:14 localhost haproxy[14389]: 10.0.1.2:33317 [06/Feb/2009:12:14:14.655] http-in static/srv1 10/0/30/69/109 200 2750 - - ---- 1/1/1/1/0 0/0 {1wt.eu} {} "GET /index.html HTTP/1.1"
+// Envoy default access log format example:
+// [2016-04-15T20:17:00.310Z] "POST /api/v1/locations HTTP/2" 204 - 154 0 226 100 "10.0.35.28" "nsq2http" "cc21d9b0-cf5c-432b-8c7e-98aeb7988cd2" "locations" "tcp://10.0.2.1:80"
+var (
+	haproxyLogEntryRegExp = regexp.MustCompile(`^(?:.*?haproxy\[\d+\]: )?(\S+?)(?::\d+)? \[(\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2}\.\d{3})\] \S+ \S+ (-?\d+)/(-?\d+)/(-?\d+)/(-?\d+)/\+?(-?\d+) (-?\d+) \+?(\d+) \S+ \S+ (\S{4}) \d+/\d+/\d+/\d+/\+?\d+ \d+/\d+(?: \{.*?\}){0,2} "(.*)"$`)
+	envoyLogEntryRegExp   = regexp.MustCompile(`^\[(\S+)\] "(\S+) (\S+) (\S+)" (\d+) (\S+) (\d+) (\d+) (\d+) (\S+) "(.*?)" "(.*?)" "(.*?)" "(.*?)" "(.*?)"$`)
+)
+
+// haproxyTimeLayout is the layout of the accept date of haproxy (local time)
+const haproxyTimeLayout = "02/Jan/2006:15:04:05.000"
+
+// HAProxyParser is a parser of the HAProxy HTTP log entries
+// the latency is the total session time (Tt), the upstream latency is the server response time (Tr)
+// the flags are the first 2 characters of the termination state (empty for the normal termination)
+type HAProxyParser struct{}
+
+// Parse parses the given haproxy log entry
+func (HAProxyParser) Parse(str string) (*LogMessage, error) {
+	m := haproxyLogEntryRegExp.FindStringSubmatch(str)
+	if m == nil {
+		return nil, errors.New("haproxy log entry format not matched")
+	}
+
+	msg := &LogMessage{RemoteAddr: m[1]}
+	t, err := time.ParseInLocation(haproxyTimeLayout, m[2], time.Local)
+	if err != nil {
+		return nil, errors.New("wrong time format")
+	}
+	msg.Time = t
+
+	// the timers are -1 if the corresponding step wasn't reached
+	if tr, _ := strconv.Atoi(m[6]); tr > 0 {
+		msg.UpstreamLatency = time.Duration(tr) * time.Millisecond
+	}
+	if tt, _ := strconv.Atoi(m[7]); tt >= 0 {
+		msg.Latency = time.Duration(tt) * time.Millisecond
+		msg.HasLatency = true
+	}
+
+	if err := msg.parseCode(m[8]); err != nil {
+		return nil, err
+	}
+	msg.Bytes, err = strconv.Atoi(m[9])
+	if err != nil {
+		return nil, err
+	}
+
+	if st := m[10][:2]; st != "--" {
+		msg.Flags = st
+	}
+
+	if err := msg.parseRequest(m[11]); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// EnvoyParser is a parser of the Envoy access log entries in the default format
+// the remote address is the first address of X-Forwarded-For, the flags are the response flags (UF, UH, ...)
+// the status 0 (no response sent, e.g. downstream disconnection) is accepted
+type EnvoyParser struct{}
+
+// Parse parses the given envoy log entry
+func (EnvoyParser) Parse(str string) (*LogMessage, error) {
+	m := envoyLogEntryRegExp.FindStringSubmatch(str)
+	if m == nil {
+		return nil, errors.New("envoy log entry format not matched")
+	}
+
+	msg := &LogMessage{
+		Method:   m[2],
+		Protocol: m[4],
+	}
+	t, err := time.Parse(time.RFC3339Nano, m[1])
+	if err != nil {
+		return nil, errors.New("wrong time format")
+	}
+	msg.Time = t
+
+	if err := msg.parsePath(m[3]); err != nil {
+		return nil, err
+	}
+	if m[5] != "0" {
+		if err := msg.parseCode(m[5]); err != nil {
+			return nil, err
+		}
+	}
+	msg.Flags = dashToEmpty(m[6])
+
+	msg.Bytes, err = strconv.Atoi(m[8])
+	if err != nil {
+		return nil, err
+	}
+
+	d, err := strconv.Atoi(m[9])
+	if err != nil {
+		return nil, err
+	}
+	msg.Latency = time.Duration(d) * time.Millisecond
+	msg.HasLatency = true
+	if m[10] != "-" {
+		d, err := strconv.Atoi(m[10])
+		if err != nil {
+			return nil, errors.New("wrong upstream service time format")
+		}
+		msg.UpstreamLatency = time.Duration(d) * time.Millisecond
+	}
+
+	if xff := dashToEmpty(m[11]); len(xff) != 0 {
+		msg.RemoteAddr = strings.TrimSpace(strings.Split(xff, ",")[0])
+	}
+	msg.UserAgent = dashToEmpty(m[12])
+	return msg, nil
+}
@@ -0,0 +1,190 @@
+package collector
+
+import (
+	"testing"
+	"time"
+)
+
+func TestProxyParsers(t *testing.T) {
+	testCases := []struct {
+		name     string
+		parser   Parser
+		file     string
+		expected []LogMessage
+	}{
+		{
+			name:   "HAProxy",
+			parser: HAProxyParser{},
+			file:   "haproxy.log",
+			expected: []LogMessage{
+				{
+					Section:         "/index.html",
+					Method:          "GET",
+					Protocol:        "HTTP/1.1",
+					Code:            200,
+					Time:            mustParseLocal("06/Feb/2009:12:14:14.655"),
+					RemoteAddr:      "10.0.1.2",
+					Bytes:           2750,
+					Latency:         109 * time.Millisecond,
+					HasLatency:      true,
+					UpstreamLatency: 69 * time.Millisecond,
+				},
+				{
+					Section:    "/api",
+					Method:     "GET",
+					Protocol:   "HTTP/1.1",
+					Code:       503,
+					Time:       mustParseLocal("06/Feb/2009:12:14:15.001"),
+					RemoteAddr: "10.0.1.3",
+					Bytes:      212,
+					Latency:    3001 * time.Millisecond,
+					HasLatency: true,
+					Flags:      "SC",
+				},
+				{
+					Section:    "/api",
+					Method:     "POST",
+					Protocol:   "HTTP/1.1",
+					Code:       502,
+					Time:       mustParseLocal("06/Feb/2009:12:14:16.120"),
+					RemoteAddr: "10.0.1.4",
+					Bytes:      204,
+					Latency:    5 * time.Millisecond,
+					HasLatency: true,
+					Flags:      "SH",
+				},
+			},
+		},
+		{
+			name:   "Envoy",
+			parser: EnvoyParser{},
+			file:   "envoy.log",
+			expected: []LogMessage{
+				{
+					Section:         "/api",
+					Method:          "POST",
+					Protocol:        "HTTP/2",
+					Code:            204,
+					Time:            mustParseRFC3339("2016-04-15T20:17:00.310Z"),
+					RemoteAddr:      "10.0.35.28",
+					UserAgent:       "nsq2http",
+					Latency:         226 * time.Millisecond,
+					HasLatency:      true,
+					UpstreamLatency: 100 * time.Millisecond,
+				},
+				{
+					Section:    "/users",
+					Method:     "GET",
+					Protocol:   "HTTP/1.1",
+					Code:       503,
+					Time:       mustParseRFC3339("2016-04-15T20:17:01Z"),
+					Bytes:      91,
+					UserAgent:  "curl/7.58.0",
+					Latency:    1003 * time.Millisecond,
+					HasLatency: true,
+					Flags:      "UF,URX",
+				},
+				{
+					Section:    "/health",
+					Method:     "GET",
+					Protocol:   "HTTP/1.1",
+					Code:       503,
+					Time:       mustParseRFC3339("2016-04-15T20:17:02.5Z"),
+					RemoteAddr: "10.0.0.9",
+					Bytes:      19,
+					UserAgent:  "kube-probe/1.15",
+					Flags:      "UH",
+					HasLatency: true,
+				},
+				{
+					Section:    "/stream",
+					Method:     "GET",
+					Protocol:   "HTTP/1.1",
+					Time:       mustParseRFC3339("2016-04-15T20:17:03Z"),
+					Latency:    15 * time.Second,
+					HasLatency: true,
+					Flags:      "DC",
+				},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			output := parseTestFile(t, tc.parser, tc.file)
+			if len(output) != len(tc.expected) {
+				t.Fatalf("Test case %q: expected %d log messages, got %d", tc.name, len(tc.expected), len(output))
+			}
+			for i := range output {
+				if !output[i].Equal(&tc.expected[i]) {
+					t.Errorf("Test case %q: log message %d didn't match, got %+v", tc.name, i, *output[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSummaryFlags(t *testing.T) {
+	sum := NewSummary(2, 100)
+	sum.Add(&LogMessage{Section: "/api", Method: "GET", Code: 503, Flags: "UF,URX"})
+	sum.Add(&LogMessage{Section: "/api", Method: "GET", Code: 503, Flags: "UF,URX"})
+	sum.Add(&LogMessage{Section: "/health", Method: "GET", Code: 503, Flags: "UH"})
+	sum.Add(&LogMessage{Section: "/", Method: "GET", Code: 200})
+	sum.CalcTraffic(1)
+
+	expectedFormat := `
+-------------TOP SECTIONS-------------
+     Section          Number of hits  
+-----------------    -----------------
+/api                                 2
+/                                    1
+
+----------TOP ERROR SECTIONS----------
+Section    1xx / 2xx / 3xx / 4xx / 5xx
+-------    ---------------------------
+/api                 0 / 0 / 0 / 0 / 2
+/health              0 / 0 / 0 / 0 / 1
+
+-------------STATUS CODES-------------
+     Status           Number of hits  
+-----------------    -----------------
+200                                  1
+503                                  3
+
+---------------METHODS----------------
+     Method           Number of hits  
+-----------------    -----------------
+GET                                  4
+
+-----------TOP PROXY FLAGS------------
+Status and flags      Number of hits  
+-----------------    -----------------
+503 UF,URX                           2
+503 UH                               1
+
+---------------SUMMARY----------------
+         Detail                Value  
+-------------------------    ---------
+Total hits                           4
+Traffic (per second)                 4
+Total informational (1xx)            0
+Total success                        1
+Total redirects                      0
+Total errors                         3
+Total client errors (4xx)            0
+Total server errors (5xx)            3
+`
+	gotFormat := sum.Format()
+
+	if expectedFormat != gotFormat {
+		t.Fatalf("Expected format %s, got format %s", expectedFormat, gotFormat)
+	}
+}
+
+func mustParseLocal(s string) time.Time {
+	t, err := time.ParseInLocation(haproxyTimeLayout, s, time.Local)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
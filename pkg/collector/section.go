@@ -0,0 +1,139 @@
+package collector
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"httplogmonitor/pkg/config"
+)
+
+// Sectioner extracts the sections from the request paths:
+// the paths matching a route template are counted as the template,
+// the other ones are rewritten by the rules and cut to the given depth
+type Sectioner struct {
+	depth  int
+	routes []route
+	rules  []sectionRule
+}
+
+// route is a compiled route template
+type route struct {
+	template string
+	regexp   *regexp.Regexp
+}
+
+// sectionRule is a compiled regexp=replacement rule
+type sectionRule struct {
+	regexp      *regexp.Regexp
+	replacement string
+}
+
+// NewSectioner returns a sectioner keeping the given number of path segments (0 for the whole path)
+// routes are the templates with :name segments matching any segment and a trailing * matching the rest of the path,
+// rules are the regexp=replacement pairs applied in order to the whole path
+func NewSectioner(depth int, routes, rules []string) (*Sectioner, error) {
+	if depth < 0 {
+		return nil, fmt.Errorf("negative section depth %d", depth)
+	}
+	s := &Sectioner{depth: depth}
+	for _, t := range routes {
+		re, err := compileRoute(t)
+		if err != nil {
+			return nil, err
+		}
+		s.routes = append(s.routes, route{template: t, regexp: re})
+	}
+	for _, r := range rules {
+		expr, repl, err := config.SplitSectionRule(r)
+		if err != nil {
+			return nil, err
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("wrong section rule %q: %s", r, err)
+		}
+		s.rules = append(s.rules, sectionRule{regexp: re, replacement: repl})
+	}
+	return s, nil
+}
+
+// compileRoute compiles the given route template into an anchored regexp
+func compileRoute(template string) (*regexp.Regexp, error) {
+	if !strings.HasPrefix(template, "/") {
+		return nil, fmt.Errorf("route %q must start with /", template)
+	}
+	segs := strings.Split(template[1:], "/")
+	expr := strings.Builder{}
+	expr.WriteString("^")
+	for i, seg := range segs {
+		switch {
+		case seg == "*" && i == len(segs)-1:
+			expr.WriteString("(?:/.*)?")
+			continue
+		case seg == "*":
+			return nil, fmt.Errorf("route %q: * is only allowed as the last segment", template)
+		case strings.HasPrefix(seg, ":"):
+			expr.WriteString("/[^/]+")
+		default:
+			expr.WriteString("/" + regexp.QuoteMeta(seg))
+		}
+	}
+	expr.WriteString("/?$")
+	return regexp.Compile(expr.String())
+}
+
+// Section returns the section of the given request path
+func (s *Sectioner) Section(path string) string {
+	for _, r := range s.routes {
+		if r.regexp.MatchString(path) {
+			return r.template
+		}
+	}
+	for _, r := range s.rules {
+		path = r.regexp.ReplaceAllString(path, r.replacement)
+	}
+	return cutPath(path, s.depth)
+}
+
+// cutPath keeps the given number of segments of the given path, all of them if depth is 0
+// the trailing slash is dropped but for the root path
+// the root path is returned for the paths not starting with a slash (rewritten by the section rules)
+func cutPath(path string, depth int) string {
+	if !strings.HasPrefix(path, "/") {
+		return "/"
+	}
+	if depth > 0 {
+		// skipping first slash
+		segs := strings.SplitN(path[1:], "/", depth+1)
+		if len(segs) > depth {
+			segs = segs[:depth]
+		}
+		path = "/" + strings.Join(segs, "/")
+	}
+	if len(path) > 1 {
+		path = strings.TrimSuffix(path, "/")
+	}
+	return path
+}
+
+// sectionParser is a parser of the given log format extracting the sections with the given sectioner
+type sectionParser struct {
+	parser    Parser
+	sectioner *Sectioner
+}
+
+// Parse parses the given log entry of an unnamed source
+func (p *sectionParser) Parse(str string) (*LogMessage, error) {
+	return p.ParseSource("", str)
+}
+
+// ParseSource parses the given log entry of the given source and sets its section
+func (p *sectionParser) ParseSource(source, str string) (*LogMessage, error) {
+	msg, err := ParseEntry(p.parser, LogEntry{Source: source, Line: str})
+	if err != nil {
+		return nil, err
+	}
+	msg.Section = p.sectioner.Section(msg.path)
+	return msg, nil
+}
@@ -0,0 +1,203 @@
+package collector
+
+import (
+	"testing"
+
+	"httplogmonitor/pkg/config"
+)
+
+func TestSectioner(t *testing.T) {
+	testCases := []struct {
+		name     string
+		depth    int
+		routes   []string
+		rules    []string
+		input    string
+		expected string
+	}{
+		{
+			name:     "First segment",
+			depth:    1,
+			input:    "/api/v1/users",
+			expected: "/api",
+		},
+		{
+			name:     "Root",
+			depth:    1,
+			input:    "/",
+			expected: "/",
+		},
+		{
+			name:     "Depth",
+			depth:    2,
+			input:    "/api/v1/users",
+			expected: "/api/v1",
+		},
+		{
+			name:     "Depth greater than the path",
+			depth:    3,
+			input:    "/api/v1/",
+			expected: "/api/v1",
+		},
+		{
+			name:     "Whole path",
+			depth:    0,
+			input:    "/api/v2/orders/",
+			expected: "/api/v2/orders",
+		},
+		{
+			name:     "Route template",
+			depth:    1,
+			routes:   []string{"/users/:id", "/users/:id/orders/:id"},
+			input:    "/users/123/orders/9",
+			expected: "/users/:id/orders/:id",
+		},
+		{
+			name:     "Route template with the trailing wildcard",
+			depth:    2,
+			routes:   []string{"/static/*"},
+			input:    "/static/css/main.css",
+			expected: "/static/*",
+		},
+		{
+			name:     "Route template not matched",
+			depth:    2,
+			routes:   []string{"/users/:id"},
+			input:    "/users/123/orders/9",
+			expected: "/users/123",
+		},
+		{
+			name:     "Rules",
+			depth:    0,
+			rules:    []string{"/[0-9]+(/|$)=/:id$1", "/[0-9]+(/|$)=/:id$1"},
+			input:    "/users/123/9/orders",
+			expected: "/users/:id/:id/orders",
+		},
+		{
+			name:     "Rules before the depth",
+			depth:    2,
+			rules:    []string{"^/v[0-9]+="},
+			input:    "/v2/users/123",
+			expected: "/users/123",
+		},
+		{
+			name:     "Rule rewriting to an empty path",
+			depth:    1,
+			rules:    []string{"^.*$="},
+			input:    "/users/123",
+			expected: "/",
+		},
+		{
+			name:     "Rule rewriting to a relative path",
+			depth:    0,
+			rules:    []string{"^/api/="},
+			input:    "/api/users",
+			expected: "/",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, err := NewSectioner(tc.depth, tc.routes, tc.rules)
+			if err != nil {
+				t.Fatalf("Test case %q got an unexpected error: %s", tc.name, err)
+			}
+			if output := s.Section(tc.input); output != tc.expected {
+				t.Errorf("Test case %q: expected %q, got %q", tc.name, tc.expected, output)
+			}
+		})
+	}
+}
+
+func TestNewSectionerErrors(t *testing.T) {
+	testCases := []struct {
+		name   string
+		depth  int
+		routes []string
+		rules  []string
+	}{
+		{
+			name:  "Negative depth",
+			depth: -1,
+		},
+		{
+			name:   "Relative route",
+			routes: []string{"users/:id"},
+		},
+		{
+			name:   "Wildcard in the middle",
+			routes: []string{"/static/*/main.css"},
+		},
+		{
+			name:  "No replacement",
+			rules: []string{"/[0-9]+"},
+		},
+		{
+			name:  "Wrong regexp",
+			rules: []string{"/[0-9+=/:id"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := NewSectioner(tc.depth, tc.routes, tc.rules); err == nil {
+				t.Errorf("Test case %q got no error while one is expected", tc.name)
+			}
+		})
+	}
+}
+
+func TestParserSections(t *testing.T) {
+	cfg := config.NewDefault()
+	cfg.LogFormat = config.LogFormatCLF
+	cfg.SectionDepth = 2
+	cfg.SectionRoutes = []string{"/users/:id/orders/:id"}
+	p, err := NewParser(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create the parser: %s", err)
+	}
+
+	testCases := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "Depth",
+			input:    `127.0.0.1 - - [09/May/2018:16:00:39 +0000] "GET /api/v1/users HTTP/1.0" 200 123`,
+			expected: "/api/v1",
+		},
+		{
+			name:     "Query string stripped",
+			input:    `127.0.0.1 - - [09/May/2018:16:00:39 +0000] "GET /search?q=a/b HTTP/1.0" 200 123`,
+			expected: "/search",
+		},
+		{
+			name:     "Percent-encoding decoded",
+			input:    `127.0.0.1 - - [09/May/2018:16:00:39 +0000] "GET /caf%C3%A9/menu%20du%20jour/1 HTTP/1.0" 200 123`,
+			expected: "/café/menu du jour",
+		},
+		{
+			name:     "Wrong percent-encoding kept",
+			input:    `127.0.0.1 - - [09/May/2018:16:00:39 +0000] "GET /100%/sure HTTP/1.0" 200 123`,
+			expected: "/100%/sure",
+		},
+		{
+			name:     "Route template",
+			input:    `127.0.0.1 - - [09/May/2018:16:00:39 +0000] "GET /users/123/orders/9?full=1 HTTP/1.0" 200 123`,
+			expected: "/users/:id/orders/:id",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			msg, err := p.Parse(tc.input)
+			if err != nil {
+				t.Fatalf("Test case %q got an unexpected error: %s", tc.name, err)
+			}
+			if msg.Section != tc.expected {
+				t.Errorf("Test case %q: expected %q, got %q", tc.name, tc.expected, msg.Section)
+			}
+		})
+	}
+}
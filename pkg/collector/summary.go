@@ -2,68 +2,153 @@ package collector
 
 import (
 	"errors"
+	"fmt"
 	"math"
 	"net/http"
+	"net/url"
 	"regexp"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	"httplogmonitor/pkg/printer"
+	"httplogmonitor/pkg/sketch"
 )
 
-// Example: 127.0.0.1 - james [09/May/2018:16:00:39 +0000] "GET /report HTTP/1.0" 200 123
+// Common log format example:
+// 127.0.0.1 - james [09/May/2018:16:00:39 +0000] "GET /report HTTP/1.0" 200 123
+// Combined log format example (common one followed by the referer and the user agent):
+// 127.0.0.1 - james [09/May/2018:16:00:39 +0000] "GET /report HTTP/1.0" 200 123 "http://example.com/" "curl/7.58.0"
 var (
-	w3cLogEntryRegExp = regexp.MustCompile(`^.+? .+? .+? \[.+?\] "(.+?)" (\d+) \d+$`)
-	methodPathRegExp  = regexp.MustCompile(`^(\w+) (/.*?) `)
+	w3cLogEntryRegExp = regexp.MustCompile(`^(\S+) (\S+) (\S+) \[(.+?)\] "((?:[^"\\]|\\.)*)" (\d+) (\d+|-)(?: "((?:[^"\\]|\\.)*)" "((?:[^"\\]|\\.)*)")?$`)
+	requestRegExp     = regexp.MustCompile(`^(\w+) (/.*?) (\S+)$`)
 )
 
+// timeLocalLayout is the layout of the log entry time (nginx's $time_local, apache's %t)
+const timeLocalLayout = "02/Jan/2006:15:04:05 -0700"
+
+// maxKeyLen is the maximum length of the keys (user agents, referrers, ...) displayed in the top tables
+const maxKeyLen = 64
+
+// summaryTimeFormat is the format of the summary interval end
+const summaryTimeFormat = "2006-01-02 15:04:05"
+
 // LogMessage represents the parsed log entry
 // with only the data we are interested in
+// absent fields ("-" in the log entry) are left empty
 type LogMessage struct {
-	Section string
-	Method  string
-	Code    int
+	Section    string
+	Method     string
+	Protocol   string
+	Code       int
+	Time       time.Time
+	RemoteAddr string
+	User       string
+	Bytes      int
+	Referer    string
+	UserAgent  string
+	// Flags are the termination state (haproxy) or the response flags (envoy) of the proxies
+	Flags string
+	// UpstreamCode is the status code of the upstream server (load balancer's target), 0 if unknown
+	UpstreamCode int
+	// Latency is the request processing time, UpstreamLatency is the response time of the upstream servers
+	Latency         time.Duration
+	UpstreamLatency time.Duration
+	// HasLatency is set if the log format gives the latency
+	HasLatency bool
+	// Source is the path of the file the log entry was read from
+	Source string
+	// path is the request path the section is extracted from
+	path string
 }
 
 // NewLogMessageFromLogEntry parses the raw log entry validating it therefore
 // and extracts only the interesting fields
+// both common and combined log formats are accepted
 func NewLogMessageFromLogEntry(str string) (*LogMessage, error) {
 	msg := &LogMessage{}
-	m1 := w3cLogEntryRegExp.FindStringSubmatch(str)
-	if m1 != nil && len(m1) == 3 {
-		// got request and code
-		code, err := strconv.Atoi(m1[2])
+	m := w3cLogEntryRegExp.FindStringSubmatch(str)
+	if m == nil || len(m) != 10 {
+		return nil, errors.New("w3c log entry format not matched")
+	}
+
+	msg.RemoteAddr = m[1]
+	msg.User = dashToEmpty(m[3])
+
+	t, err := time.Parse(timeLocalLayout, m[4])
+	if err != nil {
+		return nil, errors.New("wrong time format")
+	}
+	msg.Time = t
+
+	if err := msg.parseRequest(m[5]); err != nil {
+		return nil, err
+	}
+
+	if err := msg.parseCode(m[6]); err != nil {
+		return nil, err
+	}
+
+	// no body sent is given as -
+	if m[7] != "-" {
+		msg.Bytes, err = strconv.Atoi(m[7])
 		if err != nil {
 			return nil, err
 		}
-		if st := http.StatusText(code); len(st) == 0 {
-			return nil, errors.New("unknown http code")
-		}
-		msg.Code = code
+	}
 
-		m2 := methodPathRegExp.FindStringSubmatch(m1[1])
-		if m2 != nil && len(m2) == 3 {
-			// got method and section
-			msg.Method = m2[1]
+	// empty for the common log format
+	msg.Referer = dashToEmpty(unescapeQuoted(m[8]))
+	msg.UserAgent = dashToEmpty(unescapeQuoted(m[9]))
 
-			// skipping first slash as cheched by regexp
-			if i := strings.Index(m2[2][1:], "/"); i == -1 {
-				// only section in path
-				msg.Section = m2[2]
-			} else {
-				// cut all but section
-				msg.Section = m2[2][0 : i+1]
-			}
-		} else {
-			return nil, errors.New("method and path format not matched")
-		}
-	} else {
-		return nil, errors.New("w3c log entry format not matched")
-	}
 	return msg, nil
 }
 
+// parseRequest extracts the method, section and protocol from the given request line
+func (m *LogMessage) parseRequest(req string) error {
+	r := requestRegExp.FindStringSubmatch(req)
+	if r == nil || len(r) != 4 {
+		return errors.New("method and path format not matched")
+	}
+	// got method, section and protocol
+	m.Method = r[1]
+	m.Protocol = r[3]
+	return m.parsePath(r[2])
+}
+
+// parsePath stores the given request path without its query string and percent-decoded
+// and extracts the section from it (first path segment)
+func (m *LogMessage) parsePath(path string) error {
+	if !strings.HasPrefix(path, "/") {
+		return errors.New("path format not matched")
+	}
+	if i := strings.IndexAny(path, "?#"); i != -1 {
+		path = path[:i]
+	}
+	// the badly encoded paths are kept as is
+	if p, err := url.PathUnescape(path); err == nil {
+		path = p
+	}
+	m.path = path
+	m.Section = cutPath(path, 1)
+	return nil
+}
+
+// parseCode validates the given http status code
+func (m *LogMessage) parseCode(str string) error {
+	code, err := strconv.Atoi(str)
+	if err != nil {
+		return err
+	}
+	if st := http.StatusText(code); len(st) == 0 {
+		return errors.New("unknown http code")
+	}
+	m.Code = code
+	return nil
+}
+
 // Equal compares the log message field by field to the given one
 func (m *LogMessage) Equal(other *LogMessage) bool {
 	if m.Section != other.Section {
@@ -72,109 +157,627 @@ func (m *LogMessage) Equal(other *LogMessage) bool {
 	if m.Method != other.Method {
 		return false
 	}
+	if m.Protocol != other.Protocol {
+		return false
+	}
 	if m.Code != other.Code {
 		return false
 	}
+	if !m.Time.Equal(other.Time) {
+		return false
+	}
+	if m.RemoteAddr != other.RemoteAddr {
+		return false
+	}
+	if m.User != other.User {
+		return false
+	}
+	if m.Bytes != other.Bytes {
+		return false
+	}
+	if m.Referer != other.Referer {
+		return false
+	}
+	if m.UserAgent != other.UserAgent {
+		return false
+	}
+	if m.Flags != other.Flags {
+		return false
+	}
+	if m.UpstreamCode != other.UpstreamCode {
+		return false
+	}
+	if m.Latency != other.Latency {
+		return false
+	}
+	if m.UpstreamLatency != other.UpstreamLatency {
+		return false
+	}
+	if m.HasLatency != other.HasLatency {
+		return false
+	}
 	return true
 }
 
+// dashToEmpty returns an empty string for the absent value ("-")
+func dashToEmpty(str string) string {
+	if str == "-" {
+		return ""
+	}
+	return str
+}
+
+// unescapeQuoted removes the escaping of the quoted field:
+// \xHH (nginx) is decoded, the backslash of other escaped characters (apache) is dropped
+func unescapeQuoted(str string) string {
+	if strings.IndexByte(str, '\\') == -1 {
+		return str
+	}
+	b := strings.Builder{}
+	for i := 0; i < len(str); i++ {
+		if str[i] == '\\' && i+1 < len(str) {
+			if str[i+1] == 'x' && i+3 < len(str) {
+				if c, err := strconv.ParseUint(str[i+2:i+4], 16, 8); err == nil {
+					b.WriteByte(byte(c))
+					i += 3
+					continue
+				}
+			}
+			i++
+		}
+		b.WriteByte(str[i])
+	}
+	return b.String()
+}
+
 const (
-	hitsKey     = "hits"
-	successKey  = "success"
-	redirectKey = "redirect"
-	errorsKey   = "errors"
-	trafficKey  = "traffic"
+	hitsKey          = "hits"
+	informationalKey = "informational"
+	successKey       = "success"
+	redirectKey      = "redirect"
+	errorsKey        = "errors"
+	// client (4xx) and server (5xx) errors
+	clientErrorsKey = "client_errors"
+	serverErrorsKey = "server_errors"
+	trafficKey      = "traffic"
+	bytesKey        = "bytes"
+	bandwidthKey    = "bandwidth"
+	// estimated unique clients and visitors (client + user agent) of the summary interval
+	uniqueClientsKey  = "unique_clients"
+	uniqueVisitorsKey = "unique_visitors"
+	// estimated unique clients and visitors of the rolling hour and day, set by the collector
+	hourClientsKey  = "hour_clients"
+	hourVisitorsKey = "hour_visitors"
+	dayClientsKey   = "day_clients"
+	dayVisitorsKey  = "day_visitors"
 )
 
 // Summary represents the whole summary to be displayed every summary interval
 // is made of 2 parts: top hitted sections and summary of interesting stats for the past summary interval
+// top hitted sections are also given per source if the log entries come from more than one file
+// top referrers and user agents are given if the log entries are in the combined log format
+// top statuses with the proxy flags are given if the log entries come from the proxies (haproxy, envoy)
+// latency percentiles overall and of the top sections are given if the log entries have latencies
+// the bytes sent and the top sections and clients by bandwidth are given if the log entries have byte counts
+// hits by status code and by method are always given, hits by status class of the top sections with errors if any
+// top paths and clients are given if the log entries have them
+// with the estimated unique clients and visitors (client + user agent) of the interval (and the rolling hour and day if set)
+// the top tables track a bounded number of keys so their counts are estimates given with their maximum error
+// the hits, errors and top sections are given with their trend if the summary is compared to the previous ones
+// the summaries are mergeable into the ones of the longer aggregation windows given under their own heading
 type Summary struct {
-	Sections map[string]int
-	Sum      map[string]int
-	topNum   int
+	Sections *sketch.TopK
+	// Paths are the hits by request path, Clients by client address
+	Paths   *sketch.TopK
+	Clients *sketch.TopK
+	// Codes are the hits by status code, Methods by request method
+	Codes   map[string]int
+	Methods *sketch.TopK
+	// SectionClasses are the hits of each section by status class (2xx, 3xx, 4xx, 5xx)
+	SectionClasses map[string]map[string]int
+	Sources        map[string]*sketch.TopK
+	Referers       *sketch.TopK
+	UserAgents     *sketch.TopK
+	Flags          map[string]int
+	// SectionBytes and ClientBytes are the bytes sent by section and by client address
+	SectionBytes *sketch.TopK
+	ClientBytes  *sketch.TopK
+	Sum          map[string]int
+	// Latency is the histogram of the latencies of all the log messages, SectionLatencies of the ones of each section
+	Latency          *sketch.Histogram
+	SectionLatencies map[string]*sketch.Histogram
+	// UniqueClients and UniqueVisitors estimate the distinct client addresses and (client address, user agent) pairs
+	UniqueClients  *sketch.HyperLogLog
+	UniqueVisitors *sketch.HyperLogLog
+	// Trend is the baseline the hits, errors and top sections are compared to, nil if there is no previous summary
+	Trend *Trend
+	// Window is the heading of the aggregation window summarized (total, last 5m, ...), empty for the summary interval
+	Window string
+	// Time is the end of the summary interval, not displayed if not set
+	Time   time.Time
+	topNum int
+	// capacity is the number of the keys tracked by each top table
+	capacity int
 }
 
 // NewSummary returns a new instance of Summary with given limit for most hitted sections
-func NewSummary(top int) *Summary {
+// and number of the keys tracked by each top table
+func NewSummary(top, capacity int) *Summary {
 	return &Summary{
-		Sections: map[string]int{},
-		Sum:      map[string]int{},
-		topNum:   top,
+		Sections:         sketch.NewTopK(capacity),
+		Paths:            sketch.NewTopK(capacity),
+		Clients:          sketch.NewTopK(capacity),
+		Codes:            map[string]int{},
+		Methods:          sketch.NewTopK(capacity),
+		SectionClasses:   map[string]map[string]int{},
+		Sources:          map[string]*sketch.TopK{},
+		Referers:         sketch.NewTopK(capacity),
+		UserAgents:       sketch.NewTopK(capacity),
+		Flags:            map[string]int{},
+		SectionBytes:     sketch.NewTopK(capacity),
+		ClientBytes:      sketch.NewTopK(capacity),
+		Sum:              map[string]int{},
+		Latency:          sketch.NewHistogram(),
+		SectionLatencies: map[string]*sketch.Histogram{},
+		UniqueClients:    sketch.NewHyperLogLog(),
+		UniqueVisitors:   sketch.NewHyperLogLog(),
+		topNum:           top,
+		capacity:         capacity,
 	}
 }
 
 // Add adds the stats of the given log message to the summary
 func (s *Summary) Add(m *LogMessage) {
 	s.Sum[hitsKey]++
-	s.Sections[m.Section]++
+	if evicted, ok := s.Sections.Add(m.Section, 1); ok {
+		// the stats of the sections no longer tracked are dropped to keep the memory bounded
+		delete(s.SectionClasses, evicted)
+		delete(s.SectionLatencies, evicted)
+	}
+	if len(m.path) != 0 {
+		s.Paths.Add(m.path, 1)
+	}
+	if len(m.RemoteAddr) != 0 {
+		s.Clients.Add(m.RemoteAddr, 1)
+		s.UniqueClients.Add(m.RemoteAddr)
+		s.UniqueVisitors.Add(visitorKey(m))
+	}
+	// no response sent (envoy) is counted as 000
+	s.Codes[fmt.Sprintf("%03d", m.Code)]++
+	if len(m.Method) != 0 {
+		s.Methods.Add(m.Method, 1)
+	}
+	if _, ok := s.SectionClasses[m.Section]; !ok {
+		s.SectionClasses[m.Section] = map[string]int{}
+	}
+	s.SectionClasses[m.Section][statusClass(m.Code)]++
+	if len(m.Source) != 0 {
+		if _, ok := s.Sources[m.Source]; !ok {
+			s.Sources[m.Source] = sketch.NewTopK(s.capacity)
+		}
+		s.Sources[m.Source].Add(m.Section, 1)
+	}
+	if len(m.Referer) != 0 {
+		s.Referers.Add(m.Referer, 1)
+	}
+	if len(m.UserAgent) != 0 {
+		s.UserAgents.Add(m.UserAgent, 1)
+	}
+	if len(m.Flags) != 0 {
+		s.Flags[strconv.Itoa(m.Code)+" "+m.Flags]++
+	}
+	if m.Bytes != 0 {
+		s.Sum[bytesKey] += m.Bytes
+		s.SectionBytes.Add(m.Section, m.Bytes)
+		if len(m.RemoteAddr) != 0 {
+			s.ClientBytes.Add(m.RemoteAddr, m.Bytes)
+		}
+	}
+	// the log formats with no latency are not counted, the table is then not displayed
+	if m.HasLatency {
+		s.Latency.Add(m.Latency)
+		h, ok := s.SectionLatencies[m.Section]
+		if !ok {
+			h = sketch.NewHistogram()
+			s.SectionLatencies[m.Section] = h
+		}
+		h.Add(m.Latency)
+	}
 
 	switch m.Code / 100 {
 	case 5:
-		fallthrough
+		s.Sum[errorsKey]++
+		s.Sum[serverErrorsKey]++
 	case 4:
 		s.Sum[errorsKey]++
+		s.Sum[clientErrorsKey]++
 	case 3:
 		s.Sum[redirectKey]++
 	case 2:
 		s.Sum[successKey]++
+	case 1:
+		s.Sum[informationalKey]++
 	}
 }
 
-// CalcTraffic calculates the traffic for the one summary
+// countKeys are the sums counted by the log messages, the other ones are calculated from them
+var countKeys = []string{hitsKey, informationalKey, successKey, redirectKey, errorsKey, clientErrorsKey, serverErrorsKey, bytesKey}
+
+// Merge adds the stats of the given summary to the summary, the given one is left unchanged
+// the traffic and the unique clients are to be calculated again, the trend and the time are not merged
+func (s *Summary) Merge(o *Summary) {
+	for _, k := range countKeys {
+		if o.Sum[k] != 0 {
+			s.Sum[k] += o.Sum[k]
+		}
+	}
+	s.Sections.Merge(o.Sections)
+	s.Paths.Merge(o.Paths)
+	s.Clients.Merge(o.Clients)
+	mergeCounts(s.Codes, o.Codes)
+	s.Methods.Merge(o.Methods)
+	for sec, cls := range o.SectionClasses {
+		if _, ok := s.SectionClasses[sec]; !ok {
+			s.SectionClasses[sec] = map[string]int{}
+		}
+		mergeCounts(s.SectionClasses[sec], cls)
+	}
+	for src, t := range o.Sources {
+		if _, ok := s.Sources[src]; !ok {
+			s.Sources[src] = sketch.NewTopK(s.capacity)
+		}
+		s.Sources[src].Merge(t)
+	}
+	s.Referers.Merge(o.Referers)
+	s.UserAgents.Merge(o.UserAgents)
+	mergeCounts(s.Flags, o.Flags)
+	s.SectionBytes.Merge(o.SectionBytes)
+	s.ClientBytes.Merge(o.ClientBytes)
+	s.Latency.Merge(o.Latency)
+	for sec, h := range o.SectionLatencies {
+		if _, ok := s.SectionLatencies[sec]; !ok {
+			s.SectionLatencies[sec] = sketch.NewHistogram()
+		}
+		s.SectionLatencies[sec].Merge(h)
+	}
+	s.UniqueClients.Merge(o.UniqueClients)
+	s.UniqueVisitors.Merge(o.UniqueVisitors)
+
+	// the stats of the sections no longer tracked are dropped to keep the memory bounded
+	for sec := range s.SectionClasses {
+		if s.Sections.Count(sec) == 0 {
+			delete(s.SectionClasses, sec)
+			delete(s.SectionLatencies, sec)
+		}
+	}
+}
+
+// mergeCounts adds the counts of the given map to the counts of the other one
+func mergeCounts(dst, src map[string]int) {
+	for k, v := range src {
+		dst[k] += v
+	}
+}
+
+// statusClasses are the status classes displayed for the sections
+var statusClasses = []string{"1xx", "2xx", "3xx", "4xx", "5xx"}
+
+// statusClass returns the class of the given status code (2xx, 4xx, ...)
+func statusClass(code int) string {
+	return strconv.Itoa(code/100) + "xx"
+}
+
+// visitorKey returns the key of the visitor of the given log message: its client address and user agent
+func visitorKey(m *LogMessage) string {
+	return m.RemoteAddr + " " + m.UserAgent
+}
+
+// CalcTraffic calculates the traffic, the bandwidth and the unique clients for the one summary
 func (s *Summary) CalcTraffic(win int) {
 	s.Sum[trafficKey] = int(math.Round(float64(s.Sum[hitsKey]) / float64(win)))
+	if s.Sum[bytesKey] != 0 {
+		s.Sum[bandwidthKey] = int(math.Round(float64(s.Sum[bytesKey]) / float64(win)))
+	}
+	if n := s.UniqueClients.Count(); n != 0 {
+		s.Sum[uniqueClientsKey] = n
+		s.Sum[uniqueVisitorsKey] = s.UniqueVisitors.Count()
+	}
 }
 
-// Format formats the summary structure as two 2d tables ready to be printed
+// Format formats the summary structure as 2d tables ready to be printed under the heading of its window if any:
+// top sections, their latency percentiles and top paths (if any), top sections per source (if more than one),
+// top error sections (if any), status codes, methods, top clients (if any),
+// top sections and clients by bandwidth, top referrers, user agents and proxy flags (if any) and the summary
 func (s Summary) Format() string {
 	b := strings.Builder{}
+	if len(s.Window) != 0 {
+		b.WriteString("\n========== " + s.Window + " ==========\n")
+	}
 
-	// top sections table
-	tblT := printer.NewTable2dMessage("TOP SECTIONS", "Section", "Number of hits")
-	if len(s.Sections) == 0 {
-		tblT.AddRow("<no section data>", "")
-	} else {
-		// sorting, filtering and formatting the section data
-		om := make([]struct {
-			key   string
-			value int
-		}, 0, len(s.Sections))
-		for k, v := range s.Sections {
-			p := struct {
-				key   string
-				value int
-			}{k, v}
-			om = append(om, p)
+	// top sections tables
+	tbls := []*printer.Table2dMessage{s.newSectionsTable()}
+	if s.Latency.Count() > 0 {
+		tbls = append(tbls, s.newLatencyTable())
+	}
+	if s.Paths.Len() != 0 {
+		tbls = append(tbls, newSketchTable("TOP PATHS", "Path", "Number of hits", s.Paths, s.topNum, strconv.Itoa))
+	}
+	if len(s.Sources) > 1 {
+		srcs := make([]string, 0, len(s.Sources))
+		for src := range s.Sources {
+			srcs = append(srcs, src)
 		}
-		sort.Slice(om, func(i, j int) bool { return om[i].value > om[j].value })
-		for i := 0; i < len(om) || i == s.topNum; i++ {
-			tblT.AddRow(om[i].key, strconv.Itoa(om[i].value))
+		sort.Strings(srcs)
+		for _, src := range srcs {
+			tbls = append(tbls, newSketchTable("TOP SECTIONS "+src, "Section", "Number of hits", s.Sources[src], s.topNum, strconv.Itoa))
 		}
 	}
 
+	// top error sections, status codes and methods tables
+	if s.Sum[errorsKey] != 0 {
+		tbls = append(tbls, s.newErrorSectionsTable())
+	}
+	tbls = append(tbls,
+		newSortedTable("STATUS CODES", "Status", "Number of hits", s.Codes),
+		newSketchTable("METHODS", "Method", "Number of hits", s.Methods, s.topNum, strconv.Itoa),
+	)
+	if s.Clients.Len() != 0 {
+		tbls = append(tbls, newSketchTable("TOP CLIENTS", "Client", "Number of hits", s.Clients, s.topNum, strconv.Itoa))
+	}
+
+	// top bandwidth tables
+	if s.Sum[bytesKey] != 0 {
+		tbls = append(tbls,
+			newSketchTable("TOP BANDWIDTH SECTIONS", "Section", "Bytes sent", s.SectionBytes, s.topNum, printer.FormatBytes),
+			newSketchTable("TOP BANDWIDTH CLIENTS", "Client", "Bytes sent", s.ClientBytes, s.topNum, printer.FormatBytes),
+		)
+	}
+
+	// top referrers and user agents tables
+	if s.Referers.Len() != 0 {
+		tbls = append(tbls, newSketchTable("TOP REFERRERS", "Referrer", "Number of hits", s.Referers, s.topNum, strconv.Itoa))
+	}
+	if s.UserAgents.Len() != 0 {
+		tbls = append(tbls, newSketchTable("TOP USER AGENTS", "User agent", "Number of hits", s.UserAgents, s.topNum, strconv.Itoa))
+	}
+
+	// top proxy flags table
+	if len(s.Flags) != 0 {
+		tbls = append(tbls, newTopTable("TOP PROXY FLAGS", "Status and flags", "Number of hits", s.Flags, s.topNum))
+	}
+
 	// summary table
-	tblS := printer.NewTable2dMessage("SUMMARY", "Detail", "Value")
-	tblS.AddRow("Total hits", strconv.Itoa(s.Sum[hitsKey]))
+	name := "SUMMARY"
+	if !s.Time.IsZero() {
+		name += " AT " + s.Time.Format(summaryTimeFormat)
+	}
+	tblS := printer.NewTable2dMessage(name, "Detail", "Value")
+	tblS.AddRow("Total hits", s.withTrend(s.Sum[hitsKey], func(t *Trend) float64 { return t.Hits }))
 	tblS.AddRow("Traffic (per second)", strconv.Itoa(s.Sum[trafficKey]))
+	tblS.AddRow("Total informational (1xx)", strconv.Itoa(s.Sum[informationalKey]))
 	tblS.AddRow("Total success", strconv.Itoa(s.Sum[successKey]))
 	tblS.AddRow("Total redirects", strconv.Itoa(s.Sum[redirectKey]))
-	tblS.AddRow("Total errors", strconv.Itoa(s.Sum[errorsKey]))
+	tblS.AddRow("Total errors", s.withTrend(s.Sum[errorsKey], func(t *Trend) float64 { return t.Errors }))
+	tblS.AddRow("Total client errors (4xx)", strconv.Itoa(s.Sum[clientErrorsKey]))
+	tblS.AddRow("Total server errors (5xx)", strconv.Itoa(s.Sum[serverErrorsKey]))
+	if s.Sum[bytesKey] != 0 {
+		tblS.AddRow("Total bytes sent", printer.FormatBytes(s.Sum[bytesKey]))
+		tblS.AddRow("Bandwidth (per second)", printer.FormatBytes(s.Sum[bandwidthKey]))
+	}
+	if s.Sum[uniqueClientsKey] != 0 {
+		tblS.AddRow("Unique clients", strconv.Itoa(s.Sum[uniqueClientsKey]))
+		tblS.AddRow("Unique visitors (client + UA)", strconv.Itoa(s.Sum[uniqueVisitorsKey]))
+	}
+	if s.Sum[hourClientsKey] != 0 {
+		tblS.AddRow("Unique clients (last hour)", strconv.Itoa(s.Sum[hourClientsKey]))
+		tblS.AddRow("Unique visitors (last hour)", strconv.Itoa(s.Sum[hourVisitorsKey]))
+		tblS.AddRow("Unique clients (last day)", strconv.Itoa(s.Sum[dayClientsKey]))
+		tblS.AddRow("Unique visitors (last day)", strconv.Itoa(s.Sum[dayVisitorsKey]))
+	}
+	if s.Trend != nil {
+		base := "previous interval"
+		if s.Trend.Intervals > 1 {
+			base = fmt.Sprintf("average of %d previous intervals", s.Trend.Intervals)
+		}
+		tblS.AddRow("Trends compared to", base)
+	}
+	tbls = append(tbls, tblS)
 
-	// align 2 tables
-	if tblS.Length() > tblT.Length() {
-		tblT.Enlarge(tblS.Length())
-	} else if tblT.Length() > tblS.Length() {
-		tblS.Enlarge(tblT.Length())
+	// align all the tables
+	max := 0
+	for _, t := range tbls {
+		if t.Length() > max {
+			max = t.Length()
+		}
+	}
+	for _, t := range tbls {
+		t.Enlarge(max)
 	}
 
-	// add both tables to the output after the alignment
-	b.WriteString(tblT.Format())
-	b.WriteString(tblS.Format())
+	// add all the tables to the output after the alignment
+	for _, t := range tbls {
+		b.WriteString(t.Format())
+	}
 
 	return b.String()
 }
 
+// newSectionsTable returns a 2d table filled with the top most hitted sections
+// with their trends if any: the sections not in the top of the previous summary are marked as new
+func (s Summary) newSectionsTable() *printer.Table2dMessage {
+	if s.Trend == nil {
+		return newSketchTable("TOP SECTIONS", "Section", "Number of hits", s.Sections, s.topNum, strconv.Itoa)
+	}
+	tbl := printer.NewTable2dMessage("TOP SECTIONS", "Section", "Number of hits")
+	if s.Sections.Len() == 0 {
+		tbl.AddRow(noDataKey("Section"), "")
+		return tbl
+	}
+	for _, it := range s.Sections.Top(s.topNum) {
+		v := formatItem(it, strconv.Itoa) + " " + formatTrend(it.Count, s.Trend.Sections[it.Key])
+		if !s.Trend.Top[it.Key] {
+			v += " new"
+		}
+		tbl.AddRow(truncate(it.Key, maxKeyLen), v)
+	}
+	return tbl
+}
+
+// withTrend formats the given value followed by its trend if any, the baseline being given by the function
+func (s Summary) withTrend(v int, base func(*Trend) float64) string {
+	if s.Trend == nil {
+		return strconv.Itoa(v)
+	}
+	return strconv.Itoa(v) + " " + formatTrend(v, base(s.Trend))
+}
+
+// newErrorSectionsTable returns a 2d table filled with the hits by status class
+// of the top sections by errors (4xx and 5xx)
+func (s Summary) newErrorSectionsTable() *printer.Table2dMessage {
+	errs := map[string]int{}
+	for sec, cls := range s.SectionClasses {
+		if n := cls["4xx"] + cls["5xx"]; n != 0 {
+			errs[sec] = n
+		}
+	}
+
+	tbl := printer.NewTable2dMessage("TOP ERROR SECTIONS", "Section", strings.Join(statusClasses, " / "))
+	for _, k := range topKeys(errs, s.topNum) {
+		counts := make([]string, 0, len(statusClasses))
+		for _, c := range statusClasses {
+			counts = append(counts, strconv.Itoa(s.SectionClasses[k][c]))
+		}
+		tbl.AddRow(truncate(k, maxKeyLen), strings.Join(counts, " / "))
+	}
+	return tbl
+}
+
+// newSortedTable returns a 2d table filled with all the keys of the given map in their order
+func newSortedTable(name, keyTitle, valueTitle string, m map[string]int) *printer.Table2dMessage {
+	tbl := printer.NewTable2dMessage(name, keyTitle, valueTitle)
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		tbl.AddRow(k, strconv.Itoa(m[k]))
+	}
+	return tbl
+}
+
+// newLatencyTable returns a 2d table filled with the latency percentiles of all the log messages
+// followed by the ones of the top most hitted sections
+func (s Summary) newLatencyTable() *printer.Table2dMessage {
+	tbl := printer.NewTable2dMessage("LATENCY (MS)", "Section", "p50 / p90 / p99 / max")
+	tbl.AddRow("All sections", formatPercentiles(s.Latency))
+	for _, it := range s.Sections.Top(s.topNum) {
+		if h, ok := s.SectionLatencies[it.Key]; ok {
+			tbl.AddRow(truncate(it.Key, maxKeyLen), formatPercentiles(h))
+		}
+	}
+	return tbl
+}
+
+// formatPercentiles formats the p50, p90, p99 and max of the given histogram in milliseconds
+func formatPercentiles(h *sketch.Histogram) string {
+	return strings.Join([]string{
+		formatMillis(h.Quantile(0.5)),
+		formatMillis(h.Quantile(0.9)),
+		formatMillis(h.Quantile(0.99)),
+		formatMillis(h.Max()),
+	}, " / ")
+}
+
+// formatMillis formats the given duration in milliseconds with about 3 significant digits
+func formatMillis(d time.Duration) string {
+	ms := float64(d) / float64(time.Millisecond)
+	switch {
+	case ms < 10:
+		return strconv.FormatFloat(ms, 'f', 2, 64)
+	case ms < 100:
+		return strconv.FormatFloat(ms, 'f', 1, 64)
+	}
+	return strconv.FormatFloat(ms, 'f', 0, 64)
+}
+
+// newTopTable returns a 2d table filled with the top most hitted keys of the given map
+func newTopTable(name, keyTitle, valueTitle string, m map[string]int, top int) *printer.Table2dMessage {
+	tbl := printer.NewTable2dMessage(name, keyTitle, valueTitle)
+	if len(m) == 0 {
+		tbl.AddRow(noDataKey(keyTitle), "")
+		return tbl
+	}
+	for _, k := range topKeys(m, top) {
+		tbl.AddRow(truncate(k, maxKeyLen), strconv.Itoa(m[k]))
+	}
+	return tbl
+}
+
+// newSketchTable returns a 2d table filled with the top keys of the given top-K summary by count
+// the counts (and their maximum error if any) being formatted by the given function
+func newSketchTable(name, keyTitle, valueTitle string, t *sketch.TopK, top int, format func(int) string) *printer.Table2dMessage {
+	tbl := printer.NewTable2dMessage(name, keyTitle, valueTitle)
+	if t.Len() == 0 {
+		tbl.AddRow(noDataKey(keyTitle), "")
+		return tbl
+	}
+	for _, it := range t.Top(top) {
+		tbl.AddRow(truncate(it.Key, maxKeyLen), formatItem(it, format))
+	}
+	return tbl
+}
+
+// formatItem formats the count of the given top-K item followed by its maximum error if any
+func formatItem(it sketch.Item, format func(int) string) string {
+	if it.Err != 0 {
+		return format(it.Count) + " (err " + format(it.Err) + ")"
+	}
+	return format(it.Count)
+}
+
+// noDataKey returns the placeholder of the empty top table
+func noDataKey(keyTitle string) string {
+	return "<no " + strings.ToLower(keyTitle) + " data>"
+}
+
+// topKeys returns the given number of the most hitted keys of the given map, the most hitted first
+func topKeys(m map[string]int, top int) []string {
+	// sorting and filtering the data
+	om := make([]struct {
+		key   string
+		value int
+	}, 0, len(m))
+	for k, v := range m {
+		p := struct {
+			key   string
+			value int
+		}{k, v}
+		om = append(om, p)
+	}
+	// keys are compared for the stable output of the equal values
+	sort.Slice(om, func(i, j int) bool {
+		if om[i].value == om[j].value {
+			return om[i].key < om[j].key
+		}
+		return om[i].value > om[j].value
+	})
+	keys := make([]string, 0, top)
+	for i := 0; i < len(om) && i < top; i++ {
+		keys = append(keys, om[i].key)
+	}
+	return keys
+}
+
 // Verbose returns false as summary is to be always displayed
 func (s Summary) Verbose() bool {
 	return false
 }
+
+// truncate cuts the given string to the given length (in characters) marking it with ellipsis
+func truncate(str string, max int) string {
+	if utf8.RuneCountInString(str) <= max {
+		return str
+	}
+	return string([]rune(str)[:max-3]) + "..."
+}
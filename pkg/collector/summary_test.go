@@ -2,7 +2,12 @@ package collector
 
 import (
 	"reflect"
+	"strings"
 	"testing"
+	"time"
+	"unicode/utf8"
+
+	"httplogmonitor/pkg/sketch"
 )
 
 func TestLogMessageEqual(t *testing.T) {
@@ -53,6 +58,16 @@ func TestLogMessageEqual(t *testing.T) {
 			},
 			expected: false,
 		},
+		{
+			name: "Not equal time",
+			input: LogMessage{
+				Section: "/api",
+				Method:  "GET",
+				Code:    200,
+				Time:    mustParseTime("09/May/2018:16:00:39 +0000"),
+			},
+			expected: false,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -76,56 +91,130 @@ func TestNewLogMessageFromLogEntry(t *testing.T) {
 			name:  "Nominal 1",
 			input: `127.0.0.1 - james [09/May/2018:16:00:39 +0000] "GET /report HTTP/1.0" 200 123`,
 			expected: LogMessage{
-				Section: "/report",
-				Method:  "GET",
-				Code:    200,
+				Section:    "/report",
+				Method:     "GET",
+				Code:       200,
+				Time:       mustParseTime("09/May/2018:16:00:39 +0000"),
+				Protocol:   "HTTP/1.0",
+				RemoteAddr: "127.0.0.1",
+				User:       "james",
+				Bytes:      123,
 			},
 		},
 		{
 			name:  "Nominal 2",
 			input: `127.0.0.1 - jill [09/May/2018:16:00:41 +0000] "GET /api/user HTTP/1.0" 200 234`,
 			expected: LogMessage{
-				Section: "/api",
-				Method:  "GET",
-				Code:    200,
+				Section:    "/api",
+				Method:     "GET",
+				Code:       200,
+				Time:       mustParseTime("09/May/2018:16:00:41 +0000"),
+				Protocol:   "HTTP/1.0",
+				RemoteAddr: "127.0.0.1",
+				User:       "jill",
+				Bytes:      234,
 			},
 		},
 		{
 			name:  "Nominal 3",
 			input: `127.0.0.1 - frank [09/May/2018:16:00:42 +0000] "POST / HTTP/1.0" 200 34`,
 			expected: LogMessage{
-				Section: "/",
-				Method:  "POST",
-				Code:    200,
+				Section:    "/",
+				Method:     "POST",
+				Code:       200,
+				Time:       mustParseTime("09/May/2018:16:00:42 +0000"),
+				Protocol:   "HTTP/1.0",
+				RemoteAddr: "127.0.0.1",
+				User:       "frank",
+				Bytes:      34,
 			},
 		},
 		{
 			name:  "Nominal 4",
 			input: `127.0.0.1 - mary [09/May/2018:16:00:42 +0000] "GET /api/user HTTP/1.0" 503 12`,
 			expected: LogMessage{
-				Section: "/api",
-				Method:  "GET",
-				Code:    503,
+				Section:    "/api",
+				Method:     "GET",
+				Code:       503,
+				Time:       mustParseTime("09/May/2018:16:00:42 +0000"),
+				Protocol:   "HTTP/1.0",
+				RemoteAddr: "127.0.0.1",
+				User:       "mary",
+				Bytes:      12,
 			},
 		},
 		{
 			name:  "Nominal dns name",
 			input: `hostname.xyz.com - mary [09/May/2018:16:00:42 +0000] "GET /api/user HTTP/1.0" 200 12`,
 			expected: LogMessage{
-				Section: "/api",
-				Method:  "GET",
-				Code:    200,
+				Section:    "/api",
+				Method:     "GET",
+				Code:       200,
+				Time:       mustParseTime("09/May/2018:16:00:42 +0000"),
+				Protocol:   "HTTP/1.0",
+				RemoteAddr: "hostname.xyz.com",
+				User:       "mary",
+				Bytes:      12,
 			},
 		},
 		{
 			name:  "Nominal absent rfc931 and authuser",
 			input: `hostname.xyz.com - - [09/May/2018:16:00:42 +0000] "GET /api/user HTTP/1.0" 200 12`,
 			expected: LogMessage{
-				Section: "/api",
-				Method:  "GET",
-				Code:    200,
+				Section:    "/api",
+				Method:     "GET",
+				Code:       200,
+				Time:       mustParseTime("09/May/2018:16:00:42 +0000"),
+				Protocol:   "HTTP/1.0",
+				RemoteAddr: "hostname.xyz.com",
+				Bytes:      12,
+			},
+		},
+		{
+			name:  "Nominal combined",
+			input: `127.0.0.1 - - [09/May/2018:16:00:42 +0000] "GET /api/user?id=1 HTTP/1.1" 200 12 "http://example.com/\"quoted\"" "Mozilla/5.0 \x22escaped\x22"`,
+			expected: LogMessage{
+				Section:    "/api",
+				Method:     "GET",
+				Code:       200,
+				Time:       mustParseTime("09/May/2018:16:00:42 +0000"),
+				Protocol:   "HTTP/1.1",
+				RemoteAddr: "127.0.0.1",
+				Bytes:      12,
+				Referer:    `http://example.com/"quoted"`,
+				UserAgent:  `Mozilla/5.0 "escaped"`,
+			},
+		},
+		{
+			name:  "Nominal combined absent referer",
+			input: `127.0.0.1 - - [09/May/2018:16:00:42 +0000] "GET / HTTP/1.1" 304 0 "-" "curl/7.58.0"`,
+			expected: LogMessage{
+				Section:    "/",
+				Method:     "GET",
+				Code:       304,
+				Time:       mustParseTime("09/May/2018:16:00:42 +0000"),
+				Protocol:   "HTTP/1.1",
+				RemoteAddr: "127.0.0.1",
+				UserAgent:  "curl/7.58.0",
+			},
+		},
+		{
+			name:  "Nominal no bytes sent",
+			input: `127.0.0.1 - - [09/May/2018:16:00:42 +0000] "HEAD /api/user HTTP/1.1" 204 -`,
+			expected: LogMessage{
+				Section:    "/api",
+				Method:     "HEAD",
+				Code:       204,
+				Time:       mustParseTime("09/May/2018:16:00:42 +0000"),
+				Protocol:   "HTTP/1.1",
+				RemoteAddr: "127.0.0.1",
 			},
 		},
+		{
+			name:        "Error combined no user agent",
+			input:       `127.0.0.1 - - [09/May/2018:16:00:42 +0000] "GET / HTTP/1.1" 200 12 "-"`,
+			expectedErr: true,
+		},
 		{
 			name:        "Error empty request",
 			input:       `127.0.0.1 - - [09/May/2018:16:00:42 +0000] "" 200 12`,
@@ -171,6 +260,11 @@ func TestNewLogMessageFromLogEntry(t *testing.T) {
 			input:       `127.0.0.1 - - "GET /api/user HTTP/1.0" 200 12`,
 			expectedErr: true,
 		},
+		{
+			name:        "Error wrong date",
+			input:       `127.0.0.1 - mary [09/Mai/2018:16:00:42 +0000] "GET /api/user HTTP/1.0" 200 12`,
+			expectedErr: true,
+		},
 		{
 			name:        "Error no bytes",
 			input:       `127.0.0.1 - mary [09/May/2018:16:00:42 +0000] "GET /api/user HTTP/1.0" 200`,
@@ -262,22 +356,29 @@ func TestSummary(t *testing.T) {
 	}
 
 	expectedSum := map[string]int{
-		hitsKey:     10,
-		successKey:  7,
-		errorsKey:   2,
-		redirectKey: 1,
-		trafficKey:  2,
+		hitsKey:         10,
+		successKey:      7,
+		errorsKey:       2,
+		clientErrorsKey: 1,
+		serverErrorsKey: 1,
+		redirectKey:     1,
+		trafficKey:      2,
 	}
 
-	sum := NewSummary(limit)
+	sum := NewSummary(limit, 100)
 	for i := range logMsg {
 		sum.Add(logMsg[i])
 	}
+	// no source given: no per source stats
+	expectedSources := map[string]*sketch.TopK{}
 	sum.CalcTraffic(window)
 
 	t.Log("Checking summary internals")
-	if !reflect.DeepEqual(sum.Sections, expectedSections) {
-		t.Fatalf("Expected sections %+v, got sections %+v", expectedSections, sum.Sections)
+	if got := topKCounts(sum.Sections); !reflect.DeepEqual(got, expectedSections) {
+		t.Fatalf("Expected sections %+v, got sections %+v", expectedSections, got)
+	}
+	if !reflect.DeepEqual(sum.Sources, expectedSources) {
+		t.Fatalf("Expected sources %+v, got sources %+v", expectedSources, sum.Sources)
 	}
 	if !reflect.DeepEqual(sum.Sum, expectedSum) {
 		t.Fatalf("Expected summary %+v, got summary %+v", expectedSum, sum.Sum)
@@ -285,22 +386,466 @@ func TestSummary(t *testing.T) {
 
 	t.Log("Checking summary formatting")
 	expectedFormat := `
---------TOP SECTIONS---------
-  Section      Number of hits
------------    --------------
-/here                       4
-/                           3
-/there                      2
-/redirect                   1
-
------------SUMMARY-----------
-       Detail           Value
---------------------    -----
-Total hits                 10
-Traffic (per second)        2
-Total success               7
-Total redirects             1
-Total errors                2
+-------------TOP SECTIONS-------------
+     Section          Number of hits  
+-----------------    -----------------
+/here                                4
+/                                    3
+/there                               2
+/redirect                            1
+
+----------TOP ERROR SECTIONS----------
+Section    1xx / 2xx / 3xx / 4xx / 5xx
+-------    ---------------------------
+/                    0 / 1 / 0 / 1 / 1
+
+-------------STATUS CODES-------------
+     Status           Number of hits  
+-----------------    -----------------
+200                                  4
+202                                  3
+302                                  1
+404                                  1
+500                                  1
+
+---------------METHODS----------------
+     Method           Number of hits  
+-----------------    -----------------
+GET                                  4
+POST                                 4
+PUT                                  2
+
+---------------SUMMARY----------------
+         Detail                Value  
+-------------------------    ---------
+Total hits                          10
+Traffic (per second)                 2
+Total informational (1xx)            0
+Total success                        7
+Total redirects                      1
+Total errors                         2
+Total client errors (4xx)            1
+Total server errors (5xx)            1
+`
+	gotFormat := sum.Format()
+
+	if expectedFormat != gotFormat {
+		t.Fatalf("Expected format %s, got format %s", expectedFormat, gotFormat)
+	}
+}
+
+// topKCounts returns the counts of all the keys tracked by the given top-K summary
+func topKCounts(t *sketch.TopK) map[string]int {
+	m := map[string]int{}
+	for _, it := range t.Top(t.Len()) {
+		m[it.Key] = it.Count
+	}
+	return m
+}
+
+func TestSummarySources(t *testing.T) {
+	sum := NewSummary(1, 100)
+	sum.Add(&LogMessage{Section: "/api", Method: "GET", Code: 200, Source: "/var/log/nginx/api.access.log"})
+	sum.Add(&LogMessage{Section: "/api", Method: "GET", Code: 200, Source: "/var/log/nginx/api.access.log"})
+	sum.Add(&LogMessage{Section: "/", Method: "GET", Code: 200, Source: "/var/log/nginx/api.access.log"})
+	sum.Add(&LogMessage{Section: "/", Method: "GET", Code: 404, Source: "/var/log/nginx/www.access.log"})
+	sum.CalcTraffic(1)
+
+	expectedFormat := `
+----------------TOP SECTIONS----------------
+      Section              Number of hits   
+--------------------    --------------------
+/                                          2
+
+-TOP SECTIONS /var/log/nginx/api.access.log-
+      Section              Number of hits   
+--------------------    --------------------
+/api                                       2
+
+-TOP SECTIONS /var/log/nginx/www.access.log-
+      Section              Number of hits   
+--------------------    --------------------
+/                                          1
+
+-------------TOP ERROR SECTIONS-------------
+   Section       1xx / 2xx / 3xx / 4xx / 5xx
+-------------    ---------------------------
+/                          0 / 1 / 0 / 1 / 0
+
+----------------STATUS CODES----------------
+       Status              Number of hits   
+--------------------    --------------------
+200                                        3
+404                                        1
+
+------------------METHODS-------------------
+       Method              Number of hits   
+--------------------    --------------------
+GET                                        4
+
+------------------SUMMARY-------------------
+         Detail                   Value     
+-------------------------    ---------------
+Total hits                                 4
+Traffic (per second)                       4
+Total informational (1xx)                  0
+Total success                              3
+Total redirects                            0
+Total errors                               1
+Total client errors (4xx)                  1
+Total server errors (5xx)                  0
+`
+	gotFormat := sum.Format()
+
+	if expectedFormat != gotFormat {
+		t.Fatalf("Expected format %s, got format %s", expectedFormat, gotFormat)
+	}
+}
+
+func mustParseTime(s string) time.Time {
+	t, err := time.Parse(timeLocalLayout, s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestSummaryCombined(t *testing.T) {
+	sum := NewSummary(2, 100)
+	sum.Add(&LogMessage{Section: "/api", Method: "GET", Code: 200, Referer: "http://example.com/", UserAgent: "curl/7.58.0"})
+	sum.Add(&LogMessage{Section: "/api", Method: "GET", Code: 200, UserAgent: "curl/7.58.0"})
+	sum.Add(&LogMessage{Section: "/", Method: "GET", Code: 200, UserAgent: "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/78.0.3904.108 Safari/537.36"})
+	sum.CalcTraffic(1)
+
+	expectedFormat := `
+-----------------------------------TOP SECTIONS-----------------------------------
+                Section                                Number of hits             
+---------------------------------------    ---------------------------------------
+/api                                                                             2
+/                                                                                1
+
+-----------------------------------STATUS CODES-----------------------------------
+                Status                                 Number of hits             
+---------------------------------------    ---------------------------------------
+200                                                                              3
+
+-------------------------------------METHODS--------------------------------------
+                Method                                 Number of hits             
+---------------------------------------    ---------------------------------------
+GET                                                                              3
+
+----------------------------------TOP REFERRERS-----------------------------------
+               Referrer                                Number of hits             
+---------------------------------------    ---------------------------------------
+http://example.com/                                                              1
+
+---------------------------------TOP USER AGENTS----------------------------------
+                           User agent                               Number of hits
+----------------------------------------------------------------    --------------
+curl/7.58.0                                                                      2
+Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, li...                 1
+
+-------------------------------------SUMMARY--------------------------------------
+                Detail                                      Value                 
+---------------------------------------    ---------------------------------------
+Total hits                                                                       3
+Traffic (per second)                                                             3
+Total informational (1xx)                                                        0
+Total success                                                                    3
+Total redirects                                                                  0
+Total errors                                                                     0
+Total client errors (4xx)                                                        0
+Total server errors (5xx)                                                        0
+`
+	gotFormat := sum.Format()
+
+	if expectedFormat != gotFormat {
+		t.Fatalf("Expected format %s, got format %s", expectedFormat, gotFormat)
+	}
+}
+
+func TestSummaryLatency(t *testing.T) {
+	sum := NewSummary(2, 100)
+	for i := 1; i <= 100; i++ {
+		sum.Add(&LogMessage{Section: "/api", Method: "GET", Code: 200, Latency: time.Duration(i) * time.Millisecond, HasLatency: true})
+	}
+	sum.Add(&LogMessage{Section: "/", Method: "GET", Code: 200, Latency: 250 * time.Microsecond, HasLatency: true})
+	sum.Add(&LogMessage{Section: "/static", Method: "GET", Code: 200})
+	sum.CalcTraffic(1)
+
+	expectedFormat := `
+--------------TOP SECTIONS---------------
+     Section            Number of hits   
+------------------    -------------------
+/api                                  100
+/                                       1
+
+--------------LATENCY (MS)---------------
+  Section         p50 / p90 / p99 / max  
+------------    -------------------------
+All sections     49.5 / 90.2 / 99.7 / 100
+/api             49.5 / 90.2 / 99.7 / 100
+/               0.25 / 0.25 / 0.25 / 0.25
+
+--------------STATUS CODES---------------
+      Status            Number of hits   
+------------------    -------------------
+200                                   102
+
+-----------------METHODS-----------------
+      Method            Number of hits   
+------------------    -------------------
+GET                                   102
+
+-----------------SUMMARY-----------------
+         Detail                 Value    
+-------------------------    ------------
+Total hits                            102
+Traffic (per second)                  102
+Total informational (1xx)               0
+Total success                         102
+Total redirects                         0
+Total errors                            0
+Total client errors (4xx)               0
+Total server errors (5xx)               0
+`
+	gotFormat := sum.Format()
+
+	if expectedFormat != gotFormat {
+		t.Fatalf("Expected format %s, got format %s", expectedFormat, gotFormat)
+	}
+}
+
+func TestSummaryBandwidth(t *testing.T) {
+	sum := NewSummary(2, 100)
+	sum.Add(&LogMessage{Section: "/download", Method: "GET", Code: 200, RemoteAddr: "10.0.0.1", Bytes: 3 * 1024 * 1024})
+	sum.Add(&LogMessage{Section: "/download", Method: "GET", Code: 200, RemoteAddr: "10.0.0.2", Bytes: 1024 * 1024})
+	sum.Add(&LogMessage{Section: "/api", Method: "GET", Code: 200, RemoteAddr: "10.0.0.2", Bytes: 512})
+	sum.Add(&LogMessage{Section: "/", Method: "GET", Code: 304, RemoteAddr: "10.0.0.3"})
+	sum.CalcTraffic(2)
+
+	expectedFormat := `
+-------------TOP SECTIONS--------------
+     Section           Number of hits  
+-----------------    ------------------
+/download                             2
+/                                     1
+
+-------------STATUS CODES--------------
+     Status            Number of hits  
+-----------------    ------------------
+200                                   3
+304                                   1
+
+----------------METHODS----------------
+     Method            Number of hits  
+-----------------    ------------------
+GET                                   4
+
+--------------TOP CLIENTS--------------
+     Client            Number of hits  
+-----------------    ------------------
+10.0.0.2                              2
+10.0.0.1                              1
+
+--------TOP BANDWIDTH SECTIONS---------
+     Section             Bytes sent    
+-----------------    ------------------
+/download                        4.0 MB
+/api                              512 B
+
+---------TOP BANDWIDTH CLIENTS---------
+     Client              Bytes sent    
+-----------------    ------------------
+10.0.0.1                         3.0 MB
+10.0.0.2                         1.0 MB
+
+----------------SUMMARY----------------
+           Detail                Value 
+-----------------------------    ------
+Total hits                            4
+Traffic (per second)                  2
+Total informational (1xx)             0
+Total success                         3
+Total redirects                       1
+Total errors                          0
+Total client errors (4xx)             0
+Total server errors (5xx)             0
+Total bytes sent                 4.0 MB
+Bandwidth (per second)           2.0 MB
+Unique clients                        3
+Unique visitors (client + UA)         3
+`
+	gotFormat := sum.Format()
+
+	if expectedFormat != gotFormat {
+		t.Fatalf("Expected format %s, got format %s", expectedFormat, gotFormat)
+	}
+}
+
+func TestSummaryStatuses(t *testing.T) {
+	sum := NewSummary(2, 100)
+	sum.Add(&LogMessage{Section: "/api", Method: "GET", Code: 200})
+	sum.Add(&LogMessage{Section: "/api", Method: "POST", Code: 500})
+	sum.Add(&LogMessage{Section: "/api", Method: "PUT", Code: 503})
+	sum.Add(&LogMessage{Section: "/login", Method: "POST", Code: 401})
+	sum.Add(&LogMessage{Section: "/login", Method: "GET", Code: 302})
+	sum.Add(&LogMessage{Section: "/ws", Method: "GET", Code: 101})
+	sum.Add(&LogMessage{Section: "/static", Method: "GET", Code: 404})
+	sum.CalcTraffic(1)
+
+	expectedCodes := map[string]int{
+		"101": 1,
+		"200": 1,
+		"302": 1,
+		"401": 1,
+		"404": 1,
+		"500": 1,
+		"503": 1,
+	}
+	expectedMethods := map[string]int{
+		"GET":  4,
+		"POST": 2,
+		"PUT":  1,
+	}
+	expectedClasses := map[string]map[string]int{
+		"/api":    {"2xx": 1, "5xx": 2},
+		"/login":  {"3xx": 1, "4xx": 1},
+		"/ws":     {"1xx": 1},
+		"/static": {"4xx": 1},
+	}
+
+	if !reflect.DeepEqual(sum.Codes, expectedCodes) {
+		t.Fatalf("Expected codes %+v, got codes %+v", expectedCodes, sum.Codes)
+	}
+	if sum.Methods.Len() != len(expectedMethods) {
+		t.Fatalf("Expected %d methods, got %d", len(expectedMethods), sum.Methods.Len())
+	}
+	for k, n := range expectedMethods {
+		if got := sum.Methods.Count(k); got != n {
+			t.Fatalf("Expected %d hits of method %s, got %d", n, k, got)
+		}
+	}
+	if !reflect.DeepEqual(sum.SectionClasses, expectedClasses) {
+		t.Fatalf("Expected section classes %+v, got section classes %+v", expectedClasses, sum.SectionClasses)
+	}
+
+	expectedFormat := `
+-------------TOP SECTIONS-------------
+     Section          Number of hits  
+-----------------    -----------------
+/api                                 3
+/login                               2
+
+----------TOP ERROR SECTIONS----------
+Section    1xx / 2xx / 3xx / 4xx / 5xx
+-------    ---------------------------
+/api                 0 / 1 / 0 / 0 / 2
+/login               0 / 0 / 1 / 1 / 0
+
+-------------STATUS CODES-------------
+     Status           Number of hits  
+-----------------    -----------------
+101                                  1
+200                                  1
+302                                  1
+401                                  1
+404                                  1
+500                                  1
+503                                  1
+
+---------------METHODS----------------
+     Method           Number of hits  
+-----------------    -----------------
+GET                                  4
+POST                                 2
+
+---------------SUMMARY----------------
+         Detail                Value  
+-------------------------    ---------
+Total hits                           7
+Traffic (per second)                 7
+Total informational (1xx)            1
+Total success                        1
+Total redirects                      1
+Total errors                         4
+Total client errors (4xx)            2
+Total server errors (5xx)            2
+`
+	gotFormat := sum.Format()
+
+	if expectedFormat != gotFormat {
+		t.Fatalf("Expected format %s, got format %s", expectedFormat, gotFormat)
+	}
+}
+
+func TestSummaryCapacity(t *testing.T) {
+	// only 2 keys tracked by the top tables
+	sum := NewSummary(2, 2)
+	sum.Add(&LogMessage{Section: "/a", path: "/a/1", Method: "GET", Code: 200, RemoteAddr: "10.0.0.1"})
+	sum.Add(&LogMessage{Section: "/a", path: "/a/1", Method: "GET", Code: 200, RemoteAddr: "10.0.0.1"})
+	sum.Add(&LogMessage{Section: "/a", path: "/a/2", Method: "GET", Code: 200, RemoteAddr: "10.0.0.1"})
+	sum.Add(&LogMessage{Section: "/b", path: "/b", Method: "GET", Code: 404, RemoteAddr: "10.0.0.2"})
+	sum.Add(&LogMessage{Section: "/c", path: "/c", Method: "GET", Code: 500, RemoteAddr: "10.0.0.3"})
+	sum.CalcTraffic(1)
+
+	// the stats of the evicted section are dropped
+	if _, ok := sum.SectionClasses["/b"]; ok {
+		t.Fatal("Expected no status classes for the evicted section /b")
+	}
+	if _, ok := sum.SectionLatencies["/b"]; ok {
+		t.Fatal("Expected no latencies for the evicted section /b")
+	}
+
+	expectedFormat := `
+-------------TOP SECTIONS-------------
+     Section          Number of hits  
+-----------------    -----------------
+/a                                   3
+/c                           2 (err 1)
+
+--------------TOP PATHS---------------
+      Path            Number of hits  
+-----------------    -----------------
+/c                           3 (err 2)
+/a/1                                 2
+
+----------TOP ERROR SECTIONS----------
+Section    1xx / 2xx / 3xx / 4xx / 5xx
+-------    ---------------------------
+/c                   0 / 0 / 0 / 0 / 1
+
+-------------STATUS CODES-------------
+     Status           Number of hits  
+-----------------    -----------------
+200                                  3
+404                                  1
+500                                  1
+
+---------------METHODS----------------
+     Method           Number of hits  
+-----------------    -----------------
+GET                                  5
+
+-------------TOP CLIENTS--------------
+     Client           Number of hits  
+-----------------    -----------------
+10.0.0.1                             3
+10.0.0.3                     2 (err 1)
+
+---------------SUMMARY----------------
+           Detail                Value
+-----------------------------    -----
+Total hits                           5
+Traffic (per second)                 5
+Total informational (1xx)            0
+Total success                        3
+Total redirects                      0
+Total errors                         2
+Total client errors (4xx)            1
+Total server errors (5xx)            1
+Unique clients                       3
+Unique visitors (client + UA)        3
 `
 	gotFormat := sum.Format()
 
@@ -308,3 +853,201 @@ Total errors                2
 		t.Fatalf("Expected format %s, got format %s", expectedFormat, gotFormat)
 	}
 }
+
+func TestSummaryTrend(t *testing.T) {
+	newSum := func(sections map[string]int, errors int) *Summary {
+		s := NewSummary(2, 100)
+		for sec, n := range sections {
+			for i := 0; i < n; i++ {
+				s.Add(&LogMessage{Section: sec, Method: "GET", Code: 200})
+			}
+		}
+		for i := 0; i < errors; i++ {
+			s.Add(&LogMessage{Section: "/", Method: "GET", Code: 500})
+		}
+		s.CalcTraffic(10)
+		return s
+	}
+
+	testCases := []struct {
+		name           string
+		prev           []*Summary
+		expectedFormat string
+	}{
+		{
+			name: "Previous interval",
+			prev: []*Summary{newSum(map[string]int{"/api": 10, "/static": 5}, 2)},
+			expectedFormat: `
+-----------------TOP SECTIONS-----------------
+       Section              Number of hits    
+---------------------    ---------------------
+/api                            15 ↑ +5 (+50%)
+/new                                3 ↑ +3 new
+
+--------------TOP ERROR SECTIONS--------------
+    Section        1xx / 2xx / 3xx / 4xx / 5xx
+---------------    ---------------------------
+/                            0 / 0 / 0 / 0 / 1
+
+-----------------STATUS CODES-----------------
+       Status               Number of hits    
+---------------------    ---------------------
+200                                         18
+500                                          1
+
+-------------------METHODS--------------------
+       Method               Number of hits    
+---------------------    ---------------------
+GET                                         19
+
+-------------------SUMMARY--------------------
+         Detail                    Value      
+-------------------------    -----------------
+Total hits                      19 ↑ +2 (+12%)
+Traffic (per second)                         2
+Total informational (1xx)                    0
+Total success                               18
+Total redirects                              0
+Total errors                     1 ↓ -1 (-50%)
+Total client errors (4xx)                    0
+Total server errors (5xx)                    1
+Trends compared to           previous interval
+`,
+		},
+		{
+			name: "Average with an empty interval",
+			prev: []*Summary{newSum(map[string]int{"/api": 20, "/new": 5}, 4), nil},
+			expectedFormat: `
+------------------------TOP SECTIONS------------------------
+          Section                      Number of hits       
+----------------------------    ----------------------------
+/api                                      15 ↑ +5 (+50%) new
+/new                                       3 ↑ +1 (+20%) new
+
+---------------------TOP ERROR SECTIONS---------------------
+          Section               1xx / 2xx / 3xx / 4xx / 5xx 
+----------------------------    ----------------------------
+/                                          0 / 0 / 0 / 0 / 1
+
+------------------------STATUS CODES------------------------
+           Status                      Number of hits       
+----------------------------    ----------------------------
+200                                                       18
+500                                                        1
+
+--------------------------METHODS---------------------------
+           Method                      Number of hits       
+----------------------------    ----------------------------
+GET                                                       19
+
+--------------------------SUMMARY---------------------------
+         Detail                           Value             
+-------------------------    -------------------------------
+Total hits                                    19 ↑ +5 (+31%)
+Traffic (per second)                                       2
+Total informational (1xx)                                  0
+Total success                                             18
+Total redirects                                            0
+Total errors                                   1 ↓ -1 (-50%)
+Total client errors (4xx)                                  0
+Total server errors (5xx)                                  1
+Trends compared to           average of 2 previous intervals
+`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sum := newSum(map[string]int{"/api": 15, "/new": 3}, 1)
+			sum.SetTrend(tc.prev)
+			gotFormat := sum.Format()
+			if tc.expectedFormat != gotFormat {
+				t.Errorf("Test case %q: expected format %s, got format %s", tc.name, tc.expectedFormat, gotFormat)
+			}
+		})
+	}
+
+	t.Log("Checking no trend with no previous summary")
+	sum := newSum(map[string]int{"/api": 1}, 0)
+	sum.SetTrend(nil)
+	if sum.Trend != nil {
+		t.Fatalf("Expected no trend, got %+v", sum.Trend)
+	}
+}
+
+func TestSummaryMerge(t *testing.T) {
+	msgs := []*LogMessage{
+		{Section: "/api", path: "/api/1", Method: "GET", Code: 200, RemoteAddr: "10.0.0.1", Bytes: 100, Latency: 10 * time.Millisecond, HasLatency: true, Source: "a.log"},
+		{Section: "/api", path: "/api/2", Method: "POST", Code: 500, RemoteAddr: "10.0.0.2", Bytes: 50, Latency: 200 * time.Millisecond, HasLatency: true, Source: "b.log"},
+		{Section: "/static", path: "/static/a.css", Method: "GET", Code: 304, RemoteAddr: "10.0.0.1", Referer: "http://example.com/", UserAgent: "curl/7.58.0", Source: "a.log"},
+		{Section: "/api", path: "/api/1", Method: "GET", Code: 404, RemoteAddr: "10.0.0.3", Flags: "NR", Latency: 5 * time.Millisecond, HasLatency: true, Source: "b.log"},
+		{Section: "/", path: "/", Method: "GET", Code: 200, RemoteAddr: "10.0.0.1", Bytes: 1000, Source: "a.log"},
+	}
+
+	all := NewSummary(2, 100)
+	first := NewSummary(2, 100)
+	second := NewSummary(2, 100)
+	for i, m := range msgs {
+		all.Add(m)
+		if i < 2 {
+			first.Add(m)
+		} else {
+			second.Add(m)
+		}
+	}
+	all.CalcTraffic(2)
+
+	merged := NewSummary(2, 100)
+	merged.Merge(first)
+	merged.Merge(second)
+	merged.CalcTraffic(2)
+
+	if expected, got := all.Format(), merged.Format(); expected != got {
+		t.Fatalf("Expected format %s, got format %s", expected, got)
+	}
+	if first.Sum[hitsKey] != 2 || first.Sections.Count("/api") != 2 || len(first.SectionClasses) != 1 {
+		t.Fatalf("Expected the merged summary unchanged, got %v", first.Sum)
+	}
+
+	t.Log("Checking the heading of the window")
+	merged.Window = "LAST 5m"
+	if got := merged.Format(); !strings.HasPrefix(got, "\n========== LAST 5m ==========\n") {
+		t.Fatalf("Expected the window heading, got format %s", got)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "Short",
+			input:    "curl/7.58.0",
+			expected: "curl/7.58.0",
+		},
+		{
+			name:     "Long",
+			input:    "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/78.0.3904.108 Safari/537.36",
+			expected: "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, li...",
+		},
+		{
+			name:     "Non-ASCII user agent",
+			input:    "Приложение/1.0 (Ж; ĘĘĘ) ДобрыйДеньМирДобрыйДеньМирДобрыйДеньМирДобрыйДеньМир",
+			expected: "Приложение/1.0 (Ж; ĘĘĘ) ДобрыйДеньМирДобрыйДеньМирДобрыйДеньМ...",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			output := truncate(tc.input, maxKeyLen)
+			if output != tc.expected {
+				t.Errorf("Test case %q: expected %q, got %q", tc.name, tc.expected, output)
+			}
+			if !utf8.ValidString(output) || utf8.RuneCountInString(output) > maxKeyLen {
+				t.Errorf("Test case %q: expected at most %d valid characters, got %q", tc.name, maxKeyLen, output)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package collector
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// Trend is the baseline the summary is compared to: the averages of the previous summaries
+type Trend struct {
+	// Intervals is the number of the previous summaries averaged
+	Intervals int
+	Hits      float64
+	Errors    float64
+	// Sections are the average hits of the top sections of the summary
+	Sections map[string]float64
+	// Top are the top sections of the previous summary, the other ones are new in the top
+	Top map[string]bool
+}
+
+// SetTrend compares the summary to the given previous summaries (the latest last),
+// a nil summary stands for an interval with no log entries
+// no trend is set if no previous summary is given
+func (s *Summary) SetTrend(prev []*Summary) {
+	if len(prev) == 0 {
+		s.Trend = nil
+		return
+	}
+
+	t := &Trend{
+		Intervals: len(prev),
+		Sections:  map[string]float64{},
+		Top:       map[string]bool{},
+	}
+	top := s.Sections.Top(s.topNum)
+	for _, p := range prev {
+		if p == nil {
+			continue
+		}
+		t.Hits += float64(p.Sum[hitsKey])
+		t.Errors += float64(p.Sum[errorsKey])
+		for _, it := range top {
+			t.Sections[it.Key] += float64(p.Sections.Count(it.Key))
+		}
+	}
+	n := float64(len(prev))
+	t.Hits /= n
+	t.Errors /= n
+	for k := range t.Sections {
+		t.Sections[k] /= n
+	}
+	if last := prev[len(prev)-1]; last != nil {
+		for _, it := range last.Sections.Top(last.topNum) {
+			t.Top[it.Key] = true
+		}
+	}
+	s.Trend = t
+}
+
+// formatTrend formats the change of the given value from the given baseline:
+// arrow for rising/falling, delta and percentage change (↑ +4 (+50%), ↓ -2 (-20%), → 0)
+func formatTrend(v int, base float64) string {
+	d := float64(v) - base
+	arrow := "→"
+	switch {
+	case d >= 0.5:
+		arrow = "↑"
+	case d <= -0.5:
+		arrow = "↓"
+	}
+	str := arrow + " " + formatDelta(d)
+	if base > 0 && arrow != "→" {
+		str += fmt.Sprintf(" (%+.0f%%)", d/base*100)
+	}
+	return str
+}
+
+// formatDelta formats the given delta with its sign rounded to the integer
+func formatDelta(d float64) string {
+	r := int(math.Round(d))
+	if r > 0 {
+		return "+" + strconv.Itoa(r)
+	}
+	return strconv.Itoa(r)
+}
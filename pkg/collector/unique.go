@@ -0,0 +1,47 @@
+package collector
+
+import (
+	"time"
+
+	"httplogmonitor/pkg/sketch"
+)
+
+// uniqueWindows estimates the unique clients and visitors (client + user agent) of the rolling hour and day
+// the hour is counted in slots of a minute, the day in slots of an hour
+type uniqueWindows struct {
+	hourClients  *sketch.RollingHyperLogLog
+	hourVisitors *sketch.RollingHyperLogLog
+	dayClients   *sketch.RollingHyperLogLog
+	dayVisitors  *sketch.RollingHyperLogLog
+}
+
+// newUniqueWindows returns a new instance of uniqueWindows
+func newUniqueWindows() *uniqueWindows {
+	return &uniqueWindows{
+		hourClients:  sketch.NewRollingHyperLogLog(time.Hour, time.Minute),
+		hourVisitors: sketch.NewRollingHyperLogLog(time.Hour, time.Minute),
+		dayClients:   sketch.NewRollingHyperLogLog(24*time.Hour, time.Hour),
+		dayVisitors:  sketch.NewRollingHyperLogLog(24*time.Hour, time.Hour),
+	}
+}
+
+// add adds the client of the given log message seen at the given time, the log messages with no client are skipped
+func (u *uniqueWindows) add(m *LogMessage, now time.Time) {
+	if len(m.RemoteAddr) == 0 {
+		return
+	}
+	u.hourClients.Add(m.RemoteAddr, now)
+	u.hourVisitors.Add(visitorKey(m), now)
+	u.dayClients.Add(m.RemoteAddr, now)
+	u.dayVisitors.Add(visitorKey(m), now)
+}
+
+// fill sets the unique clients and visitors of the hour and day ending at the given time to the given summary
+func (u *uniqueWindows) fill(s *Summary, now time.Time) {
+	if n := u.hourClients.Count(now); n != 0 {
+		s.Sum[hourClientsKey] = n
+		s.Sum[hourVisitorsKey] = u.hourVisitors.Count(now)
+		s.Sum[dayClientsKey] = u.dayClients.Count(now)
+		s.Sum[dayVisitorsKey] = u.dayVisitors.Count(now)
+	}
+}
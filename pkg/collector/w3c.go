@@ -0,0 +1,193 @@
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// W3C extended log file format example (IIS):
+// #Software: Microsoft Internet Information Services 10.0
+// #Version: 1.0
+// #Date: 2018-05-09 16:00:00
+// #Fields: date time s-ip cs-method cs-uri-stem cs-uri-query s-port cs-username c-ip cs(User-Agent) cs(Referer) sc-status sc-substatus sc-win32-status time-taken
+// 2018-05-09 16:00:39 10.0.0.2 GET /api/user id=1 443 - 10.0.0.1 curl/7.58.0 - 200 0 0 15
+
+// w3cDateLayout and w3cTimeLayout are the layouts of the date and time fields (UTC)
+const (
+	w3cDateLayout = "2006-01-02"
+	w3cTimeLayout = "15:04:05"
+)
+
+// w3cSetters are the fields stored into the log message, the other ones are ignored
+var w3cSetters = map[string]func(m *LogMessage, v string) error{
+	"c-ip":           setRemoteAddr,
+	"cs-username":    setUser,
+	"cs-method":      setMethod,
+	"cs-uri-stem":    setPath,
+	"cs-uri":         setPath,
+	"cs-version":     setProtocol,
+	"sc-status":      setCode,
+	"sc-bytes":       setBytes,
+	"cs(referer)":    setW3CReferer,
+	"cs(user-agent)": setW3CUserAgent,
+	"time-taken":     setTimeTaken,
+}
+
+// w3cFields is the column mapping given by a #Fields directive
+type w3cFields struct {
+	// setters of the columns, nil for the ignored ones
+	setters []func(m *LogMessage, v string) error
+	// columns of the date and time, -1 if absent
+	dateCol int
+	timeCol int
+	// date of the #Date directive, used if there is no date column
+	date string
+	// err is returned for all the log entries if the fields lack the mandatory ones
+	err error
+}
+
+// newW3CFields returns the column mapping of the given space separated field names
+// the fields are stored with the given setters
+func newW3CFields(names string, setters map[string]func(m *LogMessage, v string) error) *w3cFields {
+	f := &w3cFields{dateCol: -1, timeCol: -1}
+	seen := map[string]bool{}
+	for i, n := range strings.Fields(names) {
+		n = strings.ToLower(n)
+		switch n {
+		case "date":
+			f.dateCol = i
+		case "time":
+			f.timeCol = i
+		}
+		f.setters = append(f.setters, setters[n])
+		seen[n] = true
+	}
+	if !seen["sc-status"] {
+		f.err = errors.New("w3c extended fields lack sc-status")
+	} else if !seen["cs-uri-stem"] && !seen["cs-uri"] {
+		f.err = errors.New("w3c extended fields lack cs-uri-stem")
+	}
+	return f
+}
+
+// W3CExtendedParser is a parser of the log entries in the W3C extended log file format (IIS, CDNs)
+// the columns are given by the #Fields directives of each source and rebuilt whenever they change
+// the default fields are used for the sources with no #Fields directive read yet (tailing from the end)
+type W3CExtendedParser struct {
+	defaults string
+	setters  map[string]func(m *LogMessage, v string) error
+	sources  map[string]*w3cFields
+}
+
+// NewW3CExtendedParser returns a parser of the W3C extended log entries with the given default fields
+func NewW3CExtendedParser(defaults string) (*W3CExtendedParser, error) {
+	return newW3CExtendedParser(defaults, w3cSetters)
+}
+
+// newW3CExtendedParser returns a parser of the W3C extended log entries
+// storing the fields with the given setters
+func newW3CExtendedParser(defaults string, setters map[string]func(m *LogMessage, v string) error) (*W3CExtendedParser, error) {
+	if f := newW3CFields(defaults, setters); f.err != nil {
+		return nil, fmt.Errorf("default %s", f.err)
+	}
+	return &W3CExtendedParser{
+		defaults: defaults,
+		setters:  setters,
+		sources:  map[string]*w3cFields{},
+	}, nil
+}
+
+// Parse parses the given log entry or directive of an unnamed source
+func (p *W3CExtendedParser) Parse(str string) (*LogMessage, error) {
+	return p.ParseSource("", str)
+}
+
+// ParseSource parses the given log entry or directive of the given source
+// ErrDirective is returned for the directives
+func (p *W3CExtendedParser) ParseSource(source, str string) (*LogMessage, error) {
+	f, ok := p.sources[source]
+	if !ok {
+		f = newW3CFields(p.defaults, p.setters)
+		p.sources[source] = f
+	}
+
+	if strings.HasPrefix(str, "#") {
+		switch {
+		case strings.HasPrefix(str, "#Fields:"):
+			date := f.date
+			f = newW3CFields(str[len("#Fields:"):], p.setters)
+			f.date = date
+			p.sources[source] = f
+		case strings.HasPrefix(str, "#Date:"):
+			if d := strings.Fields(str[len("#Date:"):]); len(d) != 0 {
+				f.date = d[0]
+			}
+		}
+		// #Version, #Software, #Remark and others are ignored
+		return nil, ErrDirective
+	}
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	vals := strings.Fields(str)
+	if len(vals) != len(f.setters) {
+		return nil, errors.New("w3c extended fields number not matched")
+	}
+
+	msg := &LogMessage{}
+	for i, set := range f.setters {
+		if set == nil || vals[i] == "-" {
+			continue
+		}
+		if err := set(msg, vals[i]); err != nil {
+			return nil, err
+		}
+	}
+	if len(msg.path) == 0 {
+		return nil, errors.New("no request path in w3c extended log entry")
+	}
+
+	if f.timeCol != -1 {
+		date := f.date
+		if f.dateCol != -1 {
+			date = vals[f.dateCol]
+		}
+		// fractional seconds are accepted by the parsing
+		t, err := time.Parse(w3cDateLayout+" "+w3cTimeLayout, date+" "+vals[f.timeCol])
+		if err != nil {
+			return nil, errors.New("wrong time format")
+		}
+		msg.Time = t
+	}
+	return msg, nil
+}
+
+func setW3CReferer(m *LogMessage, v string) error {
+	m.Referer = v
+	return nil
+}
+
+// setW3CUserAgent stores the user agent, spaces are written as + by IIS
+func setW3CUserAgent(m *LogMessage, v string) error {
+	m.UserAgent = strings.Replace(v, "+", " ", -1)
+	return nil
+}
+
+// setTimeTaken parses the time taken by the request:
+// integer milliseconds (IIS) or decimal seconds (W3C specification, CDNs)
+func setTimeTaken(m *LogMessage, v string) error {
+	if strings.IndexByte(v, '.') != -1 {
+		return setLatency(m, v)
+	}
+	ms, err := strconv.Atoi(v)
+	if err != nil {
+		return errors.New("wrong time taken format")
+	}
+	m.Latency = time.Duration(ms) * time.Millisecond
+	m.HasLatency = true
+	return nil
+}
@@ -0,0 +1,134 @@
+package collector
+
+import (
+	"testing"
+	"time"
+)
+
+func TestW3CExtendedParser(t *testing.T) {
+	p, err := NewW3CExtendedParser("date time s-ip cs-method cs-uri-stem cs-uri-query s-port cs-username c-ip cs(User-Agent) cs(Referer) sc-status sc-substatus sc-win32-status time-taken")
+	if err != nil {
+		t.Fatalf("Failed to create parser: %s", err)
+	}
+
+	testCases := []struct {
+		name         string
+		input        LogEntry
+		expected     LogMessage
+		expectedErr  bool
+		directiveErr bool
+	}{
+		{
+			name:  "Default fields before any directive",
+			input: LogEntry{Source: "iis.log", Line: "2018-05-09 16:00:39 10.0.0.2 GET /api/user id=1 443 - 10.0.0.1 Mozilla/5.0+(Windows+NT+10.0) - 200 0 0 15"},
+			expected: LogMessage{
+				Section:    "/api",
+				Method:     "GET",
+				Code:       200,
+				Time:       mustParseTime("09/May/2018:16:00:39 +0000"),
+				RemoteAddr: "10.0.0.1",
+				UserAgent:  "Mozilla/5.0 (Windows NT 10.0)",
+				Latency:    15 * time.Millisecond,
+				HasLatency: true,
+				Source:     "iis.log",
+			},
+		},
+		{
+			name:         "Version directive",
+			input:        LogEntry{Source: "iis.log", Line: "#Version: 1.0"},
+			directiveErr: true,
+		},
+		{
+			name:         "Date directive",
+			input:        LogEntry{Source: "iis.log", Line: "#Date: 2018-05-10 00:00:00"},
+			directiveErr: true,
+		},
+		{
+			name:         "Fields directive",
+			input:        LogEntry{Source: "iis.log", Line: "#Fields: time c-ip cs-username cs-method cs-uri-stem cs-version sc-status sc-bytes time-taken"},
+			directiveErr: true,
+		},
+		{
+			name:  "Changed fields with the date directive",
+			input: LogEntry{Source: "iis.log", Line: "00:00:01.250 10.0.0.1 jill POST /users HTTP/1.1 201 512 0.125"},
+			expected: LogMessage{
+				Section:    "/users",
+				Method:     "POST",
+				Code:       201,
+				Time:       mustParseTime("10/May/2018:00:00:01 +0000").Add(250 * time.Millisecond),
+				Protocol:   "HTTP/1.1",
+				RemoteAddr: "10.0.0.1",
+				User:       "jill",
+				Bytes:      512,
+				Latency:    125 * time.Millisecond,
+				HasLatency: true,
+				Source:     "iis.log",
+			},
+		},
+		{
+			name:  "Other source keeps default fields",
+			input: LogEntry{Source: "other.log", Line: "2018-05-09 16:00:39 10.0.0.2 GET / - 80 - 10.0.0.3 - http://example.com/a+b 404 0 2 1"},
+			expected: LogMessage{
+				Section:    "/",
+				Method:     "GET",
+				Code:       404,
+				Time:       mustParseTime("09/May/2018:16:00:39 +0000"),
+				RemoteAddr: "10.0.0.3",
+				Referer:    "http://example.com/a+b",
+				Latency:    time.Millisecond,
+				HasLatency: true,
+				Source:     "other.log",
+			},
+		},
+		{
+			name:        "No request path",
+			input:       LogEntry{Source: "other.log", Line: "2018-05-09 16:00:39 10.0.0.2 GET - - 80 - 10.0.0.3 - - 404 0 2 1"},
+			expectedErr: true,
+		},
+		{
+			name:        "Fields number not matched",
+			input:       LogEntry{Source: "iis.log", Line: "00:00:02 10.0.0.1 - GET /users 201 512 1"},
+			expectedErr: true,
+		},
+		{
+			name:         "Fields without status",
+			input:        LogEntry{Source: "iis.log", Line: "#Fields: date time cs-method cs-uri-stem"},
+			directiveErr: true,
+		},
+		{
+			name:        "Entry of fields without status",
+			input:       LogEntry{Source: "iis.log", Line: "2018-05-09 16:00:39 GET /"},
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			output, err := ParseEntry(p, tc.input)
+			if err == ErrDirective {
+				if !tc.directiveErr {
+					t.Errorf("Test case %q got not expected directive", tc.name)
+				}
+				return
+			}
+			if tc.directiveErr {
+				t.Errorf("Test case %q got no directive while one is expected", tc.name)
+				return
+			}
+			if err != nil {
+				if !tc.expectedErr {
+					t.Errorf("Test case %q got not expected error: %s", tc.name, err)
+				}
+				return
+			}
+			if tc.expectedErr {
+				t.Errorf("Test case %q got no error while one is expected", tc.name)
+				return
+			}
+
+			if !output.Equal(&tc.expected) || output.Source != tc.expected.Source {
+				t.Errorf("Test case %q: output didn't match, got %+v", tc.name, *output)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package collector
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"httplogmonitor/pkg/config"
+)
+
+// windowPanes is the maximum number of the panes of a rolling window:
+// the interval summaries of the long windows are merged into panes of several intervals
+// so that a window keeps and merges a bounded number of summaries on every interval
+const windowPanes = 60
+
+// summaryWindow is an aggregation window summarized after every interval summary:
+// the total since the start or the rolling last intervals merged from their panes
+type summaryWindow struct {
+	name string
+	// intervals is the number of the last interval summaries merged, 0 for the total window
+	intervals int
+	// paneIntervals is the number of the interval summaries merged into a pane, 0 for the total window
+	paneIntervals int
+	// panes are the merged interval summaries, the latest (possibly incomplete) last
+	// the total window has a single pane
+	panes []*pane
+}
+
+// pane is the merge of consecutive interval summaries
+type pane struct {
+	sum *Summary
+	// count is the number of the interval summaries merged
+	count int
+}
+
+// newSummaryWindows returns the aggregation windows of the given configuration
+// fails if a window cannot be parsed
+func newSummaryWindows(cfg *config.Config) ([]*summaryWindow, error) {
+	wins := make([]*summaryWindow, 0, len(cfg.SummaryWindows))
+	for _, w := range cfg.SummaryWindows {
+		n, err := config.ParseSummaryWindow(w, cfg.SummaryIntervalSec)
+		if err != nil {
+			return nil, err
+		}
+		win := &summaryWindow{name: "LAST " + w, intervals: n, paneIntervals: (n + windowPanes - 1) / windowPanes}
+		if n == 0 {
+			win.name = "TOTAL SINCE START"
+		}
+		wins = append(wins, win)
+	}
+	return wins, nil
+}
+
+// summarize merges the given interval summary into the window and returns the summary of the window
+// the rolling windows drop their oldest panes once out of the window,
+// so they cover the last intervals up to the size of a pane less than the window,
+// they're marked as partial with the span covered until the window is filled (and after a pane is dropped)
+// the summary is a new one so that the window keeps on merging while it's printed
+func (w *summaryWindow) summarize(cur *Summary, cfg *config.Config) *Summary {
+	last := len(w.panes) - 1
+	if last < 0 || (w.paneIntervals != 0 && w.panes[last].count == w.paneIntervals) {
+		w.panes = append(w.panes, &pane{sum: NewSummary(cfg.TopSectionNum, cfg.TopCapacity)})
+		last++
+	}
+	w.panes[last].sum.Merge(cur)
+	w.panes[last].count++
+
+	n := 0
+	for _, p := range w.panes {
+		n += p.count
+	}
+	for w.intervals != 0 && n > w.intervals {
+		n -= w.panes[0].count
+		w.panes = w.panes[1:]
+	}
+
+	s := NewSummary(cfg.TopSectionNum, cfg.TopCapacity)
+	for _, p := range w.panes {
+		s.Merge(p.sum)
+	}
+	s.CalcTraffic(n * cfg.SummaryIntervalSec)
+	s.Window = w.name
+	if n < w.intervals {
+		s.Window = fmt.Sprintf("%s (partial, covering %s)", w.name, formatSpan(time.Duration(n*cfg.SummaryIntervalSec)*time.Second))
+	}
+	return s
+}
+
+// formatSpan formats the given duration with no trailing zero units (50m, 1h, 1h30m)
+func formatSpan(d time.Duration) string {
+	str := d.String()
+	if strings.HasSuffix(str, "m0s") {
+		str = strings.TrimSuffix(str, "0s")
+	}
+	if strings.HasSuffix(str, "h0m") {
+		str = strings.TrimSuffix(str, "0m")
+	}
+	return str
+}
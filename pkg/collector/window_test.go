@@ -0,0 +1,54 @@
+package collector
+
+import (
+	"testing"
+
+	"httplogmonitor/pkg/config"
+)
+
+func TestSummaryWindowPanes(t *testing.T) {
+	cfg := config.NewDefault()
+	cfg.SummaryIntervalSec = 10
+
+	testCases := []struct {
+		name      string
+		window    string
+		intervals int
+		hits      int
+		panes     int
+		heading   string
+	}{
+		{name: "Window not filled yet", window: "5m", intervals: 20, hits: 20, panes: 20, heading: "LAST 5m (partial, covering 3m20s)"},
+		{name: "Pane per interval", window: "5m", intervals: 400, hits: 30, panes: 30, heading: "LAST 5m"},
+		{name: "Pane per minute", window: "1h", intervals: 400, hits: 358, panes: 60, heading: "LAST 1h (partial, covering 59m40s)"},
+		{name: "Pane completed", window: "1h", intervals: 402, hits: 360, panes: 60, heading: "LAST 1h"},
+		{name: "Pane dropped", window: "2h", intervals: 721, hits: 709, panes: 60, heading: "LAST 2h (partial, covering 1h58m10s)"},
+		{name: "Total", window: config.SummaryWindowTotal, intervals: 400, hits: 400, panes: 1, heading: "TOTAL SINCE START"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg.SummaryWindows = []string{tc.window}
+			wins, err := newSummaryWindows(cfg)
+			if err != nil {
+				t.Fatalf("Test case %q got an unexpected error: %s", tc.name, err)
+			}
+			w := wins[0]
+			var s *Summary
+			for i := 0; i < tc.intervals; i++ {
+				cur := NewSummary(cfg.TopSectionNum, cfg.TopCapacity)
+				cur.Sum[hitsKey] = 1
+				s = w.summarize(cur, cfg)
+				if len(w.panes) > windowPanes+1 {
+					t.Fatalf("Test case %q: expected %d panes at most, got %d", tc.name, windowPanes+1, len(w.panes))
+				}
+			}
+			if s.Sum[hitsKey] != tc.hits || len(w.panes) != tc.panes {
+				t.Errorf("Test case %q: expected %d hits in %d panes, got %d in %d", tc.name, tc.hits, tc.panes, s.Sum[hitsKey], len(w.panes))
+			}
+			if s.Window != tc.heading {
+				t.Errorf("Test case %q: expected heading %q, got %q", tc.name, tc.heading, s.Window)
+			}
+		})
+	}
+}
@@ -3,46 +3,178 @@ package config
 import (
 	"errors"
 	"flag"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
 )
 
 const (
-	defaultLogFilePath        = "/tmp/access.log"
-	defaultSummaryIntervalSec = 10
-	defaultPollIntervalSec    = 1
-	defaultMonitorWindowSec   = 120
-	defaultAlertThreshold     = 10
-	defaultTopSectionNum      = 10
-	defaultLogBufferSize      = 10
-	defaultMetricBufferSize   = 5
-	defaultVerbose            = false
+	defaultLogFilePath           = "/tmp/access.log"
+	defaultSummaryIntervalSec    = 10
+	defaultPollIntervalSec       = 1
+	defaultMonitorWindowSec      = 120
+	defaultAlertThreshold        = 10
+	defaultBandwidthThreshold    = 0
+	defaultUniqueJump            = 0
+	defaultErrorRatio            = 0
+	defaultServerErrorRatio      = 0
+	defaultMinHits               = 100
+	defaultTopSectionNum         = 10
+	defaultTopCapacity           = 1000
+	defaultTrendIntervals        = 0
+	defaultLogBufferSize         = 10
+	defaultMetricBufferSize      = 5
+	defaultVerbose               = false
+	defaultInotify               = true
+	defaultCheckpointPath        = ""
+	defaultCheckpointIntervalSec = 10
+	defaultReplaySpeed           = 1
+	defaultEventTime             = false
+	defaultLatenessSec           = 2
+	defaultLogFormat             = LogFormatAuto
+	defaultDetectLines           = 100
+	defaultNginxLogFormat        = ""
+	defaultNginxConfPath         = ""
+	defaultNginxFormatName       = "main"
+	defaultApacheLogFormat       = ""
+	defaultJSONFields            = ""
+	defaultSectionDepth          = 1
+	defaultRulesPath             = ""
+	defaultW3CFields             = "date time s-ip cs-method cs-uri-stem cs-uri-query s-port cs-username c-ip cs(User-Agent) cs(Referer) sc-status sc-substatus sc-win32-status time-taken"
 )
 
+// log formats of the log entries
+const (
+	// LogFormatAuto stands for the format detected from the first (or last) lines of the log files
+	LogFormatAuto = "auto"
+	// LogFormatCLF is the common or combined log format
+	LogFormatCLF = "clf"
+	// LogFormatNginx is the nginx log_format
+	LogFormatNginx = "nginx"
+	// LogFormatApache is the apache httpd LogFormat
+	LogFormatApache = "apache"
+	// LogFormatW3C is the W3C extended log file format
+	LogFormatW3C = "w3c"
+	// LogFormatALB is the AWS application (or classic) load balancer access log format
+	LogFormatALB = "alb"
+	// LogFormatCloudFront is the CloudFront standard log format
+	LogFormatCloudFront = "cloudfront"
+	// LogFormatHAProxy is the haproxy HTTP log format
+	LogFormatHAProxy = "haproxy"
+	// LogFormatEnvoy is the envoy default access log format
+	LogFormatEnvoy = "envoy"
+	// LogFormatJSON is the json objects, one per line
+	LogFormatJSON = "json"
+)
+
+// SummaryWindowTotal is the aggregation window cumulative since the start
+const SummaryWindowTotal = "total"
+
 // Config stores the configuration to the whole program
 type Config struct {
-	LogFilePath        string
+	LogFilePaths       []string
 	SummaryIntervalSec int
 	PollIntervalSec    int
 	MonitorWindowSec   int
 	AlertThreshold     int
-	TopSectionNum      int
-	LogBufferSize      int
-	MetricBufferSize   int
-	Verbose            bool
+	// BandwidthThreshold is the bandwidth alerting threshold (bytes per second), 0 disables the bandwidth alerting
+	BandwidthThreshold int
+	// UniqueJump is the unique clients alerting factor: the alert is triggered when the unique clients of a summary interval
+	// reach UniqueJump times their average over the monitoring window, 0 disables the unique clients alerting
+	UniqueJump float64
+	// ErrorRatioThreshold and ServerErrorRatioThreshold are the alerting thresholds of the ratios of the errors (4xx and 5xx)
+	// and the server errors (5xx) to the hits for the monitoring window, from 0 to 1, 0 disables them
+	ErrorRatioThreshold       float64
+	ServerErrorRatioThreshold float64
+	// MinHits is the minimum volume (hits of the monitoring window) for the ratio alerts to be evaluated
+	MinHits       int
+	TopSectionNum int
+	// TopCapacity is the number of the keys (sections, paths, clients, ...) tracked by each top table,
+	// the counts are overestimated by at most hits/TopCapacity once more keys are seen
+	TopCapacity int
+	// TrendIntervals is the number of the previous summaries averaged to show the trends of the summary, 0 disables the trends
+	TrendIntervals int
+	// SummaryWindows are the aggregation windows summarized after every interval summary:
+	// total (cumulative since the start) or rolling durations (5m, 1h) multiple of the summary interval
+	SummaryWindows   []string
+	LogBufferSize    int
+	MetricBufferSize int
+	Verbose          bool
+	Inotify          bool
+	// CheckpointPath is the file to save the positions in the log files to, no checkpoints if empty
+	CheckpointPath        string
+	CheckpointIntervalSec int
+	// ReplaySpeed is the multiplier of the log time in the replay mode, 0 stands for the maximum speed
+	ReplaySpeed float64
+	// EventTime makes the alerting count the hits by the log entries' timestamps instead of the read time
+	EventTime   bool
+	LatenessSec int
+	// LogFormat is the format of the log entries: auto, clf, nginx, apache, w3c, alb, cloudfront, haproxy, envoy or json
+	LogFormat string
+	// DetectLines is the number of the log lines sampled to detect the format
+	DetectLines int
+	// NginxLogFormat is the nginx log format of the log entries,
+	// or it's read from the log_format directive named NginxFormatName of the nginx config file at NginxConfPath
+	NginxLogFormat  string
+	NginxConfPath   string
+	NginxFormatName string
+	// ApacheLogFormat is the apache httpd LogFormat of the log entries
+	ApacheLogFormat string
+	// JSONFields maps the json fields to the log message fields: presets and key=field pairs separated by commas
+	JSONFields string
+	// W3CFields are the W3C extended fields of the log entries until a #Fields directive is read (IIS defaults)
+	W3CFields string
+	// SectionDepth is the number of the path segments kept in the sections, 0 keeps the whole path
+	SectionDepth int
+	// SectionRoutes are the route templates (/users/:id/orders/:id, /static/*) the matching paths are counted as
+	SectionRoutes []string
+	// SectionRules are the regexp=replacement rules rewriting the paths of the sections (IDs normalization)
+	SectionRules []string
+	// RulesPath is the json file the alert rules are loaded from into Rules, no other rules than the flags' ones if empty
+	RulesPath string
+	Rules     []Rule
+	// Analyze is set for the offline analysis which neither polls nor monitors: its polling interval and window are not validated
+	Analyze bool
 }
 
 // NewDefault returns the configuration with only default values
 func NewDefault() *Config {
 	return &Config{
-		LogFilePath:        defaultLogFilePath,
-		SummaryIntervalSec: defaultSummaryIntervalSec,
-		PollIntervalSec:    defaultPollIntervalSec,
-		MonitorWindowSec:   defaultMonitorWindowSec,
-		AlertThreshold:     defaultAlertThreshold,
-		TopSectionNum:      defaultTopSectionNum,
-		LogBufferSize:      defaultLogBufferSize,
-		MetricBufferSize:   defaultMetricBufferSize,
-		Verbose:            defaultVerbose,
+		LogFilePaths:              []string{defaultLogFilePath},
+		SummaryIntervalSec:        defaultSummaryIntervalSec,
+		PollIntervalSec:           defaultPollIntervalSec,
+		MonitorWindowSec:          defaultMonitorWindowSec,
+		AlertThreshold:            defaultAlertThreshold,
+		BandwidthThreshold:        defaultBandwidthThreshold,
+		UniqueJump:                defaultUniqueJump,
+		ErrorRatioThreshold:       defaultErrorRatio,
+		ServerErrorRatioThreshold: defaultServerErrorRatio,
+		MinHits:                   defaultMinHits,
+		TopSectionNum:             defaultTopSectionNum,
+		TopCapacity:               defaultTopCapacity,
+		TrendIntervals:            defaultTrendIntervals,
+		LogBufferSize:             defaultLogBufferSize,
+		MetricBufferSize:          defaultMetricBufferSize,
+		Verbose:                   defaultVerbose,
+		Inotify:                   defaultInotify,
+		CheckpointPath:            defaultCheckpointPath,
+		CheckpointIntervalSec:     defaultCheckpointIntervalSec,
+		ReplaySpeed:               defaultReplaySpeed,
+		EventTime:                 defaultEventTime,
+		LatenessSec:               defaultLatenessSec,
+		LogFormat:                 defaultLogFormat,
+		DetectLines:               defaultDetectLines,
+		NginxLogFormat:            defaultNginxLogFormat,
+		NginxConfPath:             defaultNginxConfPath,
+		NginxFormatName:           defaultNginxFormatName,
+		ApacheLogFormat:           defaultApacheLogFormat,
+		JSONFields:                defaultJSONFields,
+		W3CFields:                 defaultW3CFields,
+		SectionDepth:              defaultSectionDepth,
+		RulesPath:                 defaultRulesPath,
 	}
 }
 
@@ -53,14 +185,64 @@ func NewFromArgs() *Config {
 		MetricBufferSize: defaultMetricBufferSize,
 	}
 
-	flag.StringVar(&cfg.LogFilePath, "f", defaultLogFilePath, "Path to the log file.")
+	paths := newPathList(defaultLogFilePath)
+	flag.Var(paths, "f", "Path to the log file, can be a glob pattern. Repeat the flag to tail multiple files.")
 	flag.IntVar(&cfg.SummaryIntervalSec, "i", defaultSummaryIntervalSec, "Interval between summary displays (seconds).")
 	flag.IntVar(&cfg.PollIntervalSec, "p", defaultPollIntervalSec, "Polling interval (seconds).")
 	flag.IntVar(&cfg.MonitorWindowSec, "w", defaultMonitorWindowSec, "Monitoring window (seconds).")
 	flag.IntVar(&cfg.AlertThreshold, "t", defaultAlertThreshold, "Alerting threshold (hits per second).")
+	flag.Var((*sizeValue)(&cfg.BandwidthThreshold), "bt", "Bandwidth alerting threshold (bytes per second with the optional K, M or G suffix, 0 disables it).")
+	flag.Float64Var(&cfg.UniqueJump, "uj", defaultUniqueJump, "Unique clients alerting factor: alert when the unique clients of a summary interval reach the given multiple of their average over the monitoring window (5 for 5x, 0 disables it).")
+	flag.Float64Var(&cfg.ErrorRatioThreshold, "er", defaultErrorRatio, "Error ratio alerting threshold: alert when the errors (4xx and 5xx) reach the given ratio of the hits for the monitoring window (0.1 for 10%, 0 disables it).")
+	flag.Float64Var(&cfg.ServerErrorRatioThreshold, "sr", defaultServerErrorRatio, "5xx ratio alerting threshold: alert when the server errors (5xx) reach the given ratio of the hits for the monitoring window (0.05 for 5%, 0 disables it).")
+	flag.IntVar(&cfg.MinHits, "min-hits", defaultMinHits, "Minimum hits of the monitoring window for the error and 5xx ratio alerts to be triggered.")
 	flag.IntVar(&cfg.TopSectionNum, "n", defaultTopSectionNum, "How many most hitted sections need to be displayed.")
+	flag.IntVar(&cfg.TopCapacity, "top-capacity", defaultTopCapacity, "Number of the keys (sections, paths, clients, ...) tracked by each top table, the counts are overestimated by at most hits/capacity beyond it.")
+	flag.IntVar(&cfg.TrendIntervals, "trend", defaultTrendIntervals, "Number of the previous summaries the hits, errors and top sections are compared to (averaged), 0 disables the trends.")
+	flag.Var((*stringList)(&cfg.SummaryWindows), "window", "Aggregation window summarized after every interval summary: total (since the start) or rolling duration (5m, 1h) multiple of the summary interval. Repeat the flag to add windows.")
 	flag.BoolVar(&cfg.Verbose, "v", defaultVerbose, "Be verbose (show regular average traffic stats).")
+	flag.StringVar(&cfg.CheckpointPath, "c", defaultCheckpointPath, "Path to the checkpoint file to resume reading the log files after a restart (no checkpoints if empty).")
+	flag.IntVar(&cfg.CheckpointIntervalSec, "ci", defaultCheckpointIntervalSec, "Interval between checkpoint saves (seconds).")
+	flag.StringVar(&cfg.RulesPath, "rules", defaultRulesPath, "Path to the json file of the alert rules (name, metric, section, window, op, threshold) evaluated besides the -t and -bt ones.")
+	flag.BoolVar(&cfg.EventTime, "et", defaultEventTime, "Count the hits for alerting by the log entries' timestamps instead of the read time.")
+	flag.IntVar(&cfg.LatenessSec, "l", defaultLatenessSec, "Allowed lateness of the log entries in the event time mode (seconds).")
+	flag.BoolVar(&cfg.Inotify, "e", defaultInotify, "Read the log file on every change using inotify (Linux only), polling is kept as a fallback.")
+	cfg.addFormatFlags(flag.CommandLine)
+	cfg.addSectionFlags(flag.CommandLine)
 	flag.Parse()
+	cfg.LogFilePaths = paths.paths
+
+	rules, err := LoadRules(cfg.RulesPath)
+	if err != nil {
+		panic(err)
+	}
+	cfg.Rules = rules
+
+	err = cfg.Validate()
+	if err != nil {
+		panic(err)
+	}
+
+	return cfg
+}
+
+// NewAnalyzeFromArgs returns the configuration of the analyze mode filled from the given flags
+// only the flags meaningful for the analysis of complete log files are accepted
+func NewAnalyzeFromArgs(args []string) *Config {
+	cfg := NewDefault()
+	cfg.Analyze = true
+
+	fs := flag.NewFlagSet("analyze", flag.ExitOnError)
+	paths := newPathList(defaultLogFilePath)
+	fs.Var(paths, "f", "Path to the log file (plain or gzipped), can be a glob pattern. Repeat the flag to analyze multiple files.")
+	fs.IntVar(&cfg.SummaryIntervalSec, "i", defaultSummaryIntervalSec, "Interval of the breakdown summaries (seconds).")
+	fs.IntVar(&cfg.TopSectionNum, "n", defaultTopSectionNum, "How many most hitted sections need to be displayed.")
+	fs.IntVar(&cfg.TopCapacity, "top-capacity", defaultTopCapacity, "Number of the keys (sections, paths, clients, ...) tracked by each top table, the counts are overestimated by at most hits/capacity beyond it.")
+	fs.IntVar(&cfg.TrendIntervals, "trend", defaultTrendIntervals, "Number of the previous summaries the hits, errors and top sections are compared to (averaged), 0 disables the trends.")
+	cfg.addFormatFlags(fs)
+	cfg.addSectionFlags(fs)
+	fs.Parse(args)
+	cfg.LogFilePaths = paths.paths
 
 	err := cfg.Validate()
 	if err != nil {
@@ -70,18 +252,57 @@ func NewFromArgs() *Config {
 	return cfg
 }
 
-// Validate validates the important fields of the configuration
-func (c *Config) Validate() error {
-	if len(strings.TrimSpace(c.LogFilePath)) == 0 {
-		return errors.New("No log file provided")
+// NewReplayFromArgs returns the configuration of the replay mode filled from the given flags
+// all the flags of the live mode are accepted but the ones of the tailing
+func NewReplayFromArgs(args []string) *Config {
+	cfg := NewDefault()
+
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	paths := newPathList(defaultLogFilePath)
+	speed := speedValue(defaultReplaySpeed)
+	fs.Var(paths, "f", "Path to the log file (plain or gzipped), can be a glob pattern. Repeat the flag to replay multiple files one after another.")
+	fs.Var(&speed, "x", "Replay speed: multiplier of the log time (1, 10, 0.5, ...) or max.")
+	fs.IntVar(&cfg.SummaryIntervalSec, "i", defaultSummaryIntervalSec, "Interval between summary displays (seconds of the log time).")
+	fs.IntVar(&cfg.PollIntervalSec, "p", defaultPollIntervalSec, "Polling interval (seconds of the log time).")
+	fs.IntVar(&cfg.MonitorWindowSec, "w", defaultMonitorWindowSec, "Monitoring window (seconds of the log time).")
+	fs.IntVar(&cfg.AlertThreshold, "t", defaultAlertThreshold, "Alerting threshold (hits per second).")
+	fs.Var((*sizeValue)(&cfg.BandwidthThreshold), "bt", "Bandwidth alerting threshold (bytes per second with the optional K, M or G suffix, 0 disables it).")
+	fs.Float64Var(&cfg.UniqueJump, "uj", defaultUniqueJump, "Unique clients alerting factor: alert when the unique clients of a summary interval reach the given multiple of their average over the monitoring window (5 for 5x, 0 disables it).")
+	fs.Float64Var(&cfg.ErrorRatioThreshold, "er", defaultErrorRatio, "Error ratio alerting threshold: alert when the errors (4xx and 5xx) reach the given ratio of the hits for the monitoring window (0.1 for 10%, 0 disables it).")
+	fs.Float64Var(&cfg.ServerErrorRatioThreshold, "sr", defaultServerErrorRatio, "5xx ratio alerting threshold: alert when the server errors (5xx) reach the given ratio of the hits for the monitoring window (0.05 for 5%, 0 disables it).")
+	fs.IntVar(&cfg.MinHits, "min-hits", defaultMinHits, "Minimum hits of the monitoring window for the error and 5xx ratio alerts to be triggered.")
+	fs.IntVar(&cfg.TopSectionNum, "n", defaultTopSectionNum, "How many most hitted sections need to be displayed.")
+	fs.IntVar(&cfg.TopCapacity, "top-capacity", defaultTopCapacity, "Number of the keys (sections, paths, clients, ...) tracked by each top table, the counts are overestimated by at most hits/capacity beyond it.")
+	fs.IntVar(&cfg.TrendIntervals, "trend", defaultTrendIntervals, "Number of the previous summaries the hits, errors and top sections are compared to (averaged), 0 disables the trends.")
+	fs.Var((*stringList)(&cfg.SummaryWindows), "window", "Aggregation window summarized after every interval summary: total (since the start) or rolling duration (5m, 1h) multiple of the summary interval (log time). Repeat the flag to add windows.")
+	fs.BoolVar(&cfg.Verbose, "v", defaultVerbose, "Be verbose (show regular average traffic stats).")
+	fs.StringVar(&cfg.RulesPath, "rules", defaultRulesPath, "Path to the json file of the alert rules (name, metric, section, window, op, threshold) evaluated besides the -t and -bt ones.")
+	fs.BoolVar(&cfg.EventTime, "et", defaultEventTime, "Count the hits for alerting by the log entries' timestamps instead of the replay time.")
+	fs.IntVar(&cfg.LatenessSec, "l", defaultLatenessSec, "Allowed lateness of the log entries in the event time mode (seconds).")
+	cfg.addFormatFlags(fs)
+	cfg.addSectionFlags(fs)
+	fs.Parse(args)
+	cfg.LogFilePaths = paths.paths
+	cfg.ReplaySpeed = float64(speed)
+
+	rules, err := LoadRules(cfg.RulesPath)
+	if err != nil {
+		panic(err)
 	}
+	cfg.Rules = rules
 
-	if c.PollIntervalSec <= 0 {
-		return errors.New("polling interval cannot be less than 1 second")
+	err = cfg.Validate()
+	if err != nil {
+		panic(err)
 	}
 
-	if c.SummaryIntervalSec <= 0 {
-		return errors.New("interval between summary displays cannot be less than 1 second")
+	return cfg
+}
+
+// validateMonitoring validates the polling interval and the monitoring window
+func (c *Config) validateMonitoring() error {
+	if c.PollIntervalSec <= 0 {
+		return errors.New("polling interval cannot be less than 1 second")
 	}
 
 	if c.PollIntervalSec >= c.SummaryIntervalSec {
@@ -96,14 +317,287 @@ func (c *Config) Validate() error {
 	if c.MonitorWindowSec%c.PollIntervalSec != 0 {
 		return errors.New("polling interval must be a divisor of monitoring window value. Try 1s for the polling interval, it's a good divisor ;)")
 	}
+	return nil
+}
+
+// addFormatFlags adds the flags of the log format to the given flag set
+func (c *Config) addFormatFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.LogFormat, "format", defaultLogFormat, "Format of the log entries: auto (detected from the log files), clf (common or combined), nginx, apache, w3c (extended), alb (AWS ALB/ELB), cloudfront, haproxy, envoy or json.")
+	fs.IntVar(&c.DetectLines, "detect-lines", defaultDetectLines, "Number of log lines sampled to detect the log format (auto format).")
+	fs.StringVar(&c.NginxLogFormat, "nginx-format", defaultNginxLogFormat, "Nginx log_format of the log entries ('$remote_addr - $remote_user [$time_local] ...').")
+	fs.StringVar(&c.NginxConfPath, "nginx-conf", defaultNginxConfPath, "Path to the nginx config file to read the log_format from.")
+	fs.StringVar(&c.NginxFormatName, "nginx-format-name", defaultNginxFormatName, "Name of the log_format to read from the nginx config file.")
+	fs.StringVar(&c.ApacheLogFormat, "apache-format", defaultApacheLogFormat, "Apache LogFormat of the log entries ('%h %l %u %t \\\"%r\\\" %>s %b ...').")
+	fs.StringVar(&c.W3CFields, "w3c-fields", defaultW3CFields, "W3C extended fields of the log entries until a #Fields directive is read.")
+	fs.StringVar(&c.JSONFields, "json-fields", defaultJSONFields, "Mapping of the json fields: presets (nginx, caddy) and key=field pairs separated by commas (nginx preset by default).")
+}
+
+// addSectionFlags adds the flags of the section extraction to the given flag set
+func (c *Config) addSectionFlags(fs *flag.FlagSet) {
+	fs.IntVar(&c.SectionDepth, "section-depth", defaultSectionDepth, "Number of path segments kept in the sections (0 for the whole path).")
+	fs.Var((*stringList)(&c.SectionRoutes), "route", "Route template the matching paths are counted as (/users/:id/orders/:id, /static/*). Repeat the flag to add routes.")
+	fs.Var((*stringList)(&c.SectionRules), "section-rule", "Rule rewriting the paths before the depth is applied: regexp=replacement (/[0-9]+(/|$)=/:id$1). Repeat the flag to add rules.")
+}
+
+// Validate validates the important fields of the configuration
+func (c *Config) Validate() error {
+	if len(c.LogFilePaths) == 0 {
+		return errors.New("No log file provided")
+	}
+
+	for _, p := range c.LogFilePaths {
+		if len(strings.TrimSpace(p)) == 0 {
+			return errors.New("No log file provided")
+		}
+		if _, err := filepath.Match(p, ""); err != nil {
+			return fmt.Errorf("wrong log file pattern %q: %s", p, err)
+		}
+	}
+
+	if c.SummaryIntervalSec <= 0 {
+		return errors.New("interval between summary displays cannot be less than 1 second")
+	}
+
+	// the offline analysis neither polls nor monitors
+	if !c.Analyze {
+		if err := c.validateMonitoring(); err != nil {
+			return err
+		}
+	}
 
 	if c.AlertThreshold <= 0 {
 		return errors.New("alert threshold cannot be less than 1 hit per second")
 	}
 
+	if c.BandwidthThreshold < 0 {
+		return errors.New("bandwidth alert threshold cannot be negative")
+	}
+
+	if c.UniqueJump != 0 && c.UniqueJump <= 1 {
+		return errors.New("unique clients alerting factor must be greater than 1")
+	}
+
+	if c.ErrorRatioThreshold < 0 || c.ErrorRatioThreshold > 1 || c.ServerErrorRatioThreshold < 0 || c.ServerErrorRatioThreshold > 1 {
+		return errors.New("error and 5xx ratio alerting thresholds must be between 0 and 1")
+	}
+
+	if c.MinHits < 0 {
+		return errors.New("minimum hits of the ratio alerts cannot be negative")
+	}
+
 	if c.TopSectionNum <= 0 {
 		return errors.New("number of most hitted sections cannot be less than 1")
 	}
 
+	if c.TopCapacity < c.TopSectionNum {
+		return errors.New("capacity of the top tables cannot be less than the number of most hitted sections")
+	}
+
+	if c.TrendIntervals < 0 {
+		return errors.New("number of the trend intervals cannot be negative")
+	}
+
+	for _, w := range c.SummaryWindows {
+		if _, err := ParseSummaryWindow(w, c.SummaryIntervalSec); err != nil {
+			return err
+		}
+	}
+
+	if c.LatenessSec < 0 {
+		return errors.New("allowed lateness cannot be negative")
+	}
+
+	if c.ReplaySpeed < 0 {
+		return errors.New("replay speed cannot be negative")
+	}
+
+	if c.LogFormat == LogFormatAuto && c.DetectLines <= 0 {
+		return errors.New("number of log lines sampled to detect the format cannot be less than 1")
+	}
+
+	switch c.LogFormat {
+	case LogFormatAuto, LogFormatCLF, LogFormatALB, LogFormatCloudFront, LogFormatHAProxy, LogFormatEnvoy, LogFormatJSON:
+	case LogFormatW3C:
+		if len(strings.TrimSpace(c.W3CFields)) == 0 {
+			return errors.New("no w3c extended fields provided")
+		}
+	case LogFormatNginx:
+		if len(c.NginxLogFormat) == 0 && len(c.NginxConfPath) == 0 {
+			return errors.New("nginx log format or nginx config file must be given for the nginx log format")
+		}
+		if len(c.NginxLogFormat) != 0 && len(c.NginxConfPath) != 0 {
+			return errors.New("nginx log format and nginx config file cannot be given together")
+		}
+		if len(c.NginxConfPath) != 0 && len(c.NginxFormatName) == 0 {
+			return errors.New("no nginx log format name provided")
+		}
+	case LogFormatApache:
+		if len(c.ApacheLogFormat) == 0 {
+			return errors.New("apache log format must be given for the apache log format")
+		}
+	default:
+		return fmt.Errorf("unknown log format %q", c.LogFormat)
+	}
+
+	// the format specific flags are also taken as candidates of the format detection
+	if (len(c.NginxLogFormat) != 0 || len(c.NginxConfPath) != 0) && c.LogFormat != LogFormatNginx && c.LogFormat != LogFormatAuto {
+		return fmt.Errorf("nginx log format or nginx config file cannot be given for the %s log format", c.LogFormat)
+	}
+	if len(c.ApacheLogFormat) != 0 && c.LogFormat != LogFormatApache && c.LogFormat != LogFormatAuto {
+		return fmt.Errorf("apache log format cannot be given for the %s log format", c.LogFormat)
+	}
+
+	if c.SectionDepth < 0 {
+		return errors.New("section depth cannot be negative")
+	}
+
+	for _, r := range c.SectionRoutes {
+		if !strings.HasPrefix(r, "/") {
+			return fmt.Errorf("route %q must start with /", r)
+		}
+	}
+
+	for _, r := range c.SectionRules {
+		re, _, err := SplitSectionRule(r)
+		if err != nil {
+			return err
+		}
+		if _, err := regexp.Compile(re); err != nil {
+			return fmt.Errorf("wrong section rule %q: %s", r, err)
+		}
+	}
+
+	if len(c.CheckpointPath) != 0 && c.CheckpointIntervalSec <= 0 {
+		return errors.New("interval between checkpoint saves cannot be less than 1 second")
+	}
+
+	if err := c.validateRules(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// pathList is a flag value accumulating the paths given by the repeated flag
+// the default paths are replaced by the first given one
+type pathList struct {
+	paths []string
+	set   bool
+}
+
+// newPathList returns a new instance of pathList with the given default paths
+func newPathList(defaults ...string) *pathList {
+	return &pathList{paths: defaults}
+}
+
+// String returns the paths separated by commas
+func (l *pathList) String() string {
+	return strings.Join(l.paths, ",")
+}
+
+// Set adds the given path to the list
+func (l *pathList) Set(p string) error {
+	if !l.set {
+		l.paths = nil
+		l.set = true
+	}
+	l.paths = append(l.paths, p)
+	return nil
+}
+
+// stringList is a flag value accumulating the values given by the repeated flag
+type stringList []string
+
+// String returns the values separated by commas
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+// Set adds the given value to the list
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+// ParseSummaryWindow returns the number of the summary intervals of the given aggregation window,
+// 0 for the total window (cumulative since the start)
+// the rolling windows must be durations multiple of the given summary interval
+func ParseSummaryWindow(w string, intervalSec int) (int, error) {
+	if w == SummaryWindowTotal {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(w)
+	if err != nil {
+		return 0, fmt.Errorf("wrong summary window %q: total or duration (5m, 1h) expected", w)
+	}
+	interval := time.Duration(intervalSec) * time.Second
+	if d < interval || d%interval != 0 {
+		return 0, fmt.Errorf("summary window %q must be a multiple of the summary interval (%ds)", w, intervalSec)
+	}
+	return int(d / interval), nil
+}
+
+// SplitSectionRule splits the given section rule into its regexp and replacement
+// separated by the last =, the replacement may be empty
+func SplitSectionRule(rule string) (string, string, error) {
+	i := strings.LastIndex(rule, "=")
+	if i <= 0 {
+		return "", "", fmt.Errorf("wrong section rule %q: regexp=replacement expected", rule)
+	}
+	return rule[:i], rule[i+1:], nil
+}
+
+// sizeUnits are the multipliers of the size suffixes (powers of 1024)
+var sizeUnits = map[string]int{"K": 1 << 10, "M": 1 << 20, "G": 1 << 30}
+
+// sizeValue is a flag value of a byte size
+// accepts the number of bytes with the optional K, M or G suffix (512, 100K, 10M)
+type sizeValue int
+
+// String returns the number of bytes
+func (v *sizeValue) String() string {
+	return strconv.Itoa(int(*v))
+}
+
+// Set parses the given size
+func (v *sizeValue) Set(s string) error {
+	mul := 1
+	if len(s) != 0 {
+		if m, ok := sizeUnits[strings.ToUpper(s[len(s)-1:])]; ok {
+			mul = m
+			s = s[:len(s)-1]
+		}
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return errors.New("size must be a non negative number of bytes with the optional K, M or G suffix")
+	}
+	*v = sizeValue(n * mul)
+	return nil
+}
+
+// speedValue is a flag value of the replay speed
+// accepts the multiplier with the optional x suffix (10, 10x) or max (stored as 0)
+type speedValue float64
+
+// String returns the multiplier or max
+func (v *speedValue) String() string {
+	if *v == 0 {
+		return "max"
+	}
+	return strconv.FormatFloat(float64(*v), 'g', -1, 64)
+}
+
+// Set parses the given speed
+func (v *speedValue) Set(s string) error {
+	if s == "max" {
+		*v = 0
+		return nil
+	}
+	f, err := strconv.ParseFloat(strings.TrimSuffix(s, "x"), 64)
+	if err != nil || f <= 0 {
+		return errors.New("speed must be a positive multiplier or max")
+	}
+	*v = speedValue(f)
 	return nil
 }
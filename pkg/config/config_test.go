@@ -1,6 +1,10 @@
 package config
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
 	"testing"
 )
 
@@ -20,6 +24,21 @@ func TestValidateConfig(t *testing.T) {
 			input:         newDefaultLog("\t   \n"),
 			expectedError: true,
 		},
+		{
+			name:          "No log files",
+			input:         newDefaultLogs(),
+			expectedError: true,
+		},
+		{
+			name:          "Log file pattern",
+			input:         newDefaultLogs("/var/log/nginx/*.access.log", "/tmp/access.log"),
+			expectedError: false,
+		},
+		{
+			name:          "Wrong log file pattern",
+			input:         newDefaultLogs("/var/log/nginx/[.log"),
+			expectedError: true,
+		},
 		{
 			name:          "Poll interval too small",
 			input:         newDefaultPoll(0),
@@ -35,6 +54,16 @@ func TestValidateConfig(t *testing.T) {
 			input:         newDefaultPollSum(5, 5),
 			expectedError: true,
 		},
+		{
+			name:          "Analysis summary interval of 1 second",
+			input:         newDefaultAnalyzeSum(1),
+			expectedError: false,
+		},
+		{
+			name:          "Analysis summary interval too small",
+			input:         newDefaultAnalyzeSum(0),
+			expectedError: true,
+		},
 		{
 			name:          "Monitoring window too small",
 			input:         newDefaultWin(0),
@@ -50,11 +79,235 @@ func TestValidateConfig(t *testing.T) {
 			input:         newDefaultAlert(0),
 			expectedError: true,
 		},
+		{
+			name:          "Bandwidth threshold is negative",
+			input:         newDefaultBandwidth(-1),
+			expectedError: true,
+		},
+		{
+			name:  "Unique clients jump",
+			input: newDefaultUniqueJump(5),
+		},
+		{
+			name:          "Unique clients jump is too small",
+			input:         newDefaultUniqueJump(0.5),
+			expectedError: true,
+		},
 		{
 			name:          "Top section is too small",
 			input:         newDefaultTop(0),
 			expectedError: true,
 		},
+		{
+			name:  "Top capacity",
+			input: newDefaultTopCapacity(10, 10),
+		},
+		{
+			name:          "Top capacity is below the top sections",
+			input:         newDefaultTopCapacity(10, 5),
+			expectedError: true,
+		},
+		{
+			name:  "Trends disabled",
+			input: newDefaultTrend(0),
+		},
+		{
+			name:          "Trend intervals are negative",
+			input:         newDefaultTrend(-1),
+			expectedError: true,
+		},
+		{
+			name:  "Summary windows",
+			input: newDefaultWindows("total", "5m", "1h"),
+		},
+		{
+			name:          "Summary window is unknown",
+			input:         newDefaultWindows("forever"),
+			expectedError: true,
+		},
+		{
+			name:          "Summary window is not a multiple of the interval",
+			input:         newDefaultWindows("15s"),
+			expectedError: true,
+		},
+		{
+			name:          "Summary window is shorter than the interval",
+			input:         newDefaultWindows("5s"),
+			expectedError: true,
+		},
+		{
+			name: "Alert rules",
+			input: newDefaultRules(
+				Rule{Name: "api_traffic", Metric: RuleMetricSectionHits, Section: "/api", WindowSec: 60, Op: ">", Threshold: 50},
+				Rule{Name: "slow", Metric: RuleMetricLatencyP99, Op: ">=", Threshold: 500},
+				Rule{Name: "no_traffic", Metric: RuleMetricHits, Op: "<", Threshold: 1},
+				Rule{Name: "errors", Metric: RuleMetricErrorRatio, Op: ">=", Threshold: 0.1, MinHits: 50},
+			),
+		},
+		{
+			name:          "Alert rule has the name of a flag's rule",
+			input:         newDefaultRules(Rule{Name: HighTrafficRule, Metric: RuleMetricHits, Op: ">", Threshold: 50}),
+			expectedError: true,
+		},
+		{
+			name:          "Alert rule metric is unknown",
+			input:         newDefaultRules(Rule{Name: "cpu", Metric: "cpu", Op: ">", Threshold: 50}),
+			expectedError: true,
+		},
+		{
+			name:          "Alert rule comparison is unknown",
+			input:         newDefaultRules(Rule{Name: "errors", Metric: RuleMetricErrors, Op: "=", Threshold: 50}),
+			expectedError: true,
+		},
+		{
+			name:          "Alert rule has no section",
+			input:         newDefaultRules(Rule{Name: "api_traffic", Metric: RuleMetricSectionHits, Op: ">", Threshold: 50}),
+			expectedError: true,
+		},
+		{
+			name:          "Alert rule ratio is greater than 1",
+			input:         newDefaultRules(Rule{Name: "5xx", Metric: RuleMetricServerErrorRatio, Op: ">", Threshold: 5}),
+			expectedError: true,
+		},
+		{
+			name:          "Alert rule window is negative",
+			input:         newDefaultRules(Rule{Name: "errors", Metric: RuleMetricErrors, WindowSec: -10, Op: ">", Threshold: 5}),
+			expectedError: true,
+		},
+		{
+			name:  "Error ratio alerting",
+			input: newDefaultRatios(0.1, 0.05, 0),
+		},
+		{
+			name:          "Error ratio is greater than 1",
+			input:         newDefaultRatios(10, 0, 100),
+			expectedError: true,
+		},
+		{
+			name:          "5xx ratio is negative",
+			input:         newDefaultRatios(0, -0.05, 100),
+			expectedError: true,
+		},
+		{
+			name:          "Minimum hits are negative",
+			input:         newDefaultRatios(0.1, 0.05, -1),
+			expectedError: true,
+		},
+		{
+			name:          "Alert rule has minimum hits of the traffic",
+			input:         newDefaultRules(Rule{Name: "no_traffic", Metric: RuleMetricHits, Op: "<", Threshold: 1, MinHits: 10}),
+			expectedError: true,
+		},
+		{
+			name:          "Lateness is negative",
+			input:         newDefaultLateness(-1),
+			expectedError: true,
+		},
+		{
+			name:          "Checkpoint interval is too small",
+			input:         newDefaultCheckpoint("/tmp/checkpoints.json", 0),
+			expectedError: true,
+		},
+		{
+			name:          "Checkpoint interval is ignored without checkpoints",
+			input:         newDefaultCheckpoint("", 0),
+			expectedError: false,
+		},
+		{
+			name:          "Nginx log format",
+			input:         newDefaultNginx("$remote_addr [$time_local] \"$request\" $status", ""),
+			expectedError: false,
+		},
+		{
+			name:          "Nginx log format and config file",
+			input:         newDefaultNginx("$remote_addr [$time_local] \"$request\" $status", "/etc/nginx/nginx.conf"),
+			expectedError: true,
+		},
+		{
+			name:          "No nginx log format",
+			input:         newDefaultNginx("", ""),
+			expectedError: true,
+		},
+		{
+			name:          "Apache log format",
+			input:         newDefaultFormat(LogFormatApache, `%h %l %u %t "%r" %>s %b`),
+			expectedError: false,
+		},
+		{
+			name:          "No apache log format",
+			input:         newDefaultFormat(LogFormatApache, ""),
+			expectedError: true,
+		},
+		{
+			name:          "JSON log format",
+			input:         newDefaultFormat(LogFormatJSON, ""),
+			expectedError: false,
+		},
+		{
+			name:          "Apache log format given for another format",
+			input:         newDefaultFormat(LogFormatCLF, `%h %l %u %t "%r" %>s %b`),
+			expectedError: true,
+		},
+		{
+			name:          "Apache log format given for the detection",
+			input:         newDefaultFormat(LogFormatAuto, `%h %l %u %t "%r" %>s %b`),
+			expectedError: false,
+		},
+		{
+			name:          "Nginx log format given for another format",
+			input:         newDefaultNginxFor(LogFormatJSON, "$remote_addr [$time_local] \"$request\" $status", ""),
+			expectedError: true,
+		},
+		{
+			name:          "Nginx config file given for another format",
+			input:         newDefaultNginxFor(LogFormatHAProxy, "", "/etc/nginx/nginx.conf"),
+			expectedError: true,
+		},
+		{
+			name:          "Nginx config file given for the detection",
+			input:         newDefaultNginxFor(LogFormatAuto, "", "/etc/nginx/nginx.conf"),
+			expectedError: false,
+		},
+		{
+			name:          "Detection lines too small",
+			input:         newDefaultDetectFormat(LogFormatAuto, 0),
+			expectedError: true,
+		},
+		{
+			name:          "Detection lines are ignored with a format",
+			input:         newDefaultDetectFormat(LogFormatCLF, 0),
+			expectedError: false,
+		},
+		{
+			name:          "Section depth is negative",
+			input:         newDefaultSection(-1, nil, nil),
+			expectedError: true,
+		},
+		{
+			name:          "Section routes and rules",
+			input:         newDefaultSection(0, []string{"/users/:id"}, []string{"/[0-9]+(/|$)=/:id$1"}),
+			expectedError: false,
+		},
+		{
+			name:          "Relative route",
+			input:         newDefaultSection(1, []string{"users/:id"}, nil),
+			expectedError: true,
+		},
+		{
+			name:          "Section rule without replacement",
+			input:         newDefaultSection(1, nil, []string{"/[0-9]+"}),
+			expectedError: true,
+		},
+		{
+			name:          "Wrong section rule regexp",
+			input:         newDefaultSection(1, nil, []string{"/[0-9+=/:id"}),
+			expectedError: true,
+		},
+		{
+			name:          "Unknown log format",
+			input:         newDefaultFormat("xml", ""),
+			expectedError: true,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -75,7 +328,13 @@ func TestValidateConfig(t *testing.T) {
 
 func newDefaultLog(log string) *Config {
 	cfg := NewDefault()
-	cfg.LogFilePath = log
+	cfg.LogFilePaths = []string{log}
+	return cfg
+}
+
+func newDefaultLogs(logs ...string) *Config {
+	cfg := NewDefault()
+	cfg.LogFilePaths = logs
 	return cfg
 }
 
@@ -98,6 +357,13 @@ func newDefaultPollSum(poll, sum int) *Config {
 	return cfg
 }
 
+func newDefaultAnalyzeSum(sum int) *Config {
+	cfg := NewDefault()
+	cfg.Analyze = true
+	cfg.SummaryIntervalSec = sum
+	return cfg
+}
+
 func newDefaultWin(win int) *Config {
 	cfg := NewDefault()
 	cfg.MonitorWindowSec = win
@@ -122,3 +388,237 @@ func newDefaultTop(t int) *Config {
 	cfg.TopSectionNum = t
 	return cfg
 }
+
+func newDefaultTopCapacity(t, capacity int) *Config {
+	cfg := NewDefault()
+	cfg.TopSectionNum = t
+	cfg.TopCapacity = capacity
+	return cfg
+}
+
+func newDefaultTrend(n int) *Config {
+	cfg := NewDefault()
+	cfg.TrendIntervals = n
+	return cfg
+}
+
+func newDefaultWindows(w ...string) *Config {
+	cfg := NewDefault()
+	cfg.SummaryWindows = w
+	return cfg
+}
+
+func newDefaultRules(rules ...Rule) *Config {
+	cfg := NewDefault()
+	cfg.Rules = rules
+	return cfg
+}
+
+func newDefaultRatios(errors, serverErrors float64, minHits int) *Config {
+	cfg := NewDefault()
+	cfg.ErrorRatioThreshold = errors
+	cfg.ServerErrorRatioThreshold = serverErrors
+	cfg.MinHits = minHits
+	return cfg
+}
+
+func newDefaultLateness(l int) *Config {
+	cfg := NewDefault()
+	cfg.EventTime = true
+	cfg.LatenessSec = l
+	return cfg
+}
+
+func newDefaultBandwidth(b int) *Config {
+	cfg := NewDefault()
+	cfg.BandwidthThreshold = b
+	return cfg
+}
+
+func newDefaultUniqueJump(j float64) *Config {
+	cfg := NewDefault()
+	cfg.UniqueJump = j
+	return cfg
+}
+
+func newDefaultCheckpoint(path string, interval int) *Config {
+	cfg := NewDefault()
+	cfg.CheckpointPath = path
+	cfg.CheckpointIntervalSec = interval
+	return cfg
+}
+
+func newDefaultNginx(format, conf string) *Config {
+	return newDefaultNginxFor(LogFormatNginx, format, conf)
+}
+
+func newDefaultNginxFor(logFormat, format, conf string) *Config {
+	cfg := NewDefault()
+	cfg.LogFormat = logFormat
+	cfg.NginxLogFormat = format
+	cfg.NginxConfPath = conf
+	return cfg
+}
+
+func newDefaultFormat(format, apache string) *Config {
+	cfg := NewDefault()
+	cfg.LogFormat = format
+	cfg.ApacheLogFormat = apache
+	return cfg
+}
+
+func newDefaultDetectFormat(format string, n int) *Config {
+	cfg := NewDefault()
+	cfg.LogFormat = format
+	cfg.DetectLines = n
+	return cfg
+}
+
+func newDefaultSection(depth int, routes, rules []string) *Config {
+	cfg := NewDefault()
+	cfg.SectionDepth = depth
+	cfg.SectionRoutes = routes
+	cfg.SectionRules = rules
+	return cfg
+}
+
+func TestPathList(t *testing.T) {
+	l := newPathList("/tmp/access.log")
+	if l.String() != "/tmp/access.log" {
+		t.Fatalf("Expected default path, got %q", l.String())
+	}
+
+	l.Set("/var/log/a.log")
+	l.Set("/var/log/*.log")
+	expected := "/var/log/a.log,/var/log/*.log"
+	if l.String() != expected {
+		t.Fatalf("Expected paths %q, got %q", expected, l.String())
+	}
+}
+
+func TestSpeedValue(t *testing.T) {
+	testCases := []struct {
+		input       string
+		expected    float64
+		expectedErr bool
+	}{
+		{input: "1", expected: 1},
+		{input: "10x", expected: 10},
+		{input: "0.5", expected: 0.5},
+		{input: "max", expected: 0},
+		{input: "0", expectedErr: true},
+		{input: "-2", expectedErr: true},
+		{input: "fast", expectedErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			v := speedValue(1)
+			err := v.Set(tc.input)
+			if err != nil {
+				if !tc.expectedErr {
+					t.Errorf("Test case %q got not expected error", tc.input)
+				}
+				return
+			}
+			if tc.expectedErr {
+				t.Errorf("Test case %q got no error while one is expected", tc.input)
+				return
+			}
+			if float64(v) != tc.expected {
+				t.Errorf("Test case %q: expected %v, got %v", tc.input, tc.expected, float64(v))
+			}
+		})
+	}
+}
+
+func TestSizeValue(t *testing.T) {
+	testCases := []struct {
+		input       string
+		expected    int
+		expectedErr bool
+	}{
+		{input: "0", expected: 0},
+		{input: "512", expected: 512},
+		{input: "100K", expected: 100 * 1024},
+		{input: "10m", expected: 10 * 1024 * 1024},
+		{input: "1G", expected: 1024 * 1024 * 1024},
+		{input: "-1K", expectedErr: true},
+		{input: "1.5M", expectedErr: true},
+		{input: "M", expectedErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			var v sizeValue
+			err := v.Set(tc.input)
+			if err != nil {
+				if !tc.expectedErr {
+					t.Errorf("Test case %q got not expected error", tc.input)
+				}
+				return
+			}
+			if tc.expectedErr {
+				t.Errorf("Test case %q got no error while one is expected", tc.input)
+				return
+			}
+			if int(v) != tc.expected {
+				t.Errorf("Test case %q: expected %v, got %v", tc.input, tc.expected, int(v))
+			}
+		})
+	}
+}
+
+func TestLoadRules(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rules")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "rules.json")
+	data := `[
+	{"name": "api_traffic", "metric": "section_hits", "section": "/api", "window": 60, "op": ">", "threshold": 50},
+	{"name": "slow", "metric": "latency_p99", "op": ">=", "threshold": 500}
+]`
+	if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatalf("Failed to write rules file: %s", err)
+	}
+
+	rules, err := LoadRules(path)
+	if err != nil {
+		t.Fatalf("Failed to load rules: %s", err)
+	}
+	expected := []Rule{
+		{Name: "api_traffic", Metric: RuleMetricSectionHits, Section: "/api", WindowSec: 60, Op: ">", Threshold: 50},
+		{Name: "slow", Metric: RuleMetricLatencyP99, Op: ">=", Threshold: 500},
+	}
+	if !reflect.DeepEqual(expected, rules) {
+		t.Fatalf("Expected rules %+v, got %+v", expected, rules)
+	}
+
+	t.Log("Checking the rules given by the flags come first with the monitoring window")
+	cfg := NewDefault()
+	cfg.BandwidthThreshold = 1024
+	cfg.Rules = rules
+	all := cfg.AlertRules()
+	if len(all) != 4 || all[0].Name != HighTrafficRule || all[1].Name != HighBandwidthRule || all[3].WindowSec != cfg.MonitorWindowSec {
+		t.Fatalf("Unexpected alert rules %+v", all)
+	}
+
+	t.Log("Checking the wrong rules file")
+	if err := ioutil.WriteFile(path, []byte(`{"name": "slow"}`), 0644); err != nil {
+		t.Fatalf("Failed to write rules file: %s", err)
+	}
+	if _, err := LoadRules(path); err == nil {
+		t.Fatal("Expected error for the rules file not holding an array")
+	}
+
+	t.Log("Checking the misspelled rule field")
+	if err := ioutil.WriteFile(path, []byte(`[{"name": "slow", "metric": "latency_p99", "op": ">=", "treshold": 500}]`), 0644); err != nil {
+		t.Fatalf("Failed to write rules file: %s", err)
+	}
+	if _, err := LoadRules(path); err == nil {
+		t.Fatal("Expected error for the unknown rule field")
+	}
+}
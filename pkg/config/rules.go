@@ -0,0 +1,166 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// metrics of the alert rules
+const (
+	// RuleMetricHits is the traffic (hits per second)
+	RuleMetricHits = "hits"
+	// RuleMetricErrors is the client and server errors (4xx and 5xx) per second
+	RuleMetricErrors = "errors"
+	// RuleMetricErrorRatio is the ratio of the client and server errors (4xx and 5xx) to the hits, from 0 to 1
+	RuleMetricErrorRatio = "error_ratio"
+	// RuleMetricServerErrorRatio is the ratio of the server errors (5xx) to the hits, from 0 to 1
+	RuleMetricServerErrorRatio = "5xx_ratio"
+	// RuleMetricBytes is the bandwidth (bytes sent per second)
+	RuleMetricBytes = "bytes"
+	// RuleMetricLatencyP99 is the 99th percentile of the latency (milliseconds)
+	RuleMetricLatencyP99 = "latency_p99"
+	// RuleMetricSectionHits is the traffic of the section of the rule (hits per second)
+	RuleMetricSectionHits = "section_hits"
+)
+
+// names of the rules given by the flags
+const (
+	// HighTrafficRule is the rule of the alerting threshold (-t)
+	HighTrafficRule = "high_traffic"
+	// HighBandwidthRule is the rule of the bandwidth alerting threshold (-bt)
+	HighBandwidthRule = "high_bandwidth"
+	// HighErrorRatioRule is the rule of the error ratio alerting threshold (-er)
+	HighErrorRatioRule = "high_error_ratio"
+	// HighServerErrorRatioRule is the rule of the 5xx ratio alerting threshold (-sr)
+	HighServerErrorRatioRule = "high_5xx_ratio"
+)
+
+// Rule is an alert rule: the alert is triggered once the metric aggregated over the window
+// is compared successfully to the threshold and cleared once it's not anymore
+type Rule struct {
+	Name string `json:"name"`
+	// Metric is hits, errors, error_ratio, 5xx_ratio, bytes, latency_p99 or section_hits
+	Metric string `json:"metric"`
+	// Section is the section of the section_hits metric
+	Section string `json:"section,omitempty"`
+	// WindowSec is the aggregation window (seconds), the monitoring window if not set
+	WindowSec int `json:"window,omitempty"`
+	// Op compares the metric to the threshold: >, >=, < or <=
+	Op        string  `json:"op"`
+	Threshold float64 `json:"threshold"`
+	// MinHits is the minimum volume (hits of the window) for the alert of the rule to be triggered,
+	// only for the metrics of the log entries' stats (errors, ratios, latency and section hits)
+	MinHits int `json:"min_hits,omitempty"`
+}
+
+// LoadRules reads the alert rules from the given json file (array of rules)
+// the unknown fields of the rules (misspelled ones) are rejected
+// no rules are returned if no file is given
+func LoadRules(path string) ([]Rule, error) {
+	if len(path) == 0 {
+		return nil, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	rules := []Rule{}
+	if err := dec.Decode(&rules); err != nil {
+		return nil, fmt.Errorf("wrong rules file %s: %s", path, err)
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("wrong rules file %s: data after the array of rules", path)
+	}
+	return rules, nil
+}
+
+// AlertRules returns all the alert rules: the ones given by the flags followed by the ones of the rules file
+// the rules with no window are given the monitoring window
+func (c *Config) AlertRules() []Rule {
+	rules := []Rule{{Name: HighTrafficRule, Metric: RuleMetricHits, Op: ">=", Threshold: float64(c.AlertThreshold)}}
+	if c.BandwidthThreshold > 0 {
+		rules = append(rules, Rule{Name: HighBandwidthRule, Metric: RuleMetricBytes, Op: ">=", Threshold: float64(c.BandwidthThreshold)})
+	}
+	if c.ErrorRatioThreshold > 0 {
+		rules = append(rules, Rule{Name: HighErrorRatioRule, Metric: RuleMetricErrorRatio, Op: ">=", Threshold: c.ErrorRatioThreshold, MinHits: c.MinHits})
+	}
+	if c.ServerErrorRatioThreshold > 0 {
+		rules = append(rules, Rule{Name: HighServerErrorRatioRule, Metric: RuleMetricServerErrorRatio, Op: ">=", Threshold: c.ServerErrorRatioThreshold, MinHits: c.MinHits})
+	}
+	rules = append(rules, c.Rules...)
+	for i := range rules {
+		if rules[i].WindowSec == 0 {
+			rules[i].WindowSec = c.MonitorWindowSec
+		}
+	}
+	return rules
+}
+
+// HasRuleMetric returns true if one of the alert rules is evaluated on one of the given metrics
+func (c *Config) HasRuleMetric(metrics ...string) bool {
+	for _, r := range c.AlertRules() {
+		for _, m := range metrics {
+			if r.Metric == m {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// validateRules validates the alert rules, their names must be unique
+func (c *Config) validateRules() error {
+	names := map[string]bool{}
+	for _, r := range c.AlertRules() {
+		if len(strings.TrimSpace(r.Name)) == 0 {
+			return errors.New("no alert rule name provided")
+		}
+		if names[r.Name] {
+			return fmt.Errorf("alert rule %q defined more than once", r.Name)
+		}
+		names[r.Name] = true
+
+		switch r.Metric {
+		case RuleMetricHits, RuleMetricErrors, RuleMetricBytes, RuleMetricLatencyP99:
+		case RuleMetricErrorRatio, RuleMetricServerErrorRatio:
+			if r.Threshold > 1 {
+				return fmt.Errorf("alert rule %q: ratio threshold cannot be greater than 1", r.Name)
+			}
+		case RuleMetricSectionHits:
+			if !strings.HasPrefix(r.Section, "/") {
+				return fmt.Errorf("alert rule %q: section must start with /", r.Name)
+			}
+		default:
+			return fmt.Errorf("alert rule %q: unknown metric %q", r.Name, r.Metric)
+		}
+		if r.Metric != RuleMetricSectionHits && len(r.Section) != 0 {
+			return fmt.Errorf("alert rule %q: section is only given to the %s metric", r.Name, RuleMetricSectionHits)
+		}
+
+		switch r.Op {
+		case ">", ">=", "<", "<=":
+		default:
+			return fmt.Errorf("alert rule %q: unknown comparison %q", r.Name, r.Op)
+		}
+		if r.Threshold < 0 {
+			return fmt.Errorf("alert rule %q: threshold cannot be negative", r.Name)
+		}
+		if r.MinHits < 0 {
+			return fmt.Errorf("alert rule %q: minimum hits cannot be negative", r.Name)
+		}
+		if r.MinHits > 0 && (r.Metric == RuleMetricHits || r.Metric == RuleMetricBytes) {
+			return fmt.Errorf("alert rule %q: minimum hits are not given to the %s metric", r.Name, r.Metric)
+		}
+		if r.WindowSec <= 0 || r.WindowSec%c.PollIntervalSec != 0 {
+			return fmt.Errorf("alert rule %q: window must be a multiple of the polling interval", r.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,11 @@
+// Package entry provides the raw log entries passed from the readers to the collector
+package entry
+
+// LogEntry represents a raw log entry together with its source
+type LogEntry struct {
+	// Source is the path of the file the entry was read from
+	Source string
+	Line   string
+	// Sync is closed by the collector once all the log entries sent before are collected, no line is given then
+	Sync chan struct{}
+}
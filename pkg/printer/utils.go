@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"strings"
 	"time"
+	"unicode/utf8"
 )
 
 const (
@@ -57,25 +58,33 @@ func (m ErrorMessage) Verbose() bool {
 	return false
 }
 
-// AlertMessage represents the high traffic alert messsage
+// AlertMessage represents the alert message of an alert rule
 type AlertMessage struct {
-	Hits int
-	Time time.Time
+	// Rule is the name of the rule, Title describes its alert (High traffic, High 5xx ratio, ...)
+	Rule  string
+	Title string
+	// Metric is the name of the rule metric, Value its formatted value
+	Metric string
+	Value  string
+	Time   time.Time
 }
 
 // NewAlertMessage gives a new instance of the alert message
-// with given number of hits and the time at which it was triggered
-func NewAlertMessage(h int, t time.Time) AlertMessage {
+// of the given rule with its metric value and the time at which it was triggered
+func NewAlertMessage(rule, title, metric, value string, t time.Time) AlertMessage {
 	return AlertMessage{
-		Hits: h,
-		Time: t,
+		Rule:   rule,
+		Title:  title,
+		Metric: metric,
+		Value:  value,
+		Time:   t,
 	}
 }
 
-// Format returns the predefined alert text for the high traffic
+// Format returns the alert text of the rule
 // wrapped into ALERT label
 func (m AlertMessage) Format() string {
-	return wrapAlert(fmt.Sprintf("High traffic generated an alert - hits = %d, triggered at %s", m.Hits, m.Time.Format(timeFormat)))
+	return wrapAlert(fmt.Sprintf("%s: %s generated an alert - %s = %s, triggered at %s", m.Rule, m.Title, m.Metric, m.Value, m.Time.Format(timeFormat)))
 }
 
 // Verbose returns false as the alert message is to be always displayed
@@ -83,21 +92,21 @@ func (m AlertMessage) Verbose() bool {
 	return false
 }
 
-// ClearAlertMessage represents the clearance message for a previously generated high traffic alert
+// ClearAlertMessage represents the clearance message for a previously generated alert of a rule
 type ClearAlertMessage struct {
 	AlertMessage
 }
 
 // NewClearAlertMessage gives a new instance of the clearance message,
 // just like the alert message it expects the same inputs
-func NewClearAlertMessage(h int, t time.Time) ClearAlertMessage {
-	return ClearAlertMessage{NewAlertMessage(h, t)}
+func NewClearAlertMessage(rule, title, metric, value string, t time.Time) ClearAlertMessage {
+	return ClearAlertMessage{NewAlertMessage(rule, title, metric, value, t)}
 }
 
-// Format returns the predefined clearance text for the previously generated alert
+// Format returns the clearance text for the previously generated alert of the rule
 // wrapped into CLEAR label
 func (m ClearAlertMessage) Format() string {
-	return wrapClearAlert(fmt.Sprintf("High traffic alert cleared at %s. Current hits = %d", m.Time.Format(timeFormat), m.Hits))
+	return wrapClearAlert(fmt.Sprintf("%s: %s alert cleared at %s. Current %s = %s", m.Rule, m.Title, m.Time.Format(timeFormat), m.Metric, m.Value))
 }
 
 // Verbose returns false as the clearance message is to be always displayed
@@ -105,6 +114,66 @@ func (m ClearAlertMessage) Verbose() bool {
 	return false
 }
 
+// UniqueAlertMessage represents the unique clients jump alert message
+type UniqueAlertMessage struct {
+	// Unique is the number of the unique clients of the summary interval,
+	// Average the average of the previous intervals of the monitoring window
+	Unique  int
+	Average int
+	Time    time.Time
+}
+
+// NewUniqueAlertMessage gives a new instance of the unique clients alert message
+// with given unique clients, their average and the time at which it was triggered
+func NewUniqueAlertMessage(u, avg int, t time.Time) UniqueAlertMessage {
+	return UniqueAlertMessage{
+		Unique:  u,
+		Average: avg,
+		Time:    t,
+	}
+}
+
+// jump returns the ratio of the unique clients to their average
+func (m UniqueAlertMessage) jump() float64 {
+	if m.Average == 0 {
+		return 0
+	}
+	return float64(m.Unique) / float64(m.Average)
+}
+
+// Format returns the predefined alert text for the unique clients jump
+// wrapped into ALERT label
+func (m UniqueAlertMessage) Format() string {
+	return wrapAlert(fmt.Sprintf("Unique clients jumped %.1fx generated an alert - unique clients = %d (average %d), triggered at %s", m.jump(), m.Unique, m.Average, m.Time.Format(timeFormat)))
+}
+
+// Verbose returns false as the alert message is to be always displayed
+func (m UniqueAlertMessage) Verbose() bool {
+	return false
+}
+
+// ClearUniqueAlertMessage represents the clearance message for a previously generated unique clients alert
+type ClearUniqueAlertMessage struct {
+	UniqueAlertMessage
+}
+
+// NewClearUniqueAlertMessage gives a new instance of the unique clients clearance message,
+// just like the alert message it expects the same inputs
+func NewClearUniqueAlertMessage(u, avg int, t time.Time) ClearUniqueAlertMessage {
+	return ClearUniqueAlertMessage{NewUniqueAlertMessage(u, avg, t)}
+}
+
+// Format returns the predefined clearance text for the previously generated unique clients alert
+// wrapped into CLEAR label
+func (m ClearUniqueAlertMessage) Format() string {
+	return wrapClearAlert(fmt.Sprintf("Unique clients alert cleared at %s. Current unique clients = %d (average %d)", m.Time.Format(timeFormat), m.Unique, m.Average))
+}
+
+// Verbose returns false as the clearance message is to be always displayed
+func (m ClearUniqueAlertMessage) Verbose() bool {
+	return false
+}
+
 // InfoMessage represents an information message
 type InfoMessage struct {
 	Message
@@ -144,7 +213,9 @@ type Table2dMessage struct {
 func NewTable2dMessage(n string, t1, t2 string) *Table2dMessage {
 	tbl := &Table2dMessage{Name: n, colSep: "    "}
 	tbl.Titles = [2]string{t1, t2}
-	tbl.max = [2]int{len(t1), len(t2)}
+	tbl.max = [2]int{width(t1), width(t2)}
+	// the name must fit into the table (with at least one filler on each side)
+	tbl.Enlarge(width(n) + 2)
 	return tbl
 }
 
@@ -152,11 +223,11 @@ func NewTable2dMessage(n string, t1, t2 string) *Table2dMessage {
 func (t *Table2dMessage) AddRow(k, v string) {
 	t.Rows = append(t.Rows, strPair{Key: k, Value: v})
 	// calculate maxes
-	if len(k) > t.max[0] {
-		t.max[0] = len(k)
+	if width(k) > t.max[0] {
+		t.max[0] = width(k)
 	}
-	if len(v) > t.max[1] {
-		t.max[1] = len(v)
+	if width(v) > t.max[1] {
+		t.max[1] = width(v)
 	}
 }
 
@@ -185,8 +256,9 @@ func (t *Table2dMessage) Enlarge(newLen int) {
 		// keep the max of right and adjust the left
 		t.max[0] = allSize - t.max[1]
 	} else {
+		// the right column gets the remainder of the odd sizes
 		t.max[0] = colSize
-		t.max[1] = colSize
+		t.max[1] = allSize - colSize
 	}
 }
 
@@ -228,11 +300,33 @@ func (t *Table2dMessage) Format() string {
 	return b.String()
 }
 
+// byteUnits are the units of the formatted byte counts (powers of 1024)
+var byteUnits = []string{"B", "KB", "MB", "GB", "TB", "PB"}
+
+// FormatBytes formats the given byte count in the largest unit keeping it above 1 (1.5 KB, 12.3 MB, 512 B)
+func FormatBytes(n int) string {
+	v := float64(n)
+	u := 0
+	for v >= 1024 && u < len(byteUnits)-1 {
+		v /= 1024
+		u++
+	}
+	if u == 0 {
+		return fmt.Sprintf("%d B", n)
+	}
+	return fmt.Sprintf("%.1f %s", v, byteUnits[u])
+}
+
+// width returns the number of the characters of the given string (the arrows, ... take more than one byte)
+func width(str string) int {
+	return utf8.RuneCountInString(str)
+}
+
 // pad from left and right putting the given string to the center
 func center(str, filler string, max int) string {
-	padLeft := (max - len(str)) / 2
+	padLeft := (max - width(str)) / 2
 	padRight := padLeft
-	if (max-len(str))%2 != 0 {
+	if (max-width(str))%2 != 0 {
 		padRight++
 	}
 	b := strings.Builder{}
@@ -244,7 +338,7 @@ func center(str, filler string, max int) string {
 
 // pad right up to the given max
 func padRight(str string, max int) string {
-	pad := max - len(str)
+	pad := max - width(str)
 	b := strings.Builder{}
 	b.WriteString(str)
 	b.WriteString(strings.Repeat(" ", pad))
@@ -253,7 +347,7 @@ func padRight(str string, max int) string {
 
 // pad left up to the given max
 func padLeft(str string, max int) string {
-	pad := max - len(str)
+	pad := max - width(str)
 	b := strings.Builder{}
 	b.WriteString(strings.Repeat(" ", pad))
 	b.WriteString(str)
@@ -52,3 +52,43 @@ veeeeeeeery looooooooong liiiiiiiineeee                        here is other tex
 		t.Fatalf("Expected table output %s, got %s", expectedEnlarge, gotEnlarge)
 	}
 }
+
+func TestTable2dMessageWideCharacters(t *testing.T) {
+	tbl := NewTable2dMessage("TREND", "Section", "Hits")
+	tbl.AddRow("/api", "15 ↑ +5")
+	tbl.AddRow("/static", "3 ↓ -1")
+
+	// the arrows take one column
+	expectedOutput := `
+------TREND-------
+Section     Hits  
+-------    -------
+/api       15 ↑ +5
+/static     3 ↓ -1
+`
+	gotOutput := tbl.Format()
+	if expectedOutput != gotOutput {
+		t.Fatalf("Expected table output %s, got %s", expectedOutput, gotOutput)
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	testCases := []struct {
+		input    int
+		expected string
+	}{
+		{input: 0, expected: "0 B"},
+		{input: 1023, expected: "1023 B"},
+		{input: 1536, expected: "1.5 KB"},
+		{input: 12*1024*1024 + 300*1024, expected: "12.3 MB"},
+		{input: 3 * 1024 * 1024 * 1024, expected: "3.0 GB"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.expected, func(t *testing.T) {
+			if got := FormatBytes(tc.input); got != tc.expected {
+				t.Errorf("Expected %q, got %q", tc.expected, got)
+			}
+		})
+	}
+}
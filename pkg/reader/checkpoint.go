@@ -0,0 +1,63 @@
+package reader
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// checkpoint represents the position in a tailed file saved between the runs
+type checkpoint struct {
+	Path  string `json:"path"`
+	Inode uint64 `json:"inode"`
+	// Offset is the number of bytes already sent as log entries
+	Offset int64 `json:"offset"`
+}
+
+// loadCheckpoints reads the checkpoints from the given file
+// returns no checkpoints if the file doesn't exist yet
+func loadCheckpoints(path string) (map[string]checkpoint, error) {
+	cps := map[string]checkpoint{}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return cps, nil
+		}
+		return nil, err
+	}
+
+	list := []checkpoint{}
+	if err := json.Unmarshal(b, &list); err != nil {
+		return nil, err
+	}
+	for _, cp := range list {
+		cps[cp.Path] = cp
+	}
+	return cps, nil
+}
+
+// saveCheckpoints writes the given checkpoints to the given file
+// the file is replaced atomically to not leave it half written in case of a crash
+func saveCheckpoints(path string, cps []checkpoint) error {
+	b, err := json.MarshalIndent(cps, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,150 @@
+package reader
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"httplogmonitor/pkg/config"
+	"httplogmonitor/pkg/entry"
+	"httplogmonitor/pkg/printer"
+)
+
+func TestCheckpointsSaveLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "test")
+	if err != nil {
+		t.Skip("Failed to create the test directory: ", err)
+	}
+	defer os.RemoveAll(dir)
+	path := dir + "/checkpoints.json"
+
+	t.Log("Loading absent checkpoints")
+	cps, err := loadCheckpoints(path)
+	if err != nil {
+		t.Fatal("Failed to load absent checkpoints: ", err)
+	}
+	if len(cps) != 0 {
+		t.Fatalf("Expected no checkpoints, got %v", cps)
+	}
+
+	t.Log("Saving checkpoints")
+	saved := []checkpoint{
+		{Path: "/var/log/a.log", Inode: 12, Offset: 1024},
+		{Path: "/var/log/b.log", Inode: 34, Offset: 0},
+	}
+	if err := saveCheckpoints(path, saved); err != nil {
+		t.Fatal("Failed to save checkpoints: ", err)
+	}
+
+	cps, err = loadCheckpoints(path)
+	if err != nil {
+		t.Fatal("Failed to load checkpoints: ", err)
+	}
+	expected := map[string]checkpoint{
+		"/var/log/a.log": saved[0],
+		"/var/log/b.log": saved[1],
+	}
+	if !reflect.DeepEqual(expected, cps) {
+		t.Fatalf("Expected checkpoints %v, got %v", expected, cps)
+	}
+}
+
+func TestReaderResume(t *testing.T) {
+	dir, err := ioutil.TempDir("", "test")
+	if err != nil {
+		t.Skip("Failed to create the test directory: ", err)
+	}
+	defer os.RemoveAll(dir)
+	path := dir + "/access.log"
+
+	cfg := config.NewDefault()
+	cfg.LogFilePaths = []string{path}
+	cfg.CheckpointPath = dir + "/checkpoints.json"
+	printCh := make(chan printer.Formatter, 10)
+
+	t.Log("Reading the file up to the incomplete line")
+	ioutil.WriteFile(path, []byte("before\n"), 0644)
+	r := New(cfg)
+	r.discover(true, printCh)
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	f.WriteString("first\nsec")
+	logCh := make(chan entry.LogEntry, 10)
+	r.poll(r.tailers[path], logCh, printCh)
+	r.saveCheckpoints(printCh)
+	r.close()
+
+	t.Log("Writing while stopped")
+	f.WriteString("ond\nthird\n")
+	f.Close()
+
+	t.Log("Resuming from the checkpoint")
+	r = New(cfg)
+	r.checkpoints, err = loadCheckpoints(cfg.CheckpointPath)
+	if err != nil {
+		t.Fatal("Failed to load checkpoints: ", err)
+	}
+	r.discover(true, printCh)
+	defer r.close()
+	r.poll(r.tailers[path], logCh, printCh)
+	close(logCh)
+
+	outputs := []string{}
+	for e := range logCh {
+		outputs = append(outputs, e.Line)
+	}
+	expected := []string{"first", "second", "third"}
+	if !reflect.DeepEqual(expected, outputs) {
+		t.Fatalf("Expected outputs %q, got %q", expected, outputs)
+	}
+}
+
+func TestReaderResumeRotated(t *testing.T) {
+	dir, err := ioutil.TempDir("", "test")
+	if err != nil {
+		t.Skip("Failed to create the test directory: ", err)
+	}
+	defer os.RemoveAll(dir)
+	path := dir + "/access.log"
+
+	cfg := config.NewDefault()
+	cfg.LogFilePaths = []string{path}
+	cfg.CheckpointPath = dir + "/checkpoints.json"
+	printCh := make(chan printer.Formatter, 10)
+
+	t.Log("Saving the checkpoint at the end of the file")
+	ioutil.WriteFile(path, []byte("before\nrotated\n"), 0644)
+	r := New(cfg)
+	r.discover(true, printCh)
+	if fileInode(r.tailers[path].info) == 0 {
+		r.close()
+		t.Skip("Inode numbers are not available on this platform")
+	}
+	r.saveCheckpoints(printCh)
+	r.close()
+
+	t.Log("Rotating the file while stopped")
+	os.Rename(path, path+".1")
+	ioutil.WriteFile(path, []byte("first\nsecond\n"), 0644)
+
+	t.Log("Resuming from the checkpoint")
+	r = New(cfg)
+	r.checkpoints, err = loadCheckpoints(cfg.CheckpointPath)
+	if err != nil {
+		t.Fatal("Failed to load checkpoints: ", err)
+	}
+	r.discover(true, printCh)
+	defer r.close()
+	logCh := make(chan entry.LogEntry, 10)
+	r.poll(r.tailers[path], logCh, printCh)
+	close(logCh)
+
+	outputs := []string{}
+	for e := range logCh {
+		outputs = append(outputs, e.Line)
+	}
+	expected := []string{"first", "second"}
+	if !reflect.DeepEqual(expected, outputs) {
+		t.Fatalf("Expected outputs %q, got %q", expected, outputs)
+	}
+}
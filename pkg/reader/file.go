@@ -0,0 +1,72 @@
+package reader
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// MaxLineSize is the maximum length of a log entry read from a complete log file
+const MaxLineSize = 1024 * 1024
+
+// gzip magic number
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// logFile is a complete log file decompressed on the fly if needed
+type logFile struct {
+	io.Reader
+	file *os.File
+	gz   *gzip.Reader
+}
+
+// OpenFile opens the complete (not tailed) log file from the given path
+// gzipped files (rotated archives) are detected by their magic number and decompressed on the fly
+func OpenFile(path string) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	br := bufio.NewReader(f)
+	lf := &logFile{Reader: br, file: f}
+	if magic, err := br.Peek(len(gzipMagic)); err == nil && string(magic) == string(gzipMagic) {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		lf.Reader = gz
+		lf.gz = gz
+	}
+	return lf, nil
+}
+
+// Close closes the decompressor if any and the file
+func (f *logFile) Close() error {
+	if f.gz != nil {
+		f.gz.Close()
+	}
+	return f.file.Close()
+}
+
+// Glob returns the sorted paths of all the files matching the given patterns
+// a file matching several patterns is returned only once
+// the patterns are expected to be validated (by the configuration)
+func Glob(patterns []string) []string {
+	paths := []string{}
+	seen := map[string]bool{}
+	for _, p := range patterns {
+		m, _ := filepath.Glob(p)
+		for _, path := range m {
+			if !seen[path] {
+				seen[path] = true
+				paths = append(paths, path)
+			}
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
@@ -0,0 +1,13 @@
+//go:build windows || plan9
+// +build windows plan9
+
+package reader
+
+import (
+	"os"
+)
+
+// fileInode returns 0 as the inode number is not available on this platform
+func fileInode(fi os.FileInfo) uint64 {
+	return 0
+}
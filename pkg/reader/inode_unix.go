@@ -0,0 +1,19 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package reader
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileInode returns the inode number of the given file
+// returns 0 if it's not available
+func fileInode(fi os.FileInfo) uint64 {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0
+	}
+	return uint64(st.Ino)
+}
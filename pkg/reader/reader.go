@@ -1,74 +1,264 @@
 package reader
 
 import (
-	"bufio"
 	"context"
 	"fmt"
-	"io"
 	"os"
-	"strings"
+	"sort"
 	"sync"
 	"time"
 
 	alert "httplogmonitor/pkg/alertmanager"
 	"httplogmonitor/pkg/config"
+	"httplogmonitor/pkg/entry"
 	"httplogmonitor/pkg/printer"
 )
 
-// Reader implement a tailer (tail -f) on a given file
+// Reader implement a tailer (tail -f) on all the files matching the given paths
 type Reader struct {
 	config *config.Config
+	// tailers by the path of the tailed file
+	tailers map[string]*tailer
+	// rotated files which are not to be tailed again even if they match the given paths
+	retired []os.FileInfo
+	watcher watcher
+	// positions saved by the previous run by the path of the tailed file
+	checkpoints map[string]checkpoint
 }
 
 // New returns an instance of Reader
 func New(cfg *config.Config) *Reader {
 	return &Reader{
-		config: cfg,
+		config:  cfg,
+		tailers: map[string]*tailer{},
 	}
 }
 
-// Start sends raw log entries to logCh, counter metric to metCh and errors to printCh
-// gracefully stops closing the log file passed through the configuration
-func (r *Reader) Start(ctx context.Context, logCh chan<- string, metCh chan<- alert.Metric, printCh chan<- printer.Formatter, wg *sync.WaitGroup) {
+// Start sends raw log entries labeled with their source to logCh, counter metric to metCh and errors to printCh
+// the files matching the configured paths are looked for at every polling tick,
+// the ones found at start are read from the end (or from the checkpoint if any), the ones appeared later - from the beginning
+// follows the log files through the rotations informing the printer about each of them
+// the log files are read on every change if the inotify watching is on and at every polling tick otherwise,
+// in both cases the counter metric of all the files is sent at every polling tick (unless the event time mode is on)
+// the positions in the files are saved to the checkpoint file periodically and on stop if it's configured
+// gracefully stops closing the log files
+func (r *Reader) Start(ctx context.Context, logCh chan<- entry.LogEntry, metCh chan<- alert.Metric, printCh chan<- printer.Formatter, wg *sync.WaitGroup) {
 	defer wg.Done()
 
-	f, err := os.Open(r.config.LogFilePath)
-	if err != nil {
-		panic(err)
+	if r.config.Inotify {
+		w, err := newWatcher()
+		if err != nil {
+			printCh <- printer.NewInfoMessage(fmt.Sprintf("File watching is not available (%s), falling back to polling", err))
+		} else {
+			r.watcher = w
+			defer w.Close()
+		}
+	}
+	// nil channel blocks forever: polling only
+	var wakeCh <-chan struct{}
+	if r.watcher != nil {
+		wakeCh = r.watcher.Events()
 	}
-	defer f.Close()
 
-	// put the offset to the end of the file
-	_, err = f.Seek(0, 2)
-	if err != nil {
-		panic(err)
+	if len(r.config.CheckpointPath) != 0 {
+		cps, err := loadCheckpoints(r.config.CheckpointPath)
+		if err != nil {
+			printCh <- printer.NewErrorMessage(fmt.Sprintf("Error loading checkpoints: %s", err.Error()))
+		}
+		r.checkpoints = cps
 	}
 
-	reader := bufio.NewReader(f)
+	// put the offset to the end of the files or to the saved position
+	r.discover(true, printCh)
+	if len(r.tailers) == 0 {
+		printCh <- printer.NewInfoMessage(fmt.Sprintf("No log file matches %v yet, waiting for it to appear", r.config.LogFilePaths))
+	}
+	defer r.close()
 
 	tick := time.NewTicker(time.Duration(r.config.PollIntervalSec) * time.Second)
 	defer tick.Stop()
 
+	// nil channel blocks forever: no checkpoints
+	var cpCh <-chan time.Time
+	if len(r.config.CheckpointPath) != 0 {
+		cpTick := time.NewTicker(time.Duration(r.config.CheckpointIntervalSec) * time.Second)
+		defer cpTick.Stop()
+		cpCh = cpTick.C
+		// the last positions are saved on stop
+		defer r.saveCheckpoints(printCh)
+	}
+
+	// number of log entries read for each tick
+	logCnt := 0
+	read := func() {
+		for _, t := range r.tailers {
+			logCnt += r.poll(t, logCh, printCh)
+		}
+	}
+
 loop:
 	for {
 		select {
-		case t := <-tick.C:
-			// number of log entries read for each tick
-			logCnt := 0
-			for {
-				msg, err := reader.ReadBytes('\n')
-				if err != nil {
-					if err != io.EOF {
-						printCh <- printer.NewErrorMessage(fmt.Sprintf("Error reading log file: %s", err.Error()))
-					}
-					break
-				}
-				logCh <- strings.TrimSpace(string(msg))
-				logCnt++
+		case <-wakeCh:
+			read()
+		case tm := <-tick.C:
+			// polling is still done as a fallback for the changes missed by the watcher
+			read()
+			if r.discover(false, printCh) {
+				read()
+			}
+			// in the event time mode the hits are counted by the collector
+			if !r.config.EventTime {
+				metCh <- alert.NewCounterMetric(logCnt, tm)
 			}
-			metCh <- alert.NewCounterMetric(logCnt, t)
+			logCnt = 0
+		case <-cpCh:
+			r.saveCheckpoints(printCh)
 		case <-ctx.Done():
 			break loop
 		}
 	}
 }
+
+// discover starts tailing the files which match the configured paths and are not tailed yet
+// the offset of the new files is put to the end if the tail flag is set,
+// to the checkpoint if any or to the beginning if they were rotated since the checkpoint
+// returns true if any new file was found
+func (r *Reader) discover(tail bool, printCh chan<- printer.Formatter) bool {
+	found := false
+	retired := []os.FileInfo{}
+	for _, p := range Glob(r.config.LogFilePaths) {
+		if _, ok := r.tailers[p]; ok {
+			continue
+		}
+		fi, err := os.Stat(p)
+		if err != nil || fi.IsDir() {
+			continue
+		}
+		if rfi := r.sameFile(fi); rfi != nil {
+			// rotated file still matching the paths
+			retired = append(retired, rfi)
+			continue
+		}
+
+		cp, ok := r.checkpoints[p]
+		ok = ok && tail && cp.Inode != 0
+		resume := ok && cp.Inode == fileInode(fi) && cp.Offset <= fi.Size()
+		// the file was rotated (or truncated) while stopped: the new one is read from the beginning
+		rotated := ok && !resume
+		t, err := newTailer(p, tail && !resume && !rotated)
+		if err == nil && resume {
+			err = t.seek(cp.Offset)
+			if err != nil {
+				t.close()
+			}
+		}
+		if err != nil {
+			printCh <- printer.NewErrorMessage(fmt.Sprintf("Error opening log file: %s", err.Error()))
+			continue
+		}
+		if resume {
+			printCh <- printer.NewInfoMessage(fmt.Sprintf("Log file %s is read from the checkpoint at offset %d", p, cp.Offset))
+		}
+		if rotated {
+			printCh <- printer.NewInfoMessage(fmt.Sprintf("Log file %s was rotated since the checkpoint, reading it from the beginning", p))
+		}
+		r.tailers[p] = t
+		r.watch(t, printCh)
+		found = true
+		if !tail {
+			printCh <- printer.NewInfoMessage(fmt.Sprintf("New log file %s found, reading it from the beginning", p))
+		}
+	}
+	// forget the rotated files which don't match the paths anymore
+	r.retired = retired
+
+	return found
+}
+
+// sameFile returns the info of the tailed or rotated file which is the same as the given one
+// returns nil if there is no such file
+func (r *Reader) sameFile(fi os.FileInfo) os.FileInfo {
+	for _, t := range r.tailers {
+		if os.SameFile(t.info, fi) {
+			return t.info
+		}
+	}
+	for _, rfi := range r.retired {
+		if os.SameFile(rfi, fi) {
+			return rfi
+		}
+	}
+	return nil
+}
+
+// watch starts watching the file of the given tailer if the watcher is available
+func (r *Reader) watch(t *tailer, printCh chan<- printer.Formatter) {
+	if r.watcher == nil {
+		return
+	}
+	if err := r.watcher.Watch(t.path); err != nil {
+		printCh <- printer.NewErrorMessage(fmt.Sprintf("Error watching log file: %s", err.Error()))
+	}
+}
+
+// saveCheckpoints saves the current positions of all the tailed files to the checkpoint file
+func (r *Reader) saveCheckpoints(printCh chan<- printer.Formatter) {
+	cps := make([]checkpoint, 0, len(r.tailers))
+	for _, t := range r.tailers {
+		cps = append(cps, t.checkpoint())
+	}
+	sort.Slice(cps, func(i, j int) bool { return cps[i].Path < cps[j].Path })
+
+	if err := saveCheckpoints(r.config.CheckpointPath, cps); err != nil {
+		printCh <- printer.NewErrorMessage(fmt.Sprintf("Error saving checkpoints: %s", err.Error()))
+	}
+}
+
+// close closes all the tailed files
+func (r *Reader) close() {
+	for _, t := range r.tailers {
+		t.close()
+	}
+}
+
+// poll reads all the new log entries from the tailed file
+// switching to the new file if a rotation is detected
+// returns the number of log entries sent to logCh
+func (r *Reader) poll(t *tailer, logCh chan<- entry.LogEntry, printCh chan<- printer.Formatter) int {
+	send := func(l string) { logCh <- entry.LogEntry{Source: t.path, Line: l} }
+
+	logCnt, err := t.read(send)
+	if err != nil {
+		printCh <- printer.NewErrorMessage(fmt.Sprintf("Error reading log file: %s", err.Error()))
+	}
+
+	rotation, err := t.rotated()
+	if err != nil {
+		printCh <- printer.NewErrorMessage(fmt.Sprintf("Error checking log file rotation: %s", err.Error()))
+		return logCnt
+	}
+	if len(rotation) == 0 {
+		return logCnt
+	}
+
+	old := t.info
+	cnt, err := t.reopen(send)
+	logCnt += cnt
+	if err != nil {
+		printCh <- printer.NewErrorMessage(fmt.Sprintf("Error reopening rotated log file: %s", err.Error()))
+		return logCnt
+	}
+	if !os.SameFile(old, t.info) {
+		r.retired = append(r.retired, old)
+	}
+	printCh <- printer.NewInfoMessage(fmt.Sprintf("Log file %s rotated (%s), reading the new one from the beginning", t.path, rotation))
+	r.watch(t, printCh)
+
+	cnt, err = t.read(send)
+	logCnt += cnt
+	if err != nil {
+		printCh <- printer.NewErrorMessage(fmt.Sprintf("Error reading log file: %s", err.Error()))
+	}
+	return logCnt
+}
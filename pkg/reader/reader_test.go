@@ -11,6 +11,7 @@ import (
 
 	alert "httplogmonitor/pkg/alertmanager"
 	"httplogmonitor/pkg/config"
+	"httplogmonitor/pkg/entry"
 	"httplogmonitor/pkg/printer"
 )
 
@@ -24,9 +25,9 @@ func TestReaderNominal(t *testing.T) {
 		t.Skip("Failed to create the test file: ", err)
 	}
 	defer os.Remove(f.Name())
-	cfg.LogFilePath = f.Name()
+	cfg.LogFilePaths = []string{f.Name()}
 
-	logCh := make(chan string, 3)
+	logCh := make(chan entry.LogEntry, 3)
 	metCh := make(chan alert.Metric)
 	printCh := make(chan printer.Formatter)
 	ctx, cancelCtx := context.WithCancel(context.Background())
@@ -58,7 +59,10 @@ loop:
 	for {
 		select {
 		case l := <-logCh:
-			outputs = append(outputs, l)
+			if l.Source != f.Name() {
+				t.Fatalf("Expected source %q, got %q", f.Name(), l.Source)
+			}
+			outputs = append(outputs, l.Line)
 			if len(outputs) == len(inputs) {
 				break loop
 			}
@@ -101,3 +105,59 @@ loop:
 	// checking for the closure of the file may be a race
 	// so, no testing of the graceful close of the file
 }
+
+func TestReaderDiscover(t *testing.T) {
+	dir, err := ioutil.TempDir("", "test")
+	if err != nil {
+		t.Skip("Failed to create the test directory: ", err)
+	}
+	defer os.RemoveAll(dir)
+
+	cfg := config.NewDefault()
+	cfg.LogFilePaths = []string{dir + "/*.access.log*", dir + "/www.access.log"}
+	r := New(cfg)
+	defer r.close()
+	printCh := make(chan printer.Formatter, 10)
+
+	t.Log("Creating the test files")
+	ioutil.WriteFile(dir+"/api.access.log", []byte("api old\n"), 0644)
+	ioutil.WriteFile(dir+"/www.access.log", []byte("www old\n"), 0644)
+	ioutil.WriteFile(dir+"/error.log", []byte("error\n"), 0644)
+
+	if !r.discover(true, printCh) {
+		t.Fatal("Expected files to be found")
+	}
+	if len(r.tailers) != 2 {
+		t.Fatalf("Expected 2 tailed files, got %d", len(r.tailers))
+	}
+
+	t.Log("Adding a new file and rotating an old one")
+	ioutil.WriteFile(dir+"/img.access.log", []byte("img new\n"), 0644)
+	os.Rename(dir+"/api.access.log", dir+"/api.access.log.1")
+	ioutil.WriteFile(dir+"/api.access.log", []byte("api new\n"), 0644)
+
+	logCh := make(chan entry.LogEntry, 10)
+	r.poll(r.tailers[dir+"/api.access.log"], logCh, printCh)
+	if !r.discover(false, printCh) {
+		t.Fatal("Expected new file to be found")
+	}
+	if len(r.tailers) != 3 {
+		t.Fatalf("Expected 3 tailed files, got %d", len(r.tailers))
+	}
+	r.poll(r.tailers[dir+"/img.access.log"], logCh, printCh)
+	close(logCh)
+
+	expected := map[string]string{
+		dir + "/api.access.log": "api new",
+		dir + "/img.access.log": "img new",
+	}
+	for e := range logCh {
+		if expected[e.Source] != e.Line {
+			t.Fatalf("Unexpected log entry %q from %q", e.Line, e.Source)
+		}
+		delete(expected, e.Source)
+	}
+	if len(expected) != 0 {
+		t.Fatalf("Missing log entries %v", expected)
+	}
+}
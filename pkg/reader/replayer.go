@@ -0,0 +1,146 @@
+package reader
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	alert "httplogmonitor/pkg/alertmanager"
+	"httplogmonitor/pkg/clock"
+	"httplogmonitor/pkg/config"
+	"httplogmonitor/pkg/entry"
+	"httplogmonitor/pkg/printer"
+)
+
+// Replayer feeds the log entries of complete log files through the pipeline (replay mode)
+// pacing them by their timestamps at the configured speed
+// the virtual clock follows the timestamps of the log entries (it never moves backward)
+type Replayer struct {
+	config *config.Config
+	clock  *clock.Virtual
+	// logTime gives the time of a log entry in the configured format
+	logTime func(entry.LogEntry) (time.Time, error)
+	// log time and wall time of the first log entry
+	logStart  time.Time
+	wallStart time.Time
+}
+
+// NewReplayer returns an instance of Replayer moving forward the given virtual clock
+// by the time of the log entries given by logTime
+func NewReplayer(cfg *config.Config, clk *clock.Virtual, logTime func(entry.LogEntry) (time.Time, error)) *Replayer {
+	return &Replayer{
+		config:  cfg,
+		clock:   clk,
+		logTime: logTime,
+	}
+}
+
+// Start sends raw log entries labeled with their source to logCh, counter metric to metCh and errors to printCh
+// the files matching the configured paths are replayed one after another in the order of their paths
+// the counter metric is sent at every polling tick of the log time (unless the event time mode is on)
+// closes logCh once all the files are replayed or the context is done
+func (r *Replayer) Start(ctx context.Context, logCh chan<- entry.LogEntry, metCh chan<- alert.Metric, printCh chan<- printer.Formatter, wg *sync.WaitGroup) {
+	defer wg.Done()
+	defer close(logCh)
+
+	paths := Glob(r.config.LogFilePaths)
+	if len(paths) == 0 {
+		printCh <- printer.NewErrorMessage(fmt.Sprintf("No log file matches %v", r.config.LogFilePaths))
+		return
+	}
+
+	poll := time.Duration(r.config.PollIntervalSec) * time.Second
+	// time of the next polling tick
+	var next time.Time
+	// number of log entries sent for each tick
+	logCnt := 0
+
+	for _, p := range paths {
+		f, err := OpenFile(p)
+		if err != nil {
+			printCh <- printer.NewErrorMessage(fmt.Sprintf("Error opening log file: %s", err.Error()))
+			continue
+		}
+
+		sc := bufio.NewScanner(f)
+		sc.Buffer(make([]byte, 64*1024), MaxLineSize)
+		for sc.Scan() {
+			// the log entries with no valid time are sent right away, the collector reports them
+			e := entry.LogEntry{Source: p, Line: sc.Text()}
+			if t, err := r.logTime(e); err == nil && !t.IsZero() {
+				if next.IsZero() {
+					r.logStart = t
+					r.wallStart = time.Now()
+					next = t.Add(poll)
+				}
+				if !r.wait(ctx, t) {
+					f.Close()
+					return
+				}
+				for !t.Before(next) {
+					r.tick(next, logCnt, logCh, metCh)
+					logCnt = 0
+					next = next.Add(poll)
+				}
+				// the clock follows the log entries between the ticks too,
+				// so that none of them is dated after the clock time when collected
+				r.clock.Advance(t)
+			}
+			logCh <- e
+			logCnt++
+		}
+		if err := sc.Err(); err != nil {
+			printCh <- printer.NewErrorMessage(fmt.Sprintf("Error reading log file %s: %s", p, err.Error()))
+		}
+		f.Close()
+	}
+
+	if !next.IsZero() {
+		// the last (incomplete) polling interval
+		r.tick(next, logCnt, logCh, metCh)
+	}
+}
+
+// wait sleeps until the wall time corresponding to the given log time at the configured speed
+// doesn't sleep at the maximum speed
+// returns false if the context is done
+func (r *Replayer) wait(ctx context.Context, t time.Time) bool {
+	if r.config.ReplaySpeed <= 0 {
+		select {
+		case <-ctx.Done():
+			return false
+		default:
+			return true
+		}
+	}
+
+	at := r.wallStart.Add(time.Duration(float64(t.Sub(r.logStart)) / r.config.ReplaySpeed))
+	d := time.Until(at)
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// tick moves the virtual clock to the given polling tick and sends the counter metric for it
+// all the log entries sent before are collected first
+// so that they fall into the summary interval ending with the tick (if any)
+func (r *Replayer) tick(t time.Time, logCnt int, logCh chan<- entry.LogEntry, metCh chan<- alert.Metric) {
+	synced := make(chan struct{})
+	logCh <- entry.LogEntry{Sync: synced}
+	<-synced
+	r.clock.Advance(t)
+	// in the event time mode the hits are counted by the collector
+	if !r.config.EventTime {
+		metCh <- alert.NewCounterMetric(logCnt, t)
+	}
+}
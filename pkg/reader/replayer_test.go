@@ -0,0 +1,195 @@
+package reader
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	alert "httplogmonitor/pkg/alertmanager"
+	"httplogmonitor/pkg/clock"
+	"httplogmonitor/pkg/collector"
+	"httplogmonitor/pkg/config"
+	"httplogmonitor/pkg/entry"
+	"httplogmonitor/pkg/printer"
+)
+
+func TestReplayerNominal(t *testing.T) {
+	f, err := ioutil.TempFile("", "test")
+	if err != nil {
+		t.Skip("Failed to create the test file: ", err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString(`127.0.0.1 - james [09/May/2018:16:00:01 +0000] "GET /report HTTP/1.0" 200 123
+127.0.0.1 - james [09/May/2018:16:00:02 +0000] "GET /report HTTP/1.0" 200 123
+not a log entry
+127.0.0.1 - james [09/May/2018:16:00:02 +0000] "GET /report HTTP/1.0" 200 123
+127.0.0.1 - james [09/May/2018:16:00:04 +0000] "GET /report HTTP/1.0" 200 123
+`)
+	f.Close()
+
+	cfg := config.NewDefault()
+	cfg.LogFilePaths = []string{f.Name()}
+	cfg.ReplaySpeed = 0
+	clk := clock.NewVirtual()
+	parser, err := collector.NewParser(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create the parser: %s", err)
+	}
+	r := NewReplayer(cfg, clk, collector.LogTime(parser))
+
+	logCh := make(chan entry.LogEntry, 10)
+	metCh := make(chan alert.Metric, 10)
+	printCh := make(chan printer.Formatter, 10)
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+
+	// the clock ticks are consumed by a summary interval like ticker
+	tick := clk.NewTicker(2 * time.Second)
+	defer tick.Stop()
+	ticks := []time.Time{}
+	entries := 0
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case tm := <-tick.C():
+				ticks = append(ticks, tm)
+			case e, ok := <-logCh:
+				if !ok {
+					close(done)
+					return
+				}
+				if e.Sync != nil {
+					close(e.Sync)
+					continue
+				}
+				entries++
+			}
+		}
+	}()
+
+	r.Start(context.Background(), logCh, metCh, printCh, wg)
+	<-done
+	close(metCh)
+
+	if entries != 5 {
+		t.Fatalf("Expected 5 log entries, got %d", entries)
+	}
+
+	t.Log("Checking the metrics")
+	expected := []struct {
+		cnt  int
+		time string
+	}{
+		{1, "2018-05-09 16:00:02"},
+		{3, "2018-05-09 16:00:03"},
+		{0, "2018-05-09 16:00:04"},
+		{1, "2018-05-09 16:00:05"},
+	}
+	for _, e := range expected {
+		m, ok := <-metCh
+		if !ok {
+			t.Fatalf("Expected metric %v", e)
+		}
+		cnt, _ := m.Value().(int)
+		if cnt != e.cnt || m.Time().Format("2006-01-02 15:04:05") != e.time {
+			t.Fatalf("Expected metric %v, got %d at %s", e, cnt, m.Time())
+		}
+	}
+	if _, ok := <-metCh; ok {
+		t.Fatal("Expected metric channel to be closed")
+	}
+
+	t.Log("Checking the clock")
+	if len(ticks) != 2 || ticks[1].Format("15:04:05") != "16:00:05" {
+		t.Fatalf("Expected ticks at 16:00:03 and 16:00:05, got %v", ticks)
+	}
+}
+
+func TestReplayerEventTime(t *testing.T) {
+	f, err := ioutil.TempFile("", "test")
+	if err != nil {
+		t.Skip("Failed to create the test file: ", err)
+	}
+	defer os.Remove(f.Name())
+	// 3 log entries per second for 15 seconds
+	start, _ := time.Parse("2006-01-02 15:04:05", "2018-05-09 16:00:00")
+	for i := 0; i < 45; i++ {
+		tm := start.Add(time.Duration(i/3) * time.Second).Format("02/Jan/2006:15:04:05 -0700")
+		f.WriteString(`127.0.0.1 - james [` + tm + `] "GET /report HTTP/1.0" 200 123` + "\n")
+	}
+	f.Close()
+
+	cfg := config.NewDefault()
+	cfg.LogFilePaths = []string{f.Name()}
+	cfg.ReplaySpeed = 0
+	cfg.EventTime = true
+	cfg.PollIntervalSec = 5
+	cfg.LatenessSec = 0
+	clk := clock.NewVirtual()
+	parser, err := collector.NewParser(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create the parser: %s", err)
+	}
+	r := NewReplayer(cfg, clk, collector.LogTime(parser))
+	c, err := collector.NewWithClock(cfg, clk)
+	if err != nil {
+		t.Fatalf("Failed to create the collector: %s", err)
+	}
+
+	logCh := make(chan entry.LogEntry, 10)
+	metCh := make(chan alert.Metric, 10)
+	printCh := make(chan printer.Formatter)
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+
+	errs := []string{}
+	printDone := make(chan struct{})
+	go func() {
+		for m := range printCh {
+			if e, ok := m.(printer.ErrorMessage); ok {
+				errs = append(errs, e.Format())
+			}
+		}
+		close(printDone)
+	}()
+	collectorDone := make(chan struct{})
+	go func() {
+		c.Start(logCh, metCh, printCh)
+		close(collectorDone)
+	}()
+	mets := []alert.CounterMetric{}
+	metDone := make(chan struct{})
+	go func() {
+		for m := range metCh {
+			if cm, ok := m.(alert.CounterMetric); ok {
+				mets = append(mets, cm)
+			}
+		}
+		close(metDone)
+	}()
+
+	r.Start(context.Background(), logCh, metCh, printCh, wg)
+	<-collectorDone
+	close(metCh)
+	<-metDone
+	close(printCh)
+	<-printDone
+
+	if len(errs) != 0 {
+		t.Fatalf("Expected no error, got %v", errs)
+	}
+	t.Log("Checking the hits per bucket")
+	expected := []string{"16:00:05", "16:00:10", "16:00:15"}
+	if len(mets) != len(expected) {
+		t.Fatalf("Expected %d counter metrics, got %d", len(expected), len(mets))
+	}
+	for i, m := range mets {
+		if cnt, _ := m.Value().(int); cnt != 15 || m.Time().Format("15:04:05") != expected[i] {
+			t.Errorf("Expected 15 hits at %s, got %d at %s", expected[i], cnt, m.Time().Format("15:04:05"))
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package reader
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+
+	"httplogmonitor/pkg/entry"
+)
+
+// sampleTailSize is the size of the end of the file read to sample its last lines
+const sampleTailSize = 256 * 1024
+
+// SampleEntries returns up to n log entries of the files matching the given patterns
+// taken from the first lines of the files (complete files) or from their last lines (tailed files)
+// the files are sampled in the order of their paths until enough entries are read, unreadable ones are skipped
+func SampleEntries(patterns []string, n int, last bool) []entry.LogEntry {
+	var entries []entry.LogEntry
+	for _, p := range Glob(patterns) {
+		if len(entries) >= n {
+			break
+		}
+		var lines []string
+		var err error
+		if last {
+			lines, err = lastLines(p, n-len(entries))
+		} else {
+			lines, err = firstLines(p, n-len(entries))
+		}
+		if err != nil {
+			continue
+		}
+		for _, l := range lines {
+			entries = append(entries, entry.LogEntry{Source: p, Line: l})
+		}
+	}
+	return entries
+}
+
+// firstLines returns up to n first non empty lines of the given complete (maybe gzipped) file
+func firstLines(path string, n int) ([]string, error) {
+	f, err := OpenFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var lines []string
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), MaxLineSize)
+	for len(lines) < n && sc.Scan() {
+		if len(sc.Text()) != 0 {
+			lines = append(lines, sc.Text())
+		}
+	}
+	return lines, sc.Err()
+}
+
+// lastLines returns up to n last complete non empty lines of the given tailed file
+func lastLines(path string, n int) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	off := fi.Size() - sampleTailSize
+	if off < 0 {
+		off = 0
+	}
+	buf := make([]byte, fi.Size()-off)
+	if _, err := f.ReadAt(buf, off); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	all := strings.Split(string(buf), "\n")
+	// the first line is partial unless read from the beginning, the last one is partial or empty
+	if off > 0 {
+		all = all[1:]
+	}
+	if len(all) != 0 {
+		all = all[:len(all)-1]
+	}
+
+	var lines []string
+	for i := len(all) - 1; i >= 0 && len(lines) < n; i-- {
+		if len(all[i]) != 0 {
+			lines = append([]string{all[i]}, lines...)
+		}
+	}
+	return lines, nil
+}
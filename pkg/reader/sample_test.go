@@ -0,0 +1,70 @@
+package reader
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSampleEntries(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sample")
+	if err != nil {
+		t.Skip("Failed to create the test directory: ", err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"a.log": "a1\n\na2\na3\n",
+		"b.log": "b1\nb2\nb3\npartial",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal("Failed to write the test file: ", err)
+		}
+	}
+	pattern := []string{filepath.Join(dir, "*.log")}
+
+	testCases := []struct {
+		name     string
+		n        int
+		last     bool
+		expected []string
+	}{
+		{
+			name:     "First lines",
+			n:        2,
+			expected: []string{"a1", "a2"},
+		},
+		{
+			name:     "First lines of several files",
+			n:        5,
+			expected: []string{"a1", "a2", "a3", "b1", "b2"},
+		},
+		{
+			name:     "Last lines",
+			n:        2,
+			last:     true,
+			expected: []string{"a2", "a3"},
+		},
+		{
+			name:     "Last complete lines of several files",
+			n:        6,
+			last:     true,
+			expected: []string{"a1", "a2", "a3", "b1", "b2", "b3"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var lines []string
+			for _, e := range SampleEntries(pattern, tc.n, tc.last) {
+				lines = append(lines, e.Line)
+			}
+			if strings.Join(lines, ",") != strings.Join(tc.expected, ",") {
+				t.Errorf("Test case %q: expected %v, got %v", tc.name, tc.expected, lines)
+			}
+		})
+	}
+}
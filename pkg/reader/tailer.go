@@ -0,0 +1,173 @@
+package reader
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+)
+
+// tailer follows a single file by its path
+// surviving the rotations done by renaming (or removing) the file
+// as well as the ones done by truncating it (copytruncate)
+type tailer struct {
+	path   string
+	file   *os.File
+	info   os.FileInfo
+	reader *bufio.Reader
+	// pos is the number of bytes read from the current file
+	pos int64
+	// partial keeps the last line read until its end of line appears
+	partial []byte
+}
+
+// newTailer opens the file from the given path
+// the offset is put to the end of the file if the tail flag is set
+func newTailer(path string, tail bool) (*tailer, error) {
+	f, fi, err := openFile(path)
+	if err != nil {
+		return nil, err
+	}
+	t := &tailer{path: path}
+	t.reset(f, fi)
+	if tail {
+		pos, err := t.file.Seek(0, io.SeekEnd)
+		if err != nil {
+			t.close()
+			return nil, err
+		}
+		t.pos = pos
+	}
+	return t, nil
+}
+
+// openFile opens the file from the given path and gets its info
+func openFile(path string) (*os.File, os.FileInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return f, fi, nil
+}
+
+// reset sets the given file as the one to read from the beginning
+func (t *tailer) reset(f *os.File, fi os.FileInfo) {
+	t.file = f
+	t.info = fi
+	t.reader = bufio.NewReader(f)
+	t.pos = 0
+	t.partial = nil
+}
+
+// seek puts the offset of the current file to the given position
+// the position must be at the beginning of a line
+func (t *tailer) seek(offset int64) error {
+	pos, err := t.file.Seek(offset, io.SeekStart)
+	if err != nil {
+		return err
+	}
+	t.reader.Reset(t.file)
+	t.pos = pos
+	t.partial = nil
+	return nil
+}
+
+// offset returns the position right after the last complete line read
+func (t *tailer) offset() int64 {
+	return t.pos - int64(len(t.partial))
+}
+
+// checkpoint returns the current position in the tailed file
+func (t *tailer) checkpoint() checkpoint {
+	return checkpoint{
+		Path:   t.path,
+		Inode:  fileInode(t.info),
+		Offset: t.offset(),
+	}
+}
+
+// close closes the currently opened file
+func (t *tailer) close() error {
+	return t.file.Close()
+}
+
+// read sends all the complete lines available in the file to the given function
+// returns the number of lines sent
+func (t *tailer) read(send func(string)) (int, error) {
+	cnt := 0
+	for {
+		b, err := t.reader.ReadBytes('\n')
+		t.pos += int64(len(b))
+		t.partial = append(t.partial, b...)
+		if err != nil {
+			if err == io.EOF {
+				err = nil
+			}
+			return cnt, err
+		}
+		send(strings.TrimSpace(string(t.partial)))
+		t.partial = t.partial[:0]
+		cnt++
+	}
+}
+
+// flush sends the last incomplete line if any
+// returns the number of lines sent
+func (t *tailer) flush(send func(string)) int {
+	if len(strings.TrimSpace(string(t.partial))) == 0 {
+		t.partial = t.partial[:0]
+		return 0
+	}
+	send(strings.TrimSpace(string(t.partial)))
+	t.partial = t.partial[:0]
+	return 1
+}
+
+// rotated checks whether the file was rotated since it was opened
+// returns the short description of the rotation or an empty string if no rotation happened
+func (t *tailer) rotated() (string, error) {
+	fi, err := os.Stat(t.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			// the file was moved away and the new one is not created yet:
+			// keep reading the old descriptor
+			return "", nil
+		}
+		return "", err
+	}
+	if !os.SameFile(t.info, fi) {
+		return "file replaced", nil
+	}
+	if fi.Size() < t.pos {
+		return "file truncated", nil
+	}
+	return "", nil
+}
+
+// reopen drains the old file and starts reading the new one from the beginning
+// returns the number of lines sent
+func (t *tailer) reopen(send func(string)) (int, error) {
+	// opening the new file first to keep reading the old one in case of failure
+	f, fi, err := openFile(t.path)
+	if err != nil {
+		return 0, err
+	}
+
+	// the writer may have added some lines to the old file since the last read
+	cnt, err := t.read(send)
+	if err != nil {
+		f.Close()
+		return cnt, err
+	}
+	// the writer is gone, the last line won't get its end
+	cnt += t.flush(send)
+	t.close()
+
+	t.reset(f, fi)
+	return cnt, nil
+}
@@ -0,0 +1,101 @@
+package reader
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestTailerRotation(t *testing.T) {
+	testCases := []struct {
+		name     string
+		rotate   func(path string) error
+		expected string
+	}{
+		{
+			name: "Rename",
+			rotate: func(path string) error {
+				if err := os.Rename(path, path+".1"); err != nil {
+					return err
+				}
+				return ioutil.WriteFile(path, []byte("new 1\nnew 2\n"), 0644)
+			},
+			expected: "file replaced",
+		},
+		{
+			name: "Copy truncate",
+			rotate: func(path string) error {
+				if err := os.Truncate(path, 0); err != nil {
+					return err
+				}
+				return ioutil.WriteFile(path, []byte("new 1\nnew 2\n"), 0644)
+			},
+			expected: "file truncated",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "test")
+			if err != nil {
+				t.Skip("Failed to create the test directory: ", err)
+			}
+			defer os.RemoveAll(dir)
+			path := dir + "/access.log"
+
+			t.Log("Creating the test file")
+			if err := ioutil.WriteFile(path, []byte("skipped\n"), 0644); err != nil {
+				t.Fatal("Failed to create the test file: ", err)
+			}
+
+			tl, err := newTailer(path, true)
+			if err != nil {
+				t.Fatal("Failed to create the tailer: ", err)
+			}
+			defer tl.close()
+
+			outputs := []string{}
+			send := func(l string) { outputs = append(outputs, l) }
+
+			t.Log("Adding log entries")
+			f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+			if err != nil {
+				t.Fatal("Failed to open the test file: ", err)
+			}
+			f.WriteString("old 1\nold 2\nold ")
+			f.Close()
+
+			if _, err := tl.read(send); err != nil {
+				t.Fatal("Failed to read: ", err)
+			}
+			if rotation, _ := tl.rotated(); rotation != "" {
+				t.Fatalf("Unexpected rotation %q", rotation)
+			}
+
+			t.Log("Rotating the file")
+			if err := tc.rotate(path); err != nil {
+				t.Fatal("Failed to rotate the test file: ", err)
+			}
+
+			rotation, err := tl.rotated()
+			if err != nil {
+				t.Fatal("Failed to check the rotation: ", err)
+			}
+			if rotation != tc.expected {
+				t.Fatalf("Expected rotation %q, got %q", tc.expected, rotation)
+			}
+			if _, err := tl.reopen(send); err != nil {
+				t.Fatal("Failed to reopen: ", err)
+			}
+			if _, err := tl.read(send); err != nil {
+				t.Fatal("Failed to read: ", err)
+			}
+
+			expected := []string{"old 1", "old 2", "old", "new 1", "new 2"}
+			if !reflect.DeepEqual(expected, outputs) {
+				t.Fatalf("Expected outputs %q, got %q", expected, outputs)
+			}
+		})
+	}
+}
@@ -0,0 +1,13 @@
+package reader
+
+// watcher wakes the reader up as soon as the watched files change
+// so that the new log entries don't wait for the next polling tick
+type watcher interface {
+	// Events returns the channel notified about the changes of the watched files
+	Events() <-chan struct{}
+	// Watch starts watching the file from the given path
+	// must be called again once the file is rotated
+	Watch(path string) error
+	// Close stops watching all the files
+	Close() error
+}
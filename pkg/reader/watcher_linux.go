@@ -0,0 +1,101 @@
+//go:build linux
+// +build linux
+
+package reader
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+)
+
+const (
+	// changes of the watched file
+	inotifyFileMask = syscall.IN_MODIFY | syscall.IN_MOVE_SELF | syscall.IN_DELETE_SELF
+	// appearance of the new file after a rotation
+	inotifyDirMask = syscall.IN_CREATE | syscall.IN_MOVED_TO
+)
+
+// inotifyWatcher implements watcher using Linux inotify
+type inotifyWatcher struct {
+	fd     int
+	file   *os.File
+	events chan struct{}
+	mu     sync.Mutex
+	// watch descriptors of the watched files
+	wds map[string]int
+}
+
+// newWatcher returns an instance of the inotify watcher
+func newWatcher() (watcher, error) {
+	// non blocking descriptor lets the runtime poller unblock the read once the file is closed
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+	w := &inotifyWatcher{
+		fd:     fd,
+		file:   os.NewFile(uintptr(fd), "inotify"),
+		events: make(chan struct{}, 1),
+		wds:    map[string]int{},
+	}
+	go w.run()
+	return w, nil
+}
+
+// Events returns the channel notified about the changes of the watched files
+func (w *inotifyWatcher) Events() <-chan struct{} {
+	return w.events
+}
+
+// Watch starts watching the file from the given path and its directory
+// the watch of the previous file from the same path is removed
+func (w *inotifyWatcher) Watch(path string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, ok := w.wds[filepath.Dir(path)]; !ok {
+		wd, err := syscall.InotifyAddWatch(w.fd, filepath.Dir(path), inotifyDirMask)
+		if err != nil {
+			return os.NewSyscallError("inotify_add_watch", err)
+		}
+		w.wds[filepath.Dir(path)] = wd
+	}
+
+	wd, err := syscall.InotifyAddWatch(w.fd, path, inotifyFileMask)
+	if err != nil {
+		return os.NewSyscallError("inotify_add_watch", err)
+	}
+	if prev, ok := w.wds[path]; ok && prev != wd {
+		// the old file may be already gone together with its watch
+		syscall.InotifyRmWatch(w.fd, uint32(prev))
+	}
+	w.wds[path] = wd
+	return nil
+}
+
+// Close stops watching all the files
+func (w *inotifyWatcher) Close() error {
+	return w.file.Close()
+}
+
+// run notifies the events channel on every batch of inotify events
+// the notifications are coalesced as the reader reads everything at once
+func (w *inotifyWatcher) run() {
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		n, err := w.file.Read(buf)
+		if err != nil {
+			// closed
+			return
+		}
+		if n < syscall.SizeofInotifyEvent {
+			continue
+		}
+		select {
+		case w.events <- struct{}{}:
+		default:
+		}
+	}
+}
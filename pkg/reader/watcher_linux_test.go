@@ -0,0 +1,59 @@
+package reader
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestInotifyWatcher(t *testing.T) {
+	dir, err := ioutil.TempDir("", "test")
+	if err != nil {
+		t.Skip("Failed to create the test directory: ", err)
+	}
+	defer os.RemoveAll(dir)
+	path := dir + "/access.log"
+
+	t.Log("Creating the test file")
+	if err := ioutil.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal("Failed to create the test file: ", err)
+	}
+
+	w, err := newWatcher()
+	if err != nil {
+		t.Skip("Inotify is not available: ", err)
+	}
+	defer w.Close()
+	if err := w.Watch(path); err != nil {
+		t.Fatal("Failed to watch the test file: ", err)
+	}
+
+	expectEvent := func(action string) {
+		select {
+		case <-w.Events():
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out waiting for the event after %s", action)
+		}
+	}
+
+	t.Log("Modifying the file")
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal("Failed to open the test file: ", err)
+	}
+	f.WriteString("here it comes\n")
+	f.Close()
+	expectEvent("write")
+
+	t.Log("Rotating the file")
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal("Failed to rename the test file: ", err)
+	}
+	expectEvent("rename")
+
+	if err := ioutil.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal("Failed to create the new test file: ", err)
+	}
+	expectEvent("creation")
+}
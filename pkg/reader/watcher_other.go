@@ -0,0 +1,13 @@
+//go:build !linux
+// +build !linux
+
+package reader
+
+import (
+	"errors"
+)
+
+// newWatcher returns an error as inotify is only available on Linux
+func newWatcher() (watcher, error) {
+	return nil, errors.New("inotify is not supported on this platform")
+}
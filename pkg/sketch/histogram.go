@@ -0,0 +1,106 @@
+// Package sketch provides the bounded memory summaries of the log message streams:
+// they are mergeable so that the summaries of the intervals can be combined
+package sketch
+
+import (
+	"math"
+	"time"
+)
+
+// histogramAccuracy is the relative error of the quantiles given by the histogram
+const histogramAccuracy = 0.01
+
+// histogramGamma is the ratio between the bounds of the consecutive buckets
+var histogramGamma = (1 + histogramAccuracy) / (1 - histogramAccuracy)
+
+// Histogram is a mergeable histogram of durations with logarithmic buckets (HDR histogram like):
+// the quantiles are given with 1% relative error, the number of buckets grows with the logarithm of the max duration
+// (about 1100 buckets up to one hour)
+type Histogram struct {
+	// counts of the durations by bucket, the bucket i holds the durations (in µs) in (gamma^(i-1), gamma^i]
+	counts []int
+	// durations below 1µs
+	zeros int
+	count int
+	max   time.Duration
+}
+
+// NewHistogram returns a new empty instance of Histogram
+func NewHistogram() *Histogram {
+	return &Histogram{}
+}
+
+// Add adds the given duration to the histogram, the negative durations are taken as 0
+func (h *Histogram) Add(d time.Duration) {
+	h.count++
+	if d > h.max {
+		h.max = d
+	}
+	us := float64(d) / float64(time.Microsecond)
+	if us < 1 {
+		h.zeros++
+		return
+	}
+	i := int(math.Ceil(math.Log(us) / math.Log(histogramGamma)))
+	if i >= len(h.counts) {
+		counts := make([]int, i+1)
+		copy(counts, h.counts)
+		h.counts = counts
+	}
+	h.counts[i]++
+}
+
+// Merge adds all the durations of the given histogram to this one
+func (h *Histogram) Merge(o *Histogram) {
+	if len(o.counts) > len(h.counts) {
+		counts := make([]int, len(o.counts))
+		copy(counts, h.counts)
+		h.counts = counts
+	}
+	for i, c := range o.counts {
+		h.counts[i] += c
+	}
+	h.zeros += o.zeros
+	h.count += o.count
+	if o.max > h.max {
+		h.max = o.max
+	}
+}
+
+// Count returns the number of the durations added
+func (h *Histogram) Count() int {
+	return h.count
+}
+
+// Max returns the maximum duration added (exact)
+func (h *Histogram) Max() time.Duration {
+	return h.max
+}
+
+// Quantile returns the duration at the given quantile (0.5 for the median, 0.99, ...), 0 if the histogram is empty
+func (h *Histogram) Quantile(q float64) time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+	// rank of the duration, starting from 1
+	rank := int(math.Ceil(q * float64(h.count)))
+	if rank < 1 {
+		rank = 1
+	}
+	n := h.zeros
+	if n >= rank {
+		return 0
+	}
+	for i, c := range h.counts {
+		n += c
+		if n >= rank {
+			// the value of the bucket having the same relative error to both its bounds
+			us := 2 * math.Pow(histogramGamma, float64(i)) / (histogramGamma + 1)
+			if d := time.Duration(us * float64(time.Microsecond)); d < h.max {
+				return d
+			}
+			return h.max
+		}
+	}
+	return h.max
+}
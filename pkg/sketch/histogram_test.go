@@ -0,0 +1,76 @@
+package sketch
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestHistogramQuantile(t *testing.T) {
+	h := NewHistogram()
+	// 1ms to 1000ms
+	for i := 1; i <= 1000; i++ {
+		h.Add(time.Duration(i) * time.Millisecond)
+	}
+
+	testCases := []struct {
+		q        float64
+		expected time.Duration
+	}{
+		{q: 0, expected: time.Millisecond},
+		{q: 0.5, expected: 500 * time.Millisecond},
+		{q: 0.9, expected: 900 * time.Millisecond},
+		{q: 0.99, expected: 990 * time.Millisecond},
+		{q: 1, expected: 1000 * time.Millisecond},
+	}
+
+	for _, tc := range testCases {
+		got := h.Quantile(tc.q)
+		if err := math.Abs(float64(got-tc.expected)) / float64(tc.expected); err > histogramAccuracy {
+			t.Errorf("Quantile %v: expected %s, got %s (relative error %.3f)", tc.q, tc.expected, got, err)
+		}
+	}
+	if h.Max() != time.Second {
+		t.Errorf("Expected max 1s, got %s", h.Max())
+	}
+	if h.Count() != 1000 {
+		t.Errorf("Expected 1000 durations, got %d", h.Count())
+	}
+}
+
+func TestHistogramMerge(t *testing.T) {
+	all := NewHistogram()
+	a := NewHistogram()
+	b := NewHistogram()
+	for i := 0; i < 100; i++ {
+		d := time.Duration(i*i) * time.Microsecond
+		all.Add(d)
+		if i%3 == 0 {
+			a.Add(d)
+		} else {
+			b.Add(d)
+		}
+	}
+	a.Merge(b)
+
+	for _, q := range []float64{0, 0.5, 0.9, 0.99, 1} {
+		if a.Quantile(q) != all.Quantile(q) {
+			t.Errorf("Quantile %v: expected %s, got %s", q, all.Quantile(q), a.Quantile(q))
+		}
+	}
+	if a.Count() != all.Count() || a.Max() != all.Max() {
+		t.Errorf("Expected %d durations up to %s, got %d up to %s", all.Count(), all.Max(), a.Count(), a.Max())
+	}
+}
+
+func TestHistogramEmpty(t *testing.T) {
+	h := NewHistogram()
+	if h.Quantile(0.5) != 0 || h.Max() != 0 {
+		t.Errorf("Expected zero quantile and max, got %s and %s", h.Quantile(0.5), h.Max())
+	}
+	h.Add(0)
+	h.Add(-time.Millisecond)
+	if h.Quantile(0.99) != 0 {
+		t.Errorf("Expected zero quantile, got %s", h.Quantile(0.99))
+	}
+}
@@ -0,0 +1,170 @@
+package sketch
+
+import (
+	"hash/fnv"
+	"math"
+	"math/bits"
+	"time"
+)
+
+// hllPrecision is the number of the hash bits selecting the register: 4096 registers (1.6% standard error)
+const hllPrecision = 12
+
+// hllRegisters is the number of the registers of the dense HyperLogLog
+const hllRegisters = 1 << hllPrecision
+
+// hllSparseMax is the number of the distinct hashes kept as is before switching to the registers,
+// about the memory taken by the registers
+const hllSparseMax = 256
+
+// HyperLogLog estimates the number of the distinct keys in constant memory (about 4KB):
+// the keys are counted exactly while there are few of them, then estimated with 1.6% standard error
+type HyperLogLog struct {
+	// hashes of the keys while there are few of them, nil once the registers are used
+	sparse map[uint64]struct{}
+	// max number of the leading zeros (plus one) of the hashes by register
+	registers []uint8
+}
+
+// NewHyperLogLog returns a new empty instance of HyperLogLog
+func NewHyperLogLog() *HyperLogLog {
+	return &HyperLogLog{sparse: map[uint64]struct{}{}}
+}
+
+// Add adds the given key to the set of the keys counted
+func (h *HyperLogLog) Add(key string) {
+	h.addHash(hash(key))
+}
+
+// addHash adds the given key hash
+func (h *HyperLogLog) addHash(x uint64) {
+	if h.registers == nil {
+		h.sparse[x] = struct{}{}
+		if len(h.sparse) > hllSparseMax {
+			h.densify()
+		}
+		return
+	}
+	i := x >> (64 - hllPrecision)
+	// the bit guards the rank in case the remaining bits are all zeros
+	rho := uint8(bits.LeadingZeros64(x<<hllPrecision|1<<(hllPrecision-1))) + 1
+	if rho > h.registers[i] {
+		h.registers[i] = rho
+	}
+}
+
+// densify switches from the hashes to the registers
+func (h *HyperLogLog) densify() {
+	h.registers = make([]uint8, hllRegisters)
+	for x := range h.sparse {
+		h.addHash(x)
+	}
+	h.sparse = nil
+}
+
+// Merge adds all the keys of the given HyperLogLog to this one
+func (h *HyperLogLog) Merge(o *HyperLogLog) {
+	if o.registers == nil {
+		for x := range o.sparse {
+			h.addHash(x)
+		}
+		return
+	}
+	if h.registers == nil {
+		h.densify()
+	}
+	for i, r := range o.registers {
+		if r > h.registers[i] {
+			h.registers[i] = r
+		}
+	}
+}
+
+// Count returns the estimated number of the distinct keys added
+func (h *HyperLogLog) Count() int {
+	if h.registers == nil {
+		return len(h.sparse)
+	}
+	m := float64(hllRegisters)
+	sum := 0.0
+	zeros := 0
+	for _, r := range h.registers {
+		sum += 1 / float64(uint64(1)<<r)
+		if r == 0 {
+			zeros++
+		}
+	}
+	e := 0.7213 / (1 + 1.079/m) * m * m / sum
+	// linear counting is more accurate for the small cardinalities
+	if e <= 2.5*m && zeros != 0 {
+		e = m * math.Log(m/float64(zeros))
+	}
+	return int(math.Round(e))
+}
+
+// hash returns the 64 bits hash of the given key, the bits of the FNV hash being mixed (murmur3 finalizer)
+// as they are all used by HyperLogLog
+func hash(key string) uint64 {
+	f := fnv.New64a()
+	f.Write([]byte(key))
+	x := f.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
+
+// RollingHyperLogLog estimates the number of the distinct keys seen during a rolling window (the last hour, day, ...)
+// the window is made of slots of HyperLogLog so that the memory stays constant
+type RollingHyperLogLog struct {
+	slot  time.Duration
+	slots []*HyperLogLog
+	// start of the time slot each HyperLogLog counts
+	starts []time.Time
+}
+
+// NewRollingHyperLogLog returns a new empty instance of RollingHyperLogLog counting the keys of the given window
+// in slots of the given width (the window is rounded to the slots)
+func NewRollingHyperLogLog(window, slot time.Duration) *RollingHyperLogLog {
+	n := int(window / slot)
+	if n < 1 {
+		n = 1
+	}
+	return &RollingHyperLogLog{
+		slot:   slot,
+		slots:  make([]*HyperLogLog, n),
+		starts: make([]time.Time, n),
+	}
+}
+
+// Add adds the given key seen at the given time
+func (r *RollingHyperLogLog) Add(key string, t time.Time) {
+	start := t.Truncate(r.slot)
+	i := r.index(start)
+	if r.slots[i] == nil || !r.starts[i].Equal(start) {
+		// the slot of the previous round is reused
+		r.slots[i] = NewHyperLogLog()
+		r.starts[i] = start
+	}
+	r.slots[i].Add(key)
+}
+
+// Count returns the estimated number of the distinct keys seen during the window ending at the given time
+func (r *RollingHyperLogLog) Count(now time.Time) int {
+	oldest := now.Truncate(r.slot).Add(-time.Duration(len(r.slots)-1) * r.slot)
+	h := NewHyperLogLog()
+	for i, s := range r.slots {
+		if s != nil && !r.starts[i].Before(oldest) && !r.starts[i].After(now) {
+			h.Merge(s)
+		}
+	}
+	return h.Count()
+}
+
+// index returns the index of the slot starting at the given time
+func (r *RollingHyperLogLog) index(start time.Time) int {
+	n := int64(len(r.slots))
+	return int(((start.UnixNano()/int64(r.slot))%n + n) % n)
+}
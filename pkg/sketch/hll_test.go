@@ -0,0 +1,105 @@
+package sketch
+
+import (
+	"math"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestHyperLogLogCount(t *testing.T) {
+	testCases := []struct {
+		name string
+		n    int
+	}{
+		{name: "Empty", n: 0},
+		{name: "Sparse", n: 200},
+		{name: "Dense small", n: 1000},
+		{name: "Dense", n: 100000},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := NewHyperLogLog()
+			for i := 0; i < tc.n; i++ {
+				// every key is added twice
+				h.Add("10.0." + strconv.Itoa(i/256) + "." + strconv.Itoa(i%256))
+				h.Add("10.0." + strconv.Itoa(i/256) + "." + strconv.Itoa(i%256))
+			}
+			got := h.Count()
+			if tc.n <= hllSparseMax {
+				if got != tc.n {
+					t.Errorf("Test case %q: expected exact count %d, got %d", tc.name, tc.n, got)
+				}
+				return
+			}
+			// 3 standard errors
+			if err := math.Abs(float64(got-tc.n)) / float64(tc.n); err > 0.05 {
+				t.Errorf("Test case %q: expected %d, got %d (relative error %.3f)", tc.name, tc.n, got, err)
+			}
+		})
+	}
+}
+
+func TestHyperLogLogMerge(t *testing.T) {
+	a := NewHyperLogLog()
+	b := NewHyperLogLog()
+	c := NewHyperLogLog()
+	for i := 0; i < 5000; i++ {
+		a.Add("a" + strconv.Itoa(i))
+		b.Add("a" + strconv.Itoa(i+2500))
+	}
+	for i := 0; i < 100; i++ {
+		c.Add("c" + strconv.Itoa(i))
+	}
+
+	// dense and sparse merged into dense
+	a.Merge(b)
+	a.Merge(c)
+	if got := a.Count(); math.Abs(float64(got-7600))/7600 > 0.05 {
+		t.Errorf("Expected about 7600 keys, got %d", got)
+	}
+
+	// sparse merged into sparse stays exact
+	d := NewHyperLogLog()
+	d.Add("c0")
+	d.Add("d0")
+	d.Merge(c)
+	if got := d.Count(); got != 101 {
+		t.Errorf("Expected 101 keys, got %d", got)
+	}
+}
+
+func TestRollingHyperLogLog(t *testing.T) {
+	start, _ := time.Parse(time.RFC3339, "2018-05-09T16:00:00Z")
+	r := NewRollingHyperLogLog(time.Hour, time.Minute)
+
+	r.Add("10.0.0.1", start)
+	r.Add("10.0.0.2", start.Add(30*time.Minute))
+	r.Add("10.0.0.1", start.Add(59*time.Minute))
+
+	testCases := []struct {
+		name     string
+		now      time.Time
+		expected int
+	}{
+		{name: "Whole window", now: start.Add(59 * time.Minute), expected: 2},
+		{name: "First slot expired", now: start.Add(60 * time.Minute), expected: 2},
+		{name: "Only the last slot", now: start.Add(90*time.Minute + 30*time.Second), expected: 1},
+		{name: "All expired", now: start.Add(3 * time.Hour), expected: 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := r.Count(tc.now); got != tc.expected {
+				t.Errorf("Test case %q: expected %d keys, got %d", tc.name, tc.expected, got)
+			}
+		})
+	}
+
+	// the slot of the previous round is reset
+	r.Add("10.0.0.3", start.Add(time.Hour))
+	if got := r.Count(start.Add(time.Hour)); got != 3 {
+		t.Errorf("Expected 3 keys once the first slot is reused, got %d", got)
+	}
+}
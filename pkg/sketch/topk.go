@@ -0,0 +1,176 @@
+package sketch
+
+import (
+	"container/heap"
+	"sort"
+)
+
+// TopK is a Space-Saving summary of the heaviest keys of a weighted stream:
+// at most capacity keys are tracked, a new key replaces the lightest one and inherits its count as the error,
+// so the counts are overestimated by at most total/capacity and every key heavier than that is tracked
+type TopK struct {
+	capacity int
+	items    map[string]*topItem
+	// min-heap of the tracked keys by count
+	heap  topHeap
+	total int
+}
+
+// Item is a tracked key with its estimated count, overestimated by at most Err
+type Item struct {
+	Key   string
+	Count int
+	Err   int
+}
+
+// topItem is a tracked key with its position in the heap
+type topItem struct {
+	Item
+	index int
+}
+
+// NewTopK returns a new empty instance of TopK tracking at most the given number of keys,
+// all the keys are tracked (exact counts) if the capacity is not positive
+func NewTopK(capacity int) *TopK {
+	return &TopK{
+		capacity: capacity,
+		items:    map[string]*topItem{},
+	}
+}
+
+// Add adds the given weight (1 for a hit, the bytes sent, ...) to the count of the key
+// returns the key evicted to track the given one if any
+func (t *TopK) Add(key string, n int) (string, bool) {
+	t.total += n
+	if it, ok := t.items[key]; ok {
+		it.Count += n
+		heap.Fix(&t.heap, it.index)
+		return "", false
+	}
+	if t.capacity <= 0 || len(t.heap) < t.capacity {
+		it := &topItem{Item: Item{Key: key, Count: n}}
+		t.items[key] = it
+		heap.Push(&t.heap, it)
+		return "", false
+	}
+
+	// the lightest key is replaced
+	it := t.heap[0]
+	evicted := it.Key
+	delete(t.items, evicted)
+	it.Key = key
+	it.Err = it.Count
+	it.Count += n
+	t.items[key] = it
+	heap.Fix(&t.heap, it.index)
+	return evicted, true
+}
+
+// Merge adds the counts of the given summary to this one keeping the capacity,
+// a key not tracked by one of the summaries is taken with the minimal count of that summary as the error
+func (t *TopK) Merge(o *TopK) {
+	tMin, oMin := t.minCount(), o.minCount()
+	merged := map[string]*topItem{}
+	for k, it := range t.items {
+		merged[k] = &topItem{Item: Item{Key: k, Count: it.Count + oMin, Err: it.Err + oMin}}
+	}
+	for k, it := range o.items {
+		if m, ok := merged[k]; ok {
+			m.Count += it.Count - oMin
+			m.Err += it.Err - oMin
+			continue
+		}
+		merged[k] = &topItem{Item: Item{Key: k, Count: it.Count + tMin, Err: it.Err + tMin}}
+	}
+
+	items := make([]*topItem, 0, len(merged))
+	for _, it := range merged {
+		items = append(items, it)
+	}
+	sort.Slice(items, func(i, j int) bool { return heavier(items[i].Item, items[j].Item) })
+	if t.capacity > 0 && len(items) > t.capacity {
+		items = items[:t.capacity]
+	}
+
+	t.items = map[string]*topItem{}
+	t.heap = make(topHeap, 0, len(items))
+	for _, it := range items {
+		t.items[it.Key] = it
+		heap.Push(&t.heap, it)
+	}
+	t.total += o.total
+}
+
+// minCount returns the count a key not tracked may have: the lightest count once the summary is full, 0 otherwise
+func (t *TopK) minCount() int {
+	if t.capacity <= 0 || len(t.heap) < t.capacity {
+		return 0
+	}
+	return t.heap[0].Count
+}
+
+// Count returns the estimated count of the key, 0 if it's not tracked
+func (t *TopK) Count(key string) int {
+	if it, ok := t.items[key]; ok {
+		return it.Count
+	}
+	return 0
+}
+
+// Len returns the number of the tracked keys
+func (t *TopK) Len() int {
+	return len(t.heap)
+}
+
+// Total returns the sum of all the weights added (exact)
+func (t *TopK) Total() int {
+	return t.total
+}
+
+// Top returns at most n heaviest keys by decreasing count,
+// the keys are compared for the stable output of the equal counts
+func (t *TopK) Top(n int) []Item {
+	items := make([]Item, 0, len(t.heap))
+	for _, it := range t.heap {
+		items = append(items, it.Item)
+	}
+	sort.Slice(items, func(i, j int) bool { return heavier(items[i], items[j]) })
+	if len(items) > n {
+		items = items[:n]
+	}
+	return items
+}
+
+// heavier returns true if the item a comes before b in the top
+func heavier(a, b Item) bool {
+	if a.Count == b.Count {
+		return a.Key < b.Key
+	}
+	return a.Count > b.Count
+}
+
+// topHeap implements heap.Interface, the lightest key is on top
+type topHeap []*topItem
+
+func (h topHeap) Len() int { return len(h) }
+
+func (h topHeap) Less(i, j int) bool { return heavier(h[j].Item, h[i].Item) }
+
+func (h topHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *topHeap) Push(x interface{}) {
+	it := x.(*topItem)
+	it.index = len(*h)
+	*h = append(*h, it)
+}
+
+func (h *topHeap) Pop() interface{} {
+	old := *h
+	it := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return it
+}
@@ -0,0 +1,108 @@
+package sketch
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestTopKExact(t *testing.T) {
+	k := NewTopK(3)
+	k.Add("/a", 1)
+	k.Add("/b", 1)
+	k.Add("/a", 1)
+	k.Add("/c", 5)
+	k.Add("/b", 1)
+
+	expected := []Item{
+		{Key: "/c", Count: 5},
+		{Key: "/a", Count: 2},
+		{Key: "/b", Count: 2},
+	}
+	if got := k.Top(10); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Expected top %+v, got top %+v", expected, got)
+	}
+	if got := k.Top(1); !reflect.DeepEqual(got, expected[:1]) {
+		t.Fatalf("Expected top %+v, got top %+v", expected[:1], got)
+	}
+	if k.Total() != 9 || k.Len() != 3 {
+		t.Fatalf("Expected total 9 of 3 keys, got total %d of %d keys", k.Total(), k.Len())
+	}
+}
+
+func TestTopKEviction(t *testing.T) {
+	k := NewTopK(2)
+	k.Add("/a", 3)
+	k.Add("/b", 1)
+	evicted, ok := k.Add("/c", 1)
+	if !ok || evicted != "/b" {
+		t.Fatalf("Expected /b to be evicted, got %q (%t)", evicted, ok)
+	}
+
+	expected := []Item{
+		{Key: "/a", Count: 3},
+		{Key: "/c", Count: 2, Err: 1},
+	}
+	if got := k.Top(2); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Expected top %+v, got top %+v", expected, got)
+	}
+	if k.Count("/b") != 0 {
+		t.Fatalf("Expected /b not to be tracked, got count %d", k.Count("/b"))
+	}
+}
+
+func TestTopKHeavyHitters(t *testing.T) {
+	// 2 heavy keys hidden in a stream of unique keys
+	k := NewTopK(20)
+	total := 0
+	for i := 0; i < 1000; i++ {
+		k.Add("/unique/"+strconv.Itoa(i), 1)
+		total++
+		if i%4 == 0 {
+			k.Add("/heavy", 1)
+			total++
+		}
+		if i%10 == 0 {
+			k.Add("/medium", 1)
+			total++
+		}
+	}
+
+	if k.Len() != 20 {
+		t.Fatalf("Expected 20 tracked keys, got %d", k.Len())
+	}
+	top := k.Top(2)
+	if top[0].Key != "/heavy" || top[1].Key != "/medium" {
+		t.Fatalf("Expected /heavy and /medium on top, got %+v", top)
+	}
+	// the counts are overestimated by at most total/capacity
+	for _, it := range top {
+		if it.Err > total/20 {
+			t.Errorf("Key %s: error %d above the bound %d", it.Key, it.Err, total/20)
+		}
+	}
+	if top[0].Count-top[0].Err > 250 || top[0].Count < 250 {
+		t.Errorf("Expected /heavy count range to hold 250, got %d-%d", top[0].Count-top[0].Err, top[0].Count)
+	}
+}
+
+func TestTopKMerge(t *testing.T) {
+	a := NewTopK(2)
+	a.Add("/a", 5)
+	a.Add("/b", 2)
+	b := NewTopK(2)
+	b.Add("/a", 1)
+	b.Add("/c", 3)
+
+	a.Merge(b)
+	expected := []Item{
+		{Key: "/a", Count: 6},
+		{Key: "/c", Count: 5, Err: 2},
+	}
+	if got := a.Top(2); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Expected top %+v, got top %+v", expected, got)
+	}
+	if a.Total() != 11 {
+		t.Fatalf("Expected total 11, got %d", a.Total())
+	}
+}